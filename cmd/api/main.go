@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -10,10 +12,15 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/packetframe/api/internal/api/dnsupdate"
+	"github.com/packetframe/api/internal/api/dynamic"
+	"github.com/packetframe/api/internal/api/health"
 	"github.com/packetframe/api/internal/api/metrics"
 	"github.com/packetframe/api/internal/api/routes"
 	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/api/verification"
 	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
 )
 
 // Linker flags
@@ -26,11 +33,25 @@ var (
 const (
 	suffixListUpdateInterval = 24 * time.Hour
 	metricsUpdateInterval    = 15 * time.Minute
+	sessionCleanupInterval   = time.Hour
+	zskRotationInterval      = time.Hour
+	zoneVerificationInterval = 5 * time.Minute
+	zoneHealthCheckInterval  = 6 * time.Hour
+	zoneDynamicInterval      = 30 * time.Second
 )
 
 var (
 	dbHost        = os.Getenv("DB_HOST")
 	metricsListen = os.Getenv("METRICS_LISTEN")
+	resolverAddr  = os.Getenv("RESOLVER")
+	dnsUpdateAddr = os.Getenv("DNS_UPDATE_LISTEN")
+
+	nameservers = os.Getenv("NAMESERVERS")
+	soaRName    = os.Getenv("SOA_RNAME")
+	soaRefresh  = os.Getenv("SOA_REFRESH")
+	soaRetry    = os.Getenv("SOA_RETRY")
+	soaExpire   = os.Getenv("SOA_EXPIRE")
+	soaMinTTL   = os.Getenv("SOA_MIN_TTL")
 
 	smtpHost = os.Getenv("SMTP_HOST")
 	smtpUser = os.Getenv("SMTP_USER")
@@ -39,6 +60,18 @@ var (
 	sentryDsn = os.Getenv("SENTRY_DSN")
 )
 
+// envUint32 parses an optional numeric environment variable, returning 0 if it's empty
+func envUint32(name, value string) uint32 {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		log.Fatalf("%s must be a number: %s", name, err)
+	}
+	return uint32(n)
+}
+
 func main() {
 	if os.Getenv("DOCUMENT") != "" {
 		fmt.Println(routes.Document())
@@ -66,6 +99,21 @@ func main() {
 		log.Fatalf("SENTRY_DSN must be set")
 	}
 
+	if err := db.SetDefaults(strings.Split(nameservers, ","), soaRName, db.SOATimers{
+		Refresh: envUint32("SOA_REFRESH", soaRefresh),
+		Retry:   envUint32("SOA_RETRY", soaRetry),
+		Expire:  envUint32("SOA_EXPIRE", soaExpire),
+		MinTTL:  envUint32("SOA_MIN_TTL", soaMinTTL),
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Default nameservers %v", db.Nameservers)
+
+	if resolverAddr != "" {
+		resolver.Default.Addr = resolverAddr
+	}
+	log.Infof("Using resolver %s", resolver.Default.Addr)
+
 	routes.SMTPHost = smtpHost
 	routes.SMTPUser = smtpUser
 	routes.SMTPPass = smtpPass
@@ -115,6 +163,32 @@ func main() {
 		}
 	}()
 
+	// Delete expired sessions and ACME challenges on a ticker
+	sessionCleanupTicker := time.NewTicker(sessionCleanupInterval)
+	go func() {
+		for range sessionCleanupTicker.C {
+			log.Debugln("Deleting expired sessions")
+			if err := db.SessionDeleteExpired(database); err != nil {
+				log.Warn(err)
+			}
+			log.Debugln("Deleting expired ACME challenges")
+			if err := db.ACMEChallengeDeleteExpired(database); err != nil {
+				log.Warn(err)
+			}
+		}
+	}()
+
+	// Advance automatic ZSK rollovers on a ticker
+	zskRotationTicker := time.NewTicker(zskRotationInterval)
+	go func() {
+		for range zskRotationTicker.C {
+			log.Debugln("Rotating DNSSEC zone signing keys")
+			if err := db.ZoneRotateZSKs(database); err != nil {
+				log.Warn(err)
+			}
+		}
+	}()
+
 	app := fiber.New(fiber.Config{DisableStartupMessage: true})
 	if version == "dev" {
 		log.Debugln("Adding wildcard CORS origin")
@@ -133,6 +207,21 @@ func main() {
 	go metrics.Collector(database, metricsUpdateInterval)
 	go metrics.Listen(metricsListen)
 
+	// RFC 2136 DNS UPDATE listener
+	if dnsUpdateAddr != "" {
+		log.Infof("Starting DNS UPDATE listener on %s", dnsUpdateAddr)
+		dnsupdate.Listen(dnsUpdateAddr, database)
+	}
+
+	// Verify pending zones in the background
+	go verification.Verifier(database, zoneVerificationInterval)
+
+	// Check zone delegation and DNSSEC health in the background
+	go health.Checker(database, resolver.Default, zoneHealthCheckInterval)
+
+	// Apply record health in the background
+	go dynamic.Refresher(database, zoneDynamicInterval)
+
 	startupMessage := fmt.Sprintf("Starting Packetframe API v%s (%s) on :8080", version, commit)
 	sentry.CaptureMessage(startupMessage)
 	log.Println(startupMessage)
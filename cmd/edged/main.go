@@ -4,13 +4,18 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
 	"github.com/packetframe/api/internal/edged/caddy"
+	"github.com/packetframe/api/internal/edged/geodns"
+	"github.com/packetframe/api/internal/edged/healthcheck"
 	"github.com/packetframe/api/internal/edged/scriptdns"
+	"github.com/packetframe/api/internal/edged/transfer"
 	"github.com/packetframe/api/internal/edged/zonegen"
 )
 
@@ -20,12 +25,24 @@ var (
 	rpcListenAddr         = flag.String("rpc-listen", ":8083", "RPC listen address")
 	dbHost                = flag.String("db-host", "localhost", "Postgres database host")
 	zonesDirectory        = flag.String("zones-dir", "/opt/packetframe/dns/zones/", "Directory to store DNS zone files to")
+	keysDirectory         = flag.String("keys-dir", "/opt/packetframe/dns/keys/", "Directory to store DNSSEC key files to")
 	knotZonesFile         = flag.String("knot-zones-file", "/opt/packetframe/dns/knot.zones.conf", "File to write DNS zone manifest to")
 	caddyFile             = flag.String("caddyfile", "", "Path to Caddyfile, disables Caddy functionality if empty")
 	certDir               = flag.String("cert-dir", "/opt/packetframe/certs/", "TLS certificate directory")
 	scriptRefreshInterval = flag.String("script-refresh", "5s", "Script refresh interval")
 	zoneRefreshInterval   = flag.String("zone-refresh", "5s", "Zone refresh interval")
 	caddyRefreshInterval  = flag.String("caddy-refresh", "5s", "Caddy refresh interval")
+	transferCheckInterval = flag.String("transfer-check", "1m", "Secondary zone transfer status check interval")
+	healthCheckInterval   = flag.String("health-check", "30s", "Record health check interval")
+	knotAddr              = flag.String("knot-addr", transfer.Local, "Address of the local knot server, used to check the serial of secondary zones")
+	nameservers           = flag.String("nameservers", strings.Join(db.Nameservers, ","), "Comma separated default nameservers for zones without their own")
+	soaRName              = flag.String("soa-rname", db.SOARName, "Default SOA RNAME")
+	soaRefresh            = flag.Uint("soa-refresh", uint(db.SOADefaults.Refresh), "Default SOA refresh timer")
+	soaRetry              = flag.Uint("soa-retry", uint(db.SOADefaults.Retry), "Default SOA retry timer")
+	soaExpire             = flag.Uint("soa-expire", uint(db.SOADefaults.Expire), "Default SOA expire timer")
+	soaMinTTL             = flag.Uint("soa-min-ttl", uint(db.SOADefaults.MinTTL), "Default SOA negative cache TTL")
+	geoDatabases          = flag.String("geoip-db", "", "Comma separated MaxMind format database files used to answer geo records, such as a country and an ASN database")
+	resolverAddr          = flag.String("resolver", resolver.Default.Addr, "Recursive resolver used to resolve ALIAS targets")
 	verbose               = flag.Bool("verbose", false, "Enable verbose logging")
 )
 
@@ -34,13 +51,33 @@ func main() {
 	if *verbose {
 		log.SetLevel(log.DebugLevel)
 	}
+	resolver.Default.Addr = *resolverAddr
+	transfer.Local = *knotAddr
+
+	if err := db.SetDefaults(strings.Split(*nameservers, ","), *soaRName, db.SOATimers{
+		Refresh: uint32(*soaRefresh),
+		Retry:   uint32(*soaRetry),
+		Expire:  uint32(*soaExpire),
+		MinTTL:  uint32(*soaMinTTL),
+	}); err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("Connecting to database")
-	database, err := db.Open(fmt.Sprintf("host=%s user=readonly password=readonly dbname=api port=5432 sslmode=disable", *dbHost))
+	dsn := fmt.Sprintf("host=%s user=readonly password=readonly dbname=api port=5432 sslmode=disable", *dbHost)
+	database, err := db.Open(dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if *geoDatabases != "" {
+		if err := geodns.Open(strings.Split(*geoDatabases, ",")); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Warn("No GeoIP databases set, geo records will be answered with their default records")
+	}
+
 	// Update public suffix list on a ticker
 	scriptRefresh, err := time.ParseDuration(*scriptRefreshInterval)
 	if err != nil {
@@ -51,24 +88,76 @@ func main() {
 		for range scriptRefreshTicker.C {
 			log.Debug("Refreshing SCRIPT handlers")
 			scriptdns.LoadRecordHandlers(database)
+			log.Debug("Refreshing geo record handlers")
+			geodns.LoadRecordHandlers(database)
 		}
 	}()
 
-	// Update zones list on a ticker
+	// Update zones list on a ticker and when notified of changes
 	zoneRefresh, err := time.ParseDuration(*zoneRefreshInterval)
 	if err != nil {
 		log.Fatal(err)
 	}
 	zoneRefreshTicker := time.NewTicker(zoneRefresh)
+
+	// Refresh zones as soon as the API notifies of a change, such as a published ACME challenge, coalescing notifications that arrive during a refresh
+	zoneChanged := make(chan struct{}, 1)
+	go func() {
+		for {
+			err := db.ListenZoneChanges(dsn, func() {
+				select {
+				case zoneChanged <- struct{}{}:
+				default:
+				}
+			})
+			log.Warnf("listening for zone changes: %s", err)
+			time.Sleep(zoneRefresh)
+		}
+	}()
+
 	go func() {
-		for range zoneRefreshTicker.C {
+		for {
+			select {
+			case <-zoneRefreshTicker.C:
+			case <-zoneChanged:
+			}
 			log.Debug("Refreshing zones")
-			if err := zonegen.Update(*zonesDirectory, *knotZonesFile, database); err != nil {
+			if err := zonegen.Update(*zonesDirectory, *keysDirectory, *knotZonesFile, database); err != nil {
 				log.Warnf("zonegen update: %s", err)
 			}
 		}
 	}()
 
+	// Report the transfer status of secondary zones on a ticker
+	transferCheck, err := time.ParseDuration(*transferCheckInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transferCheckTicker := time.NewTicker(transferCheck)
+	go func() {
+		for range transferCheckTicker.C {
+			log.Debug("Checking secondary zone transfers")
+			if err := transfer.Update(database, *nodeId); err != nil {
+				log.Warnf("transfer status update: %s", err)
+			}
+		}
+	}()
+
+	// Report the health of health checked records on a ticker
+	healthCheck, err := time.ParseDuration(*healthCheckInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	healthCheckTicker := time.NewTicker(healthCheck)
+	go func() {
+		for range healthCheckTicker.C {
+			log.Debug("Checking record health")
+			if err := healthcheck.Update(database, *nodeId); err != nil {
+				log.Warnf("record health update: %s", err)
+			}
+		}
+	}()
+
 	if *caddyFile != "" {
 		log.Info("Caddy enabled")
 		caddyRefresh, err := time.ParseDuration(*caddyRefreshInterval)
@@ -9,6 +9,7 @@ require (
 	github.com/gofiber/fiber/v2 v2.31.0
 	github.com/lib/pq v1.10.4
 	github.com/miekg/dns v1.1.48
+	github.com/oschwald/maxminddb-golang v1.8.0
 	github.com/prometheus/client_golang v1.12.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA1, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, expected := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := TOTPCode(secret, time.Unix(unix, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestAuthValidTOTP(t *testing.T) {
+	secret, err := TOTPSecret()
+	assert.Nil(t, err)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	assert.Nil(t, err)
+	assert.True(t, ValidTOTP(secret, code, now))
+	assert.True(t, ValidTOTP(secret, code, now.Add(30*time.Second)))
+	assert.False(t, ValidTOTP(secret, code, now.Add(5*time.Minute)))
+	assert.False(t, ValidTOTP(secret, "", now))
+	assert.False(t, ValidTOTP("invalid secret!", code, now))
+}
+
+func TestAuthTOTPURI(t *testing.T) {
+	uri := TOTPURI("Packetframe", "user@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Packetframe:user@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Packetframe")
+}
@@ -0,0 +1,390 @@
+package dnsupdate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// managedRRTypes are generated for every zone and can't be changed by an UPDATE
+var managedRRTypes = map[uint16]bool{
+	dns.TypeSOA:        true,
+	dns.TypeDNSKEY:     true,
+	dns.TypeRRSIG:      true,
+	dns.TypeNSEC:       true,
+	dns.TypeNSEC3:      true,
+	dns.TypeNSEC3PARAM: true,
+}
+
+// metaRRTypes are query types that can't appear in an update
+var metaRRTypes = map[uint16]bool{
+	dns.TypeAXFR:  true,
+	dns.TypeIXFR:  true,
+	dns.TypeMAILA: true,
+	dns.TypeMAILB: true,
+}
+
+// server handles RFC 2136 DNS UPDATE messages
+type server struct {
+	database *gorm.DB
+}
+
+// zoneRecord is a record in the working copy of a zone an update is applied to
+type zoneRecord struct {
+	record db.Record
+	name   string
+	rr     dns.RR // Parsed record, nil for records such as SCRIPT and ALIAS that aren't real RR types
+}
+
+// newZoneRecord parses a record for comparison with update RRs
+func newZoneRecord(zone string, record db.Record) zoneRecord {
+	name := validation.RecordName(zone, record.Label)
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, record.TTL, record.Type, record.Value))
+	if err != nil {
+		rr = nil
+	}
+	return zoneRecord{record: record, name: name, rr: rr}
+}
+
+// matches checks if a zone record has a name and, unless the type is ANY, a type
+func (r zoneRecord) matches(name string, rrtype uint16) bool {
+	return r.name == name && (rrtype == dns.TypeANY || r.record.Type == dns.TypeToString[rrtype])
+}
+
+// rrsetExists checks if any record matches a name and type
+func rrsetExists(records []zoneRecord, name string, rrtype uint16) bool {
+	for _, r := range records {
+		if r.matches(name, rrtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// prerequisites checks the prerequisite section of an update against the zone's records and returns an RFC 2136 response code
+func prerequisites(zone string, records []zoneRecord, prereqs []dns.RR) int {
+	// RRsets that must exist with exactly these values, by name and type
+	values := map[string][]dns.RR{}
+
+	for _, rr := range prereqs {
+		hdr := rr.Header()
+		name := strings.ToLower(hdr.Name)
+		if hdr.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(zone, name) {
+			return dns.RcodeNotZone
+		}
+
+		switch hdr.Class {
+		case dns.ClassANY:
+			if hdr.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if !rrsetExists(records, name, hdr.Rrtype) {
+				if hdr.Rrtype == dns.TypeANY {
+					return dns.RcodeNameError
+				}
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if hdr.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if rrsetExists(records, name, hdr.Rrtype) {
+				if hdr.Rrtype == dns.TypeANY {
+					return dns.RcodeYXDomain
+				}
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := name + "/" + dns.TypeToString[hdr.Rrtype]
+			values[key] = append(values[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	for _, expected := range values {
+		hdr := expected[0].Header()
+		var actual []dns.RR
+		for _, r := range records {
+			if r.rr != nil && r.matches(strings.ToLower(hdr.Name), hdr.Rrtype) {
+				actual = append(actual, r.rr)
+			}
+		}
+		if !sameRRset(expected, actual) {
+			return dns.RcodeNXRrset
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// sameRRset checks if two RRsets contain the same RDATA, ignoring TTLs and order
+func sameRRset(a, b []dns.RR) bool {
+	contains := func(rrs []dns.RR, rr dns.RR) bool {
+		for _, other := range rrs {
+			if dns.IsDuplicate(rr, other) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rr := range a {
+		if !contains(b, rr) {
+			return false
+		}
+	}
+	for _, rr := range b {
+		if !contains(a, rr) {
+			return false
+		}
+	}
+	return true
+}
+
+// prescan checks the update section of an update for invalid RRs and returns an RFC 2136 response code
+func prescan(zone string, updates []dns.RR) int {
+	for _, rr := range updates {
+		hdr := rr.Header()
+		if !dns.IsSubDomain(zone, strings.ToLower(hdr.Name)) {
+			return dns.RcodeNotZone
+		}
+		switch hdr.Class {
+		case dns.ClassINET:
+			if hdr.Rrtype == dns.TypeANY || metaRRTypes[hdr.Rrtype] {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if hdr.Ttl != 0 || hdr.Rdlength != 0 || metaRRTypes[hdr.Rrtype] {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if hdr.Ttl != 0 || hdr.Rrtype == dns.TypeANY || metaRRTypes[hdr.Rrtype] {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// apply applies the update section to a working copy of the zone's records and returns the resulting changes
+func apply(zone string, records []zoneRecord, updates []dns.RR) []db.RecordChange {
+	current := append([]zoneRecord{}, records...)
+	remove := func(keep func(r zoneRecord) bool) {
+		var kept []zoneRecord
+		for _, r := range current {
+			if keep(r) {
+				kept = append(kept, r)
+			}
+		}
+		current = kept
+	}
+
+	for _, rr := range updates {
+		hdr := rr.Header()
+		name := strings.ToLower(hdr.Name)
+
+		// The SOA, apex NS records and DNSSEC records are managed by Packetframe
+		if managedRRTypes[hdr.Rrtype] || (hdr.Rrtype == dns.TypeNS && name == zone) {
+			continue
+		}
+
+		switch hdr.Class {
+		case dns.ClassINET:
+			added := newZoneRecord(zone, db.RecordFromRR(rr, zone))
+			duplicate := false
+			for _, r := range current {
+				if r.rr != nil && r.matches(name, hdr.Rrtype) && dns.IsDuplicate(r.rr, rr) {
+					duplicate = true
+				}
+			}
+			if duplicate {
+				continue
+			}
+			// Adding a CNAME replaces the existing CNAME
+			if hdr.Rrtype == dns.TypeCNAME {
+				remove(func(r zoneRecord) bool { return !r.matches(name, dns.TypeCNAME) })
+			}
+			current = append(current, added)
+		case dns.ClassANY:
+			remove(func(r zoneRecord) bool { return !r.matches(name, hdr.Rrtype) })
+		case dns.ClassNONE:
+			// Compare the RDATA with the zone's records, which are in class IN
+			target := dns.Copy(rr)
+			target.Header().Class = dns.ClassINET
+			remove(func(r zoneRecord) bool {
+				return r.rr == nil || !r.matches(name, hdr.Rrtype) || !dns.IsDuplicate(r.rr, target)
+			})
+		}
+	}
+
+	// Delete the original records that are no longer present and add the new ones
+	kept := map[string]bool{}
+	var changes []db.RecordChange
+	for _, r := range current {
+		if r.record.ID == "" {
+			changes = append(changes, db.RecordChange{Op: "add", Record: r.record})
+		} else {
+			kept[r.record.ID] = true
+		}
+	}
+	for _, r := range records {
+		if !kept[r.record.ID] {
+			changes = append(changes, db.RecordChange{Op: "delete", Record: r.record})
+		}
+	}
+	return changes
+}
+
+// updateZone checks an update message's prerequisites and applies its updates to the zone's records, and returns an RFC 2136 response code and the applied changes
+func updateZone(tx *gorm.DB, zone *db.Zone, r *dns.Msg, actor string) (int, []db.RecordDiff, error) {
+	stored, err := db.RecordList(tx, zone.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	var records []zoneRecord
+	for _, record := range stored {
+		records = append(records, newZoneRecord(zone.Zone, record))
+	}
+
+	if rcode := prerequisites(zone.Zone, records, r.Answer); rcode != dns.RcodeSuccess {
+		return rcode, nil, nil
+	}
+	if rcode := prescan(zone.Zone, r.Ns); rcode != dns.RcodeSuccess {
+		return rcode, nil, nil
+	}
+
+	changes := apply(zone.Zone, records, r.Ns)
+	if len(changes) == 0 {
+		return dns.RcodeSuccess, nil, nil
+	}
+
+	// Validate added records like RecordAdd, against the records that remain after the update
+	var added []db.Record
+	removed := map[string]bool{}
+	for _, change := range changes {
+		if change.Op == "add" {
+			if errs := validation.Validate(change.Record); errs != nil {
+				return dns.RcodeRefused, nil, nil
+			}
+			added = append(added, change.Record)
+		} else {
+			removed[change.Record.ID] = true
+		}
+	}
+	var existing []db.Record
+	for _, record := range stored {
+		if !removed[record.ID] {
+			existing = append(existing, record)
+		}
+	}
+	if conflicts := validation.RecordConflicts(zone.Zone, existing, added); len(conflicts) > 0 {
+		log.Debugf("DNS UPDATE for %s refused: %s", zone.Zone, strings.Join(conflicts, ", "))
+		return dns.RcodeRefused, nil, nil
+	}
+
+	diff, err := db.RecordApplyChanges(db.WithActor(tx, actor), zone.ID, changes, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	return dns.RcodeSuccess, diff, nil
+}
+
+// update authorizes and applies an update message and returns an RFC 2136 response code
+func (s *server) update(w dns.ResponseWriter, r *dns.Msg) int {
+	if r.Opcode != dns.OpcodeUpdate {
+		return dns.RcodeNotImplemented
+	}
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError
+	}
+
+	// Updates must be signed with one of the zone's keys
+	t := r.IsTsig()
+	if t == nil {
+		return dns.RcodeRefused
+	}
+	if err := w.TsigStatus(); err != nil {
+		log.Debugf("DNS UPDATE TSIG verification failed (%s): %s", t.Hdr.Name, err)
+		return dns.RcodeNotAuth
+	}
+	key, err := db.UpdateKeyFind(s.database, strings.ToLower(t.Hdr.Name))
+	if err != nil {
+		log.Warnf("finding DNS UPDATE key: %s", err)
+		return dns.RcodeServerFailure
+	}
+	if key == nil {
+		return dns.RcodeNotAuth
+	}
+	// Keys are found by name, so check the zone through the key as other users may have pending claims of the same name
+	zone, err := db.ZoneFindByID(s.database, key.ZoneID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dns.RcodeNotAuth
+	}
+	if err != nil {
+		log.Warnf("finding DNS UPDATE zone: %s", err)
+		return dns.RcodeServerFailure
+	}
+	if zone.Zone != dns.Fqdn(strings.ToLower(r.Question[0].Name)) || zone.IsSecondary() {
+		return dns.RcodeNotAuth
+	}
+
+	// Check and apply the update while the zone is locked, so concurrent changes can't make it conflict
+	actor := "tsig:" + key.TSIG.Name
+	var rcode int
+	var diff []db.RecordDiff
+	err = s.database.Transaction(func(tx *gorm.DB) error {
+		if _, err := db.ZoneLock(tx, zone.ID); err != nil {
+			return err
+		}
+		rcode, diff, err = updateZone(tx, zone, r, actor)
+		return err
+	})
+	if err != nil {
+		log.Warnf("applying DNS UPDATE to %s: %s", zone.Zone, err)
+		return dns.RcodeServerFailure
+	}
+	if rcode != dns.RcodeSuccess || len(diff) == 0 {
+		return rcode
+	}
+	if err := db.AuditAdd(s.database, &db.AuditEntry{Actor: actor, Action: "record.dnsupdate", ZoneID: zone.ID, IP: w.RemoteAddr().String()}, nil, diff); err != nil {
+		log.Warnf("adding DNS UPDATE audit entry: %s", err)
+	}
+	return dns.RcodeSuccess
+}
+
+// ServeDNS handles a DNS message and signs the response with the request's key
+func (s *server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetRcode(r, s.update(w, r))
+	if t := r.IsTsig(); t != nil && w.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+	_ = w.WriteMsg(m)
+}
+
+// Listen starts UDP and TCP DNS UPDATE listeners
+func Listen(addr string, database *gorm.DB) {
+	handler := &server{database: database}
+	provider := keyProvider{database: database}
+	for _, network := range []string{"udp", "tcp"} {
+		go func(network string) {
+			srv := &dns.Server{Addr: addr, Net: network, Handler: handler, TsigProvider: provider}
+			if err := srv.ListenAndServe(); err != nil {
+				log.Fatalf("failed to start DNS UPDATE %s listener: %s", network, err)
+			}
+		}(network)
+	}
+}
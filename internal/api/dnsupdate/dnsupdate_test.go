@@ -0,0 +1,105 @@
+package dnsupdate
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func testRecords() []zoneRecord {
+	var records []zoneRecord
+	for _, record := range []db.Record{
+		{ID: "1", Type: "A", Label: "www", Value: "192.0.2.1", TTL: 300},
+		{ID: "2", Type: "A", Label: "www", Value: "192.0.2.2", TTL: 300},
+		{ID: "3", Type: "TXT", Label: "@", Value: `"v=spf1 -all"`, TTL: 3600},
+		{ID: "4", Type: "CNAME", Label: "blog", Value: "www.example.com.", TTL: 300},
+	} {
+		records = append(records, newZoneRecord("example.com.", record))
+	}
+	return records
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	assert.Nil(t, err)
+	return rr
+}
+
+func TestPrerequisites(t *testing.T) {
+	records := testRecords()
+
+	for _, tc := range []struct {
+		name  string
+		build func(m *dns.Msg)
+		rcode int
+	}{
+		{"rrset exists", func(m *dns.Msg) { m.RRsetUsed([]dns.RR{mustRR(t, "www.example.com. A 0.0.0.0")}) }, dns.RcodeSuccess},
+		{"rrset missing", func(m *dns.Msg) { m.RRsetUsed([]dns.RR{mustRR(t, "www.example.com. AAAA ::")}) }, dns.RcodeNXRrset},
+		{"name in use", func(m *dns.Msg) { m.NameUsed([]dns.RR{mustRR(t, "blog.example.com. A 0.0.0.0")}) }, dns.RcodeSuccess},
+		{"name not in use", func(m *dns.Msg) { m.NameUsed([]dns.RR{mustRR(t, "new.example.com. A 0.0.0.0")}) }, dns.RcodeNameError},
+		{"name unexpectedly in use", func(m *dns.Msg) { m.NameNotUsed([]dns.RR{mustRR(t, "www.example.com. A 0.0.0.0")}) }, dns.RcodeYXDomain},
+		{"rrset unexpectedly exists", func(m *dns.Msg) { m.RRsetNotUsed([]dns.RR{mustRR(t, "www.example.com. A 0.0.0.0")}) }, dns.RcodeYXRrset},
+		{"rrset values match", func(m *dns.Msg) {
+			m.Used([]dns.RR{mustRR(t, "WWW.example.com. A 192.0.2.2"), mustRR(t, "www.example.com. A 192.0.2.1")})
+		}, dns.RcodeSuccess},
+		{"rrset values differ", func(m *dns.Msg) { m.Used([]dns.RR{mustRR(t, "www.example.com. A 192.0.2.1")}) }, dns.RcodeNXRrset},
+		{"outside zone", func(m *dns.Msg) { m.RRsetUsed([]dns.RR{mustRR(t, "www.example.net. A 0.0.0.0")}) }, dns.RcodeNotZone},
+	} {
+		m := new(dns.Msg)
+		m.SetUpdate("example.com.")
+		tc.build(m)
+		assert.Equal(t, tc.rcode, prerequisites("example.com.", records, m.Answer), tc.name)
+	}
+}
+
+func TestPrescan(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{mustRR(t, "new.example.com. 300 A 192.0.2.3")})
+	m.RemoveRRset([]dns.RR{mustRR(t, "www.example.com. A 0.0.0.0")})
+	m.Remove([]dns.RR{mustRR(t, "www.example.com. A 192.0.2.1")})
+	assert.Equal(t, dns.RcodeSuccess, prescan("example.com.", m.Ns))
+
+	assert.Equal(t, dns.RcodeNotZone, prescan("example.com.", []dns.RR{mustRR(t, "www.example.net. 300 A 192.0.2.1")}))
+	assert.Equal(t, dns.RcodeFormatError, prescan("example.com.", []dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeAXFR, Class: dns.ClassANY}}}))
+	assert.Equal(t, dns.RcodeFormatError, prescan("example.com.", []dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeA, Class: dns.ClassCHAOS}}}))
+}
+
+func TestApply(t *testing.T) {
+	records := testRecords()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{
+		mustRR(t, "new.example.com. 300 A 192.0.2.3"),
+		mustRR(t, "www.example.com. 300 A 192.0.2.1"),             // Already exists
+		mustRR(t, "blog.example.com. 300 CNAME new.example.com."), // Replaces the existing CNAME
+		mustRR(t, "example.com. 300 NS ns1.example.net."),         // Managed by Packetframe
+	})
+	m.Remove([]dns.RR{mustRR(t, "www.example.com. A 192.0.2.2")})
+	m.RemoveRRset([]dns.RR{mustRR(t, "example.com. TXT \"\"")})
+
+	var added []db.Record
+	var deleted []string
+	for _, change := range apply("example.com.", records, m.Ns) {
+		if change.Op == "add" {
+			added = append(added, change.Record)
+		} else {
+			deleted = append(deleted, change.Record.ID)
+		}
+	}
+
+	assert.Equal(t, 2, len(added))
+	assert.Equal(t, db.Record{Type: "A", Label: "new", Value: "192.0.2.3", TTL: 300}, added[0])
+	assert.Equal(t, db.Record{Type: "CNAME", Label: "blog", Value: "new.example.com.", TTL: 300}, added[1])
+	assert.ElementsMatch(t, []string{"2", "3", "4"}, deleted)
+
+	// Deleting a RRset of a name without records changes nothing
+	m = new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.RemoveName([]dns.RR{mustRR(t, "missing.example.com. A 0.0.0.0")})
+	assert.Empty(t, apply("example.com.", records, m.Ns))
+}
@@ -0,0 +1,74 @@
+package dnsupdate
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// keyProvider is a dns.TsigProvider that signs and verifies messages with the zones' DNS UPDATE keys
+type keyProvider struct {
+	database *gorm.DB
+}
+
+// Generate computes the MAC of a message with the key named in the TSIG record
+func (p keyProvider) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	key, err := db.UpdateKeyFind(p.database, strings.ToLower(t.Hdr.Name))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, dns.ErrSecret
+	}
+	if dns.CanonicalName(t.Algorithm) != dns.Fqdn(key.TSIG.Algorithm) {
+		return nil, dns.ErrKeyAlg
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(key.TSIG.Secret)
+	if err != nil {
+		return nil, err
+	}
+	var h hash.Hash
+	switch dns.CanonicalName(t.Algorithm) {
+	case dns.HmacSHA1:
+		h = hmac.New(sha1.New, secret)
+	case dns.HmacSHA224:
+		h = hmac.New(sha256.New224, secret)
+	case dns.HmacSHA256:
+		h = hmac.New(sha256.New, secret)
+	case dns.HmacSHA384:
+		h = hmac.New(sha512.New384, secret)
+	case dns.HmacSHA512:
+		h = hmac.New(sha512.New, secret)
+	default:
+		return nil, dns.ErrKeyAlg
+	}
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+// Verify checks the MAC of a message against the key named in the TSIG record
+func (p keyProvider) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := p.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return dns.ErrSig
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package dynamic
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// refreshZone updates a zone's dynamic state from its records' health, and returns the new state, or nil if the zone has no health policy records
+func refreshZone(database *gorm.DB, zone *db.Zone, records []db.Record, now time.Time) (*db.DynamicState, error) {
+	var healthPolicies bool
+	for _, record := range records {
+		if record.Policy != "" && record.Policy != db.PolicyGeo {
+			healthPolicies = true
+		}
+	}
+
+	if !healthPolicies {
+		return nil, nil
+	}
+	health, err := db.RecordHealthStatus(database, zone.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &db.DynamicState{Unhealthy: db.UnhealthyRecords(health)}, nil
+}
+
+// Refresh updates the dynamic state of zones with health policy records, incrementing the serial of zones whose record health changed
+func Refresh(database *gorm.DB, now time.Time) {
+	zones, err := db.ZoneListDynamic(database)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	for i := range zones {
+		zone := &zones[i]
+		previous, err := zone.DynamicState()
+		if err != nil {
+			log.Warnf("parsing dynamic state (%s): %s", zone.Zone, err)
+		}
+
+		records, err := db.RecordList(database, zone.ID)
+		if err != nil {
+			log.Warnf("listing records (%s): %s", zone.Zone, err)
+			continue
+		}
+
+		state, err := refreshZone(database, zone, records, now)
+		if err != nil {
+			log.Warnf("refreshing dynamic state (%s): %s", zone.Zone, err)
+			continue
+		}
+
+		current := db.DynamicState{}
+		if state != nil {
+			current = *state
+		}
+		if state == nil && zone.Dynamic == "" {
+			continue
+		}
+		if state != nil && zone.Dynamic != "" && current.SameAnswers(previous) {
+			continue // Nothing to store
+		}
+
+		if err := db.ZoneSetDynamicState(database, zone.ID, state, !current.SameAnswers(previous)); err != nil {
+			log.Warnf("setting dynamic state (%s): %s", zone.Zone, err)
+		}
+	}
+}
+
+// Refresher refreshes the dynamic state of zones periodically
+func Refresher(database *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for ; true; <-ticker.C {
+		Refresh(database, time.Now())
+	}
+}
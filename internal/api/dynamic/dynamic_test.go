@@ -0,0 +1,22 @@
+package dynamic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRefreshZoneWithoutPolicies(t *testing.T) {
+	// ALIAS targets are resolved by the edge nodes, so zones without health policy records have no state
+	zone := &db.Zone{Zone: "example.com."}
+	records := []db.Record{
+		{Label: "@", Type: "ALIAS", Value: "lb.example.net.", TTL: 300},
+		{Label: "geo", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: db.GeoDefault},
+	}
+	state, err := refreshZone(nil, zone, records, time.Now())
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// dsMatches checks if a DS record at the parent matches any of the zone's key signing keys
+func dsMatches(zone *db.Zone, ds *dns.DS) bool {
+	for _, key := range []db.DNSSECKey{zone.DNSSEC, zone.DNSSECNext} {
+		if key.Key == "" {
+			continue
+		}
+		rr, err := dns.NewRR(key.Key)
+		if err != nil {
+			continue
+		}
+		expected := rr.(*dns.DNSKEY).ToDS(ds.DigestType)
+		if expected != nil && expected.KeyTag == ds.KeyTag && expected.Algorithm == ds.Algorithm && strings.EqualFold(expected.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameNameservers checks if two sets of nameserver hostnames are equal
+func sameNameservers(a, b []string) bool {
+	set := map[string]bool{}
+	for _, ns := range a {
+		set[strings.ToLower(dns.Fqdn(ns))] = true
+	}
+	other := map[string]bool{}
+	for _, ns := range b {
+		ns = strings.ToLower(dns.Fqdn(ns))
+		if !set[ns] {
+			return false
+		}
+		other[ns] = true
+	}
+	return len(set) == len(other)
+}
+
+// Check queries the parent zone for a zone's NS and DS records with a resolver and compares them with the zone's nameservers and keys
+func Check(res *resolver.Resolver, zone *db.Zone) *db.ZoneHealth {
+	health := &db.ZoneHealth{
+		ZoneID:      zone.ID,
+		Nameservers: []string{},
+		DS:          []string{},
+		CheckedAt:   time.Now(),
+	}
+
+	nameservers, err := res.DelegatedNS(zone.Zone)
+	if err != nil {
+		health.Status = db.HealthError
+		health.Error = err.Error()
+		return health
+	}
+	health.Nameservers = nameservers
+
+	ours := false
+	for _, ns := range nameservers {
+		if util.StrSliceContains(zone.Nameservers(), ns) {
+			ours = true
+		}
+	}
+	if !ours {
+		health.Status = db.HealthLame
+		return health
+	}
+	// Resolvers would also query the other nameservers, which don't serve the zone's records
+	if !sameNameservers(nameservers, zone.Nameservers()) {
+		health.Status = db.HealthNSMismatch
+		return health
+	}
+
+	dsRecords, err := res.Delegation(zone.Zone, dns.TypeDS)
+	if err != nil {
+		health.Status = db.HealthError
+		health.Error = err.Error()
+		return health
+	}
+	if len(dsRecords) == 0 {
+		health.Status = db.HealthDSMissing
+		return health
+	}
+
+	health.Status = db.HealthDSMismatch
+	for _, rr := range dsRecords {
+		health.DS = append(health.DS, rr.String())
+		if dsMatches(zone, rr.(*dns.DS)) {
+			health.Status = db.HealthDelegated
+		}
+	}
+	return health
+}
+
+// Checker checks the health of all verified zones with a resolver when started and then periodically
+func Checker(database *gorm.DB, res *resolver.Resolver, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for ; true; <-ticker.C {
+		zones, err := db.ZoneList(database)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		for i := range zones {
+			if zones[i].PendingVerification {
+				continue
+			}
+			if err := db.ZoneHealthSet(database, Check(res, &zones[i])); err != nil {
+				log.Warn(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+func TestCheck(t *testing.T) {
+	ksk, err := db.NewKey("delegated.com.", db.DefaultDNSSECAlgorithm, true)
+	assert.Nil(t, err)
+	otherKSK, err := db.NewKey("mismatch.com.", db.DefaultDNSSECAlgorithm, true)
+	assert.Nil(t, err)
+	mismatchKSK, err := db.NewKey("mismatch.com.", db.DefaultDNSSECAlgorithm, true)
+	assert.Nil(t, err)
+
+	// The parent publishes a SHA-384 DS record for delegated.com
+	rr, err := dns.NewRR(ksk.Key)
+	assert.Nil(t, err)
+	sha384DS := rr.(*dns.DNSKEY).ToDS(dns.SHA384)
+
+	res, shutdown, err := resolver.NewTestServer([]string{
+		"com. 3600 IN NS ns.parent.test.",
+		"ns.parent.test. 3600 IN A 127.0.0.1",
+		"delegated.com. 3600 IN NS ns1.packetframe.com.",
+		"delegated.com. 3600 IN NS ns2.packetframe.com.",
+		sha384DS.String(),
+		"mismatch.com. 3600 IN NS ns1.packetframe.com.",
+		"mismatch.com. 3600 IN NS ns2.packetframe.com.",
+		otherKSK.DSRecordString,
+		"missing.com. 3600 IN NS ns1.packetframe.com.",
+		"missing.com. 3600 IN NS ns2.packetframe.com.",
+		"partial.com. 3600 IN NS ns1.packetframe.com.",
+		"partial.com. 3600 IN NS ns1.example.net.",
+		"lame.com. 3600 IN NS ns1.example.net.",
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+
+	health := Check(res, &db.Zone{Zone: "delegated.com.", DNSSEC: *ksk})
+	assert.Equal(t, db.HealthDelegated, health.Status)
+	assert.Equal(t, []string{"ns1.packetframe.com.", "ns2.packetframe.com."}, []string(health.Nameservers))
+	assert.Equal(t, 1, len(health.DS))
+
+	health = Check(res, &db.Zone{Zone: "mismatch.com.", DNSSEC: *mismatchKSK})
+	assert.Equal(t, db.HealthDSMismatch, health.Status)
+
+	// The DS of the next key matches during a rollover
+	health = Check(res, &db.Zone{Zone: "mismatch.com.", DNSSEC: *mismatchKSK, DNSSECNext: *otherKSK})
+	assert.Equal(t, db.HealthDelegated, health.Status)
+
+	health = Check(res, &db.Zone{Zone: "missing.com."})
+	assert.Equal(t, db.HealthDSMissing, health.Status)
+
+	// Delegations to other nameservers alongside the zone's aren't healthy
+	health = Check(res, &db.Zone{Zone: "partial.com."})
+	assert.Equal(t, db.HealthNSMismatch, health.Status)
+	health = Check(res, &db.Zone{Zone: "partial.com.", ZoneNameservers: []string{"ns1.packetframe.com.", "NS1.example.net"}})
+	assert.Equal(t, db.HealthDSMissing, health.Status)
+
+	health = Check(res, &db.Zone{Zone: "lame.com."})
+	assert.Equal(t, db.HealthLame, health.Status)
+	assert.Equal(t, []string{"ns1.example.net."}, []string(health.Nameservers))
+}
@@ -22,6 +22,10 @@ var (
 		Name: "packetframe_api_zones",
 		Help: "Total DNS zones",
 	})
+	metricZoneHealth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "packetframe_api_zone_health",
+		Help: "Verified DNS zones by delegation and DNSSEC health status",
+	}, []string{"status"})
 )
 
 // Collector runs a ticker to periodically update metrics values
@@ -39,6 +43,14 @@ func Collector(database *gorm.DB, interval time.Duration) {
 			log.Warn(err)
 		}
 		metricZones.Set(float64(len(zones)))
+
+		healthCounts, err := db.ZoneHealthCounts(database)
+		if err != nil {
+			log.Warn(err)
+		}
+		for status, count := range healthCounts {
+			metricZoneHealth.WithLabelValues(status).Set(float64(count))
+		}
 	}
 }
 
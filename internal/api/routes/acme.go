@@ -0,0 +1,191 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// acmeRequest is the request body sent by lego's httpreq DNS provider
+type acmeRequest struct {
+	FQDN  string `json:"fqdn"`
+	Value string `json:"value"`
+}
+
+// acmeAuthorize finds the zone of the request's ACME credential, or responds with an error and returns nil
+func acmeAuthorize(c *fiber.Ctx) (*db.Zone, *db.ACMECredential, error) {
+	username, password, ok := basicAuth(c)
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Packetframe"`)
+		return nil, nil, response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
+	}
+	credential, err := db.ACMECredentialFind(Database, username, password)
+	if err != nil {
+		return nil, nil, internalServerError(c, err)
+	}
+	if credential == nil {
+		return nil, nil, response(c, http.StatusUnauthorized, "Invalid ACME credentials", nil)
+	}
+
+	zone, err := db.ZoneFindByID(Database, credential.ZoneID)
+	if err != nil {
+		return nil, nil, internalServerError(c, err)
+	}
+	if zone.IsSecondary() {
+		return nil, nil, response(c, http.StatusBadRequest, db.ErrSecondaryZone.Error(), nil)
+	}
+	return zone, credential, nil
+}
+
+// ACMEPresent handles a POST request from lego's httpreq provider to publish an ACME DNS-01 challenge
+func ACMEPresent(c *fiber.Ctx) error {
+	var r acmeRequest
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	zone, credential, err := acmeAuthorize(c)
+	if zone == nil {
+		return err
+	}
+
+	// The challenge is served as a TXT record, so it can't be published at a name with a CNAME or SCRIPT record
+	record, err := db.ACMEChallengeRecord(zone, r.FQDN, r.Value)
+	if err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	ok := true
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if ok, err = checkRecordConflicts(c, tx, zone.ID, []db.Record{record}, map[string]bool{}); err != nil || !ok {
+			return err
+		}
+		return db.ACMEChallengePresent(tx, zone, r.FQDN, r.Value)
+	})
+	if !ok {
+		return err
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidACMEChallenge) || errors.Is(err, db.ErrTooManyACMEChallenges) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	acmeAudit(c, credential, "zone.acme.present", r)
+
+	return response(c, http.StatusOK, "ACME challenge published", nil)
+}
+
+// ACMECleanup handles a POST request from lego's httpreq provider to remove an ACME DNS-01 challenge
+func ACMECleanup(c *fiber.Ctx) error {
+	var r acmeRequest
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	zone, credential, err := acmeAuthorize(c)
+	if zone == nil {
+		return err
+	}
+
+	deleted, err := db.ACMEChallengeCleanup(Database, zone, r.FQDN, r.Value)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidACMEChallenge) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	if !deleted {
+		return response(c, http.StatusOK, "ACME challenge doesn't exist, nothing to delete", nil)
+	}
+	acmeAudit(c, credential, "zone.acme.cleanup", r)
+
+	return response(c, http.StatusOK, "ACME challenge removed", nil)
+}
+
+// acmeAudit writes an audit entry for a change made with an ACME credential
+func acmeAudit(c *fiber.Ctx, credential *db.ACMECredential, action string, r acmeRequest) {
+	entry := db.AuditEntry{Actor: "acme:" + credential.Username, Action: action, ZoneID: credential.ZoneID, IP: c.IP()}
+	if err := db.AuditAdd(Database, &entry, nil, r); err != nil {
+		fmt.Printf("Unable to write audit entry %s: %s\n", action, err)
+		sentry.CaptureException(err)
+	}
+}
+
+// ACMECredentialList handles a GET request to list a zone's ACME credentials
+func ACMECredentialList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	credentials, err := db.ACMECredentialList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "ACME credentials retrieved", map[string]interface{}{"credentials": credentials})
+}
+
+// ACMECredentialAdd handles a POST request to create a credential that can only publish ACME challenges in a zone
+func ACMECredentialAdd(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	credential, password, err := db.ACMECredentialAdd(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.acme.credential.add", ZoneID: r.ZoneID}, nil, credential)
+
+	// The password is only returned once
+	return response(c, http.StatusOK, "ACME credential created", map[string]interface{}{
+		"credential": credential,
+		"password":   password,
+	})
+}
+
+// ACMECredentialDelete handles a DELETE request to revoke an ACME credential
+func ACMECredentialDelete(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID       string `json:"zone"`
+		CredentialID string `json:"credential"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	deleted, err := db.ACMECredentialDelete(Database, r.ZoneID, r.CredentialID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !deleted {
+		return response(c, http.StatusOK, "ACME credential doesn't exist, nothing to delete", nil)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.acme.credential.delete", ZoneID: r.ZoneID}, r.CredentialID, nil)
+
+	return response(c, http.StatusOK, "ACME credential deleted", nil)
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesACME(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// The password is returned once
+	content = fmt.Sprintf(`{"zone": "%s"}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/acme", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	credential := apiResp.Data["credential"].(map[string]interface{})
+	basic := base64.StdEncoding.EncodeToString([]byte(credential["username"].(string) + ":" + apiResp.Data["password"].(string)))
+	acmeAuth := map[string]string{"Authorization": "Basic " + basic}
+
+	// ACME credentials can't be used for other API requests
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", acmeAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	content = `{"fqdn": "_acme-challenge.example.com.", "value": "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"}`
+	httpResp, _, err = testReq(app, http.MethodPost, "/acme/present", content, map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("user:wrong"))})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/acme/present", content, acmeAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	challenges, err := db.ACMEChallengeList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(challenges))
+
+	// Only _acme-challenge names can be published
+	httpResp, _, err = testReq(app, http.MethodPost, "/acme/present", `{"fqdn": "www.example.com.", "value": "value"}`, acmeAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Challenges can't be published at a name with a CNAME record
+	err = db.RecordAdd(Database, &db.Record{Type: "CNAME", Label: "_acme-challenge.www", Value: "acme.example.net.", TTL: 300, ZoneID: zone.ID})
+	assert.Nil(t, err)
+	httpResp, _, err = testReq(app, http.MethodPost, "/acme/present", `{"fqdn": "_acme-challenge.www.example.com.", "value": "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"}`, acmeAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/acme/cleanup", content, acmeAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, "ACME challenge removed", apiResp.Message)
+
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/acme", "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["credentials"].([]interface{})))
+
+	content = fmt.Sprintf(`{"zone": "%s", "credential": "%s"}`, zone.ID, credential["id"])
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/dns/zones/acme", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, "ACME credential deleted", apiResp.Message)
+}
@@ -2,7 +2,6 @@ package routes
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -31,6 +30,20 @@ func checkAdminUserAuth(c *fiber.Ctx) (bool, *db.User, error) {
 		return false, nil, response(c, http.StatusUnauthorized, "Unauthorized", nil)
 	}
 
+	// API keys can't be used for admin routes
+	if user.Key != nil {
+		return false, nil, response(c, http.StatusForbidden, errAPIKeyForbidden, nil)
+	}
+
+	// Check if admins are required to use two-factor authentication
+	admin, err := db.UserAdminAuthorized(Database, user)
+	if err != nil {
+		return false, nil, internalServerError(c, err)
+	}
+	if !admin {
+		return false, nil, response(c, http.StatusForbidden, "Two-factor authentication must be enabled to use admin privileges", nil)
+	}
+
 	// If user exists and is admin, return
 	return true, user, nil
 }
@@ -53,7 +66,7 @@ func AdminUserList(c *fiber.Ctx) error {
 // AdminUserGroupAdd handles a PUT request to add a group to a user
 func AdminUserGroupAdd(c *fiber.Ctx) error {
 	// Make sure the user is an admin
-	ok, _, err := checkAdminUserAuth(c)
+	ok, admin, err := checkAdminUserAuth(c)
 	if err != nil || !ok {
 		return err
 	}
@@ -74,13 +87,19 @@ func AdminUserGroupAdd(c *fiber.Ctx) error {
 		return response(c, http.StatusBadRequest, err.Error(), nil)
 	}
 
+	target, err := db.UserFindById(Database, r.UserID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, admin, db.AuditEntry{Action: "admin.user.groups.add", TargetUser: target.Email}, nil, map[string]string{"group": r.Group})
+
 	return response(c, http.StatusOK, "Group added successfully", nil)
 }
 
 // AdminUserGroupRemove handles a DELETE request to remove a group from a user
 func AdminUserGroupRemove(c *fiber.Ctx) error {
 	// Make sure the user is an admin
-	ok, _, err := checkAdminUserAuth(c)
+	ok, admin, err := checkAdminUserAuth(c)
 	if err != nil || !ok {
 		return err
 	}
@@ -101,13 +120,19 @@ func AdminUserGroupRemove(c *fiber.Ctx) error {
 		return response(c, http.StatusBadRequest, err.Error(), nil)
 	}
 
+	target, err := db.UserFindById(Database, r.UserID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, admin, db.AuditEntry{Action: "admin.user.groups.delete", TargetUser: target.Email}, nil, map[string]string{"group": r.Group})
+
 	return response(c, http.StatusOK, "Group removed successfully", nil)
 }
 
 // AdminUserImpersonate handles a POST request to log in as another user
 func AdminUserImpersonate(c *fiber.Ctx) error {
 	// Make sure the user is an admin
-	ok, _, err := checkAdminUserAuth(c)
+	ok, admin, err := checkAdminUserAuth(c)
 	if err != nil || !ok {
 		return err
 	}
@@ -130,13 +155,7 @@ func AdminUserImpersonate(c *fiber.Ctx) error {
 		return response(c, http.StatusUnauthorized, errInvalidCredentials, nil)
 	}
 
-	// Set Token in HTTPONLY cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(30 * 24 * time.Hour), // 30 days
-		HTTPOnly: true,
-	})
+	audit(c, admin, db.AuditEntry{Action: "admin.impersonate", TargetUser: user.Email}, nil, nil)
 
-	return response(c, http.StatusOK, "Authentication success", fiber.Map{"token": user.Token})
+	return loginSuccess(c, user, admin.ID)
 }
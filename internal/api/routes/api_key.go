@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// checkSessionUserAuth checks if a user is logged in without an API key and returns a gofiber response or nil if they are. If it returns true, the user is authorized.
+func checkSessionUserAuth(c *fiber.Ctx) (*db.User, bool, error) {
+	user, err := findUser(c)
+	if err != nil {
+		return nil, false, internalServerError(c, err)
+	}
+	if user == nil {
+		return nil, false, response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
+	}
+	if !util.StrSliceContains(user.Groups, db.GroupEnabled) {
+		return user, false, response(c, http.StatusForbidden, errUserDisabled, nil)
+	}
+	if user.Key != nil {
+		return user, false, response(c, http.StatusForbidden, errAPIKeyForbidden, nil)
+	}
+	return user, true, nil
+}
+
+// APIKeyList handles a GET request to list a user's API keys
+func APIKeyList(c *fiber.Ctx) error {
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	keys, err := db.APIKeyList(Database, user.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "API keys retrieved", map[string]interface{}{"keys": keys})
+}
+
+// APIKeyAdd handles a POST request to create an API key
+func APIKeyAdd(c *fiber.Ctx) error {
+	var k db.APIKey
+	if err := c.BodyParser(&k); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if err := validation.Validate(k); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
+	}
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()) {
+		return response(c, http.StatusBadRequest, "Expiry must be in the future", nil)
+	}
+
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Keys can only be restricted to zones the user has access to
+	admin, err := db.UserAdminAuthorized(Database, user)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !admin {
+		for _, zone := range k.Zones {
+			if err := db.ZoneUserAuthorized(Database, zone, user.ID); err != nil {
+				return response(c, http.StatusBadRequest, "Zone "+zone+" not found", nil)
+			}
+		}
+	}
+
+	key, err := db.APIKeyAdd(Database, user.ID, &k)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.key.add", TargetUser: user.Email}, nil, k)
+
+	return response(c, http.StatusOK, "API key created, store it now as it can't be shown again", map[string]interface{}{
+		"key":  key,
+		"info": k,
+	})
+}
+
+// APIKeyDelete handles a DELETE request to revoke an API key
+func APIKeyDelete(c *fiber.Ctx) error {
+	var k struct {
+		ID string `json:"id"`
+	}
+	if err := c.BodyParser(&k); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.APIKeyDelete(Database, user.ID, k.ID); err != nil {
+		if errors.Is(err, db.ErrAPIKeyNotFound) {
+			return response(c, http.StatusNotFound, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.key.delete", TargetUser: user.Email}, map[string]string{"id": k.ID}, nil)
+
+	return response(c, http.StatusOK, "API key revoked", nil)
+}
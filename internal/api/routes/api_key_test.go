@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesAPIKeys(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	sessionAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	// Add two zones
+	for _, zone := range []string{"example.com", "example.net"} {
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", fmt.Sprintf(`{"zone":"%s"}`, zone), sessionAuth)
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	}
+	exampleCom, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	exampleNet, err := db.ZoneFind(Database, "example.net")
+	assert.Nil(t, err)
+
+	// Create a read-only key restricted to example.com
+	content = fmt.Sprintf(`{"name":"monitoring", "zones":["%s"], "read_only":true}`, exampleCom.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/keys", content, sessionAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	keyAuth := map[string]string{"Authorization": "Token " + apiResp.Data["key"].(string)}
+
+	// The key can read example.com records, but not example.net records
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/records/"+exampleCom.ID, "", keyAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/records/"+exampleNet.ID, "", keyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// The key only lists example.com
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones", "", keyAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["zones"].([]interface{})))
+
+	// The key can't add records
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, exampleCom.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, keyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// The key can't manage API keys
+	httpResp, _, err = testReq(app, http.MethodGet, "/user/keys", "", keyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// Writable keys can't change the password, delete the account or use admin routes
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/keys", `{"name":"automation"}`, sessionAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	writeKeyAuth := map[string]string{"Authorization": "Token " + apiResp.Data["key"].(string)}
+	httpResp, _, err = testReq(app, http.MethodPost, "/user/password", `{"password":"password2"}`, writeKeyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	httpResp, _, err = testReq(app, http.MethodDelete, "/user/delete", "", writeKeyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	assert.Nil(t, db.UserGroupAdd(Database, u.ID, db.GroupAdmin))
+	httpResp, _, err = testReq(app, http.MethodGet, "/admin/user/list", "", writeKeyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	assert.Nil(t, db.UserGroupDelete(Database, u.ID, db.GroupAdmin))
+	writeKeys, err := db.APIKeyList(Database, u.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, db.APIKeyDelete(Database, u.ID, writeKeys[len(writeKeys)-1].ID))
+
+	// List and revoke the key
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/user/keys", "", sessionAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	keys := apiResp.Data["keys"].([]interface{})
+	assert.Equal(t, 1, len(keys))
+	keyID := keys[0].(map[string]interface{})["id"].(string)
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/user/keys", fmt.Sprintf(`{"id":"%s"}`, keyID), sessionAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// The revoked key is no longer accepted
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/records/"+exampleCom.ID, "", keyAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+}
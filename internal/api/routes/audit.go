@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// maxAuditEntries is the maximum number of audit entries returned in one request
+const maxAuditEntries = 1000
+
+// audit records a mutating API call. Failures are reported but don't fail the request, because the change has already been made.
+func audit(c *fiber.Ctx, user *db.User, entry db.AuditEntry, before interface{}, after interface{}) {
+	entry.ActorID = user.ID
+	entry.Actor = user.Email
+	if user.Session != nil {
+		entry.ImpersonatorID = user.Session.ImpersonatorID
+	}
+	if user.Key != nil {
+		entry.APIKeyID = user.Key.ID
+	}
+	entry.IP = c.IP()
+
+	if err := db.AuditAdd(Database, &entry, before, after); err != nil {
+		fmt.Printf("Unable to write audit entry %s: %s\n", entry.Action, err)
+		sentry.CaptureException(err)
+	}
+}
+
+// auditFilter parses audit filters from query parameters
+func auditFilter(c *fiber.Ctx) (db.AuditFilter, error) {
+	filter := db.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		ZoneID:     c.Query("zone"),
+		TargetUser: c.Query("user"),
+		Limit:      100,
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return filter, fmt.Errorf("invalid since time, must be RFC 3339")
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return filter, fmt.Errorf("invalid until time, must be RFC 3339")
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 || filter.Limit > maxAuditEntries {
+			return filter, fmt.Errorf("invalid limit, must be between 1 and %d", maxAuditEntries)
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			return filter, fmt.Errorf("invalid offset")
+		}
+	}
+	return filter, nil
+}
+
+// ZoneAuditList handles a GET request to list audit entries for a zone
+func ZoneAuditList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	filter.ZoneID = zoneID
+
+	entries, err := db.AuditList(Database, filter)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Audit entries retrieved", map[string]interface{}{"entries": entries})
+}
+
+// AdminAuditList handles a GET request to list audit entries for all zones and users
+func AdminAuditList(c *fiber.Ctx) error {
+	ok, _, err := checkAdminUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+
+	entries, err := db.AuditList(Database, filter)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Audit entries retrieved", map[string]interface{}{"entries": entries})
+}
@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesAudit(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com as an admin and user2@example.com
+	tokens := map[string]string{}
+	for _, email := range []string{"user1@example.com", "user2@example.com"} {
+		content := fmt.Sprintf(`{"email":"%s", "password":"example-users-password'"}`, email)
+		httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+		assert.True(t, apiResp.Success)
+
+		u, err := db.UserFindByEmail(Database, email)
+		assert.Nil(t, err)
+		err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+		assert.Nil(t, err)
+
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+		tokens[email] = apiResp.Data["token"].(string)
+	}
+	user1, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, user1.ID, db.GroupAdmin)
+	assert.Nil(t, err)
+	user1Auth := map[string]string{"Authorization": "Token " + tokens["user1@example.com"]}
+	user2Auth := map[string]string{"Authorization": "Token " + tokens["user2@example.com"]}
+
+	// Add a zone and a record
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	content := fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// The zone audit log contains the zone and record additions
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/audit", "", user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	entries := apiResp.Data["entries"].([]interface{})
+	assert.Equal(t, 2, len(entries))
+	latest := entries[0].(map[string]interface{})
+	assert.Equal(t, "record.add", latest["action"])
+	assert.Equal(t, "user2@example.com", latest["actor"])
+	assert.Contains(t, latest["after"], "192.0.2.1")
+
+	// Non-members can't see the zone audit log
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/audit", "", map[string]string{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// Impersonation is recorded with the admin as the actor
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/admin/user/impersonate", `{"email":"user2@example.com"}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	impersonatedAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	// Changes made while impersonating record the impersonator
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, impersonatedAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Filter the global audit log as an admin
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/admin/audit?action=admin.impersonate", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	entries = apiResp.Data["entries"].([]interface{})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "user1@example.com", entries[0].(map[string]interface{})["actor"])
+	assert.Equal(t, "user2@example.com", entries[0].(map[string]interface{})["user"])
+
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/admin/audit?action=record.&limit=1", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	entries = apiResp.Data["entries"].([]interface{})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, user1.ID, entries[0].(map[string]interface{})["impersonator"])
+
+	// Invalid filters are rejected
+	httpResp, _, err = testReq(app, http.MethodGet, "/admin/audit?since=yesterday", "", user1Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Non-admins can't see the global audit log
+	httpResp, _, err = testReq(app, http.MethodGet, "/admin/audit", "", user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+}
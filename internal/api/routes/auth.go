@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -17,6 +18,10 @@ import (
 var (
 	errInvalidCredentials = "invalid username and/or password"
 	errUserDisabled       = "this user is disabled, please contact info@packetframe.com"
+	errAPIKeyForbidden    = "Forbidden, this action can't be performed with an API key"
+
+	// errAPIKeyReadOnly is returned by findUser when a read-only API key is used for a request that isn't a GET
+	errAPIKeyReadOnly = errors.New("this API key is read-only")
 )
 
 var (
@@ -44,9 +49,36 @@ func findUser(c *fiber.Ctx) (*db.User, error) {
 		return nil, err
 	}
 
+	// Enforce API key scope
+	if user != nil && user.Key != nil && user.Key.ReadOnly && c.Method() != http.MethodGet {
+		return nil, errAPIKeyReadOnly
+	}
+
+	// Update session activity and rotate cookie sessions. Sessions used through the Authorization header aren't rotated because the client wouldn't see the new token.
+	if user != nil && user.Session != nil {
+		rotate := string(c.Request().Header.Peek("Authorization")) == ""
+		newToken, err := db.SessionTouch(Database, user.Session, c.IP(), string(c.Request().Header.UserAgent()), rotate)
+		if err != nil {
+			return nil, err
+		}
+		if newToken != "" {
+			setTokenCookie(c, newToken, user.Session.ExpiresAt())
+		}
+	}
+
 	return user, nil
 }
 
+// setTokenCookie sets the session token in an HTTPONLY cookie
+func setTokenCookie(c *fiber.Ctx, token string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    token,
+		Expires:  expires,
+		HTTPOnly: true,
+	})
+}
+
 // UserSignup handles a signup POST request
 func UserSignup(c *fiber.Ctx) error {
 	var u db.User
@@ -68,6 +100,9 @@ func UserSignup(c *fiber.Ctx) error {
 	if err := db.UserAdd(Database, u.Email, u.Password, u.Refer); err != nil {
 		return internalServerError(c, err)
 	}
+	if user, err := db.UserFindByEmail(Database, u.Email); err == nil && user != nil {
+		audit(c, user, db.AuditEntry{Action: "user.signup", TargetUser: user.Email}, nil, nil)
+	}
 
 	return response(c, http.StatusOK, "User created successfully", nil)
 }
@@ -100,19 +135,47 @@ func UserLogin(c *fiber.Ctx) error {
 		return response(c, http.StatusUnauthorized, errInvalidCredentials, nil)
 	}
 
-	// Set Token in HTTPONLY cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(30 * 24 * time.Hour), // 30 days
-		HTTPOnly: true,
-	})
+	// Require a second step if TOTP is enabled
+	if user.TOTPEnabled {
+		challenge, err := db.UserCreateLoginChallenge(Database, user.ID)
+		if err != nil {
+			return internalServerError(c, err)
+		}
+		return response(c, http.StatusOK, "Two-factor authentication required", fiber.Map{"totp_required": true, "challenge": challenge})
+	}
 
-	return response(c, http.StatusOK, "Authentication success", fiber.Map{"token": user.Token})
+	return loginSuccess(c, user, "")
+}
+
+// loginSuccess creates a session, sets the token cookie and returns the token for an authenticated user
+func loginSuccess(c *fiber.Ctx, user *db.User, impersonatorID string) error {
+	token, session, err := db.SessionAdd(Database, user.ID, c.IP(), string(c.Request().Header.UserAgent()), impersonatorID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	setTokenCookie(c, token, session.ExpiresAt())
+	if impersonatorID == "" {
+		audit(c, user, db.AuditEntry{Action: "user.login", TargetUser: user.Email}, nil, nil)
+	}
+
+	return response(c, http.StatusOK, "Authentication success", fiber.Map{"token": token})
 }
 
 // UserLogout handles a GET request to log the user out
 func UserLogout(c *fiber.Ctx) error {
+	// Revoke the current session
+	user, err := findUser(c)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if user != nil && user.Session != nil {
+		if err := db.SessionDelete(Database, user.ID, user.Session.ID); err != nil && !errors.Is(err, db.ErrSessionNotFound) {
+			return internalServerError(c, err)
+		}
+		audit(c, user, db.AuditEntry{Action: "user.logout", TargetUser: user.Email}, nil, nil)
+	}
+
 	// Known workaround https://github.com/gofiber/fiber/issues/1127
 	c.ClearCookie("token")
 	c.Cookie(&fiber.Cookie{
@@ -133,10 +196,14 @@ func UserDelete(c *fiber.Ctx) error {
 	if user == nil {
 		return response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
 	}
+	if user.Key != nil {
+		return response(c, http.StatusForbidden, errAPIKeyForbidden, nil)
+	}
 
 	if err := db.UserDelete(Database, user.Email); err != nil {
 		return internalServerError(c, err)
 	}
+	audit(c, user, db.AuditEntry{Action: "user.delete", TargetUser: user.Email}, nil, nil)
 
 	return response(c, http.StatusOK, "User deleted successfully", nil)
 }
@@ -160,10 +227,19 @@ func UserChangePassword(c *fiber.Ctx) error {
 	if user == nil {
 		return response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
 	}
+	if user.Key != nil {
+		return response(c, http.StatusForbidden, errAPIKeyForbidden, nil)
+	}
 
-	if err := db.UserResetPassword(Database, user.Email, p.Password); err != nil {
+	// Keep the session that changed the password logged in
+	currentSessionID := ""
+	if user.Session != nil {
+		currentSessionID = user.Session.ID
+	}
+	if err := db.UserResetPassword(Database, user.Email, p.Password, currentSessionID); err != nil {
 		return internalServerError(c, err)
 	}
+	audit(c, user, db.AuditEntry{Action: "user.password", TargetUser: user.Email}, nil, nil)
 
 	return response(c, http.StatusOK, "Password reset successfully", nil)
 }
@@ -237,9 +313,12 @@ func UserConfirmPasswordReset(c *fiber.Ctx) error {
 		return response(c, http.StatusForbidden, "Unauthorized: "+err.Error(), nil)
 	}
 
-	if err := db.UserResetPassword(Database, p.Email, p.Password); err != nil {
+	if err := db.UserResetPassword(Database, p.Email, p.Password, ""); err != nil {
 		return internalServerError(c, err)
 	}
+	if user, err := db.UserFindByEmail(Database, p.Email); err == nil && user != nil {
+		audit(c, user, db.AuditEntry{Action: "user.password_reset", TargetUser: user.Email}, nil, nil)
+	}
 
 	if err := util.SendEmail(SMTPHost, SMTPUser, SMTPPass, p.Email, "Packetframe password reset", fmt.Sprintf(`Hello,
 
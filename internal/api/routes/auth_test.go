@@ -74,6 +74,12 @@ func TestRoutesUserSignupLoginDelete(t *testing.T) {
 	assert.True(t, apiResp.Success)
 	assert.False(t, apiResp.Data["admin"].(bool))
 
+	// Log in user1@example.com again from another client
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	otherToken := apiResp.Data["token"].(string)
+
 	// Change user1@example.com's password
 	content = `{"password":"example-users-NEW-password'"}`
 	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/password", content, map[string]string{"Authorization": "Token " + userToken})
@@ -81,6 +87,14 @@ func TestRoutesUserSignupLoginDelete(t *testing.T) {
 	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
 	assert.True(t, apiResp.Success)
 
+	// The session that changed the password stays logged in, other sessions are revoked
+	httpResp, _, err = testReq(app, http.MethodGet, "/user/info", "", map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	httpResp, _, err = testReq(app, http.MethodGet, "/user/info", "", map[string]string{"Authorization": "Token " + otherToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
 	// Log in user1@example.com with the new password
 	content = `{"email":"user1@example.com", "password":"example-users-NEW-password'"}`
 	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
@@ -1,29 +1,84 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 
 	"github.com/packetframe/api/internal/api/validation"
 	"github.com/packetframe/api/internal/common/db"
-	"github.com/packetframe/api/internal/common/util"
 )
 
-func checkProxyRecord(r db.Record, ctx *fiber.Ctx, userGroups []string) error {
-	if !util.StrSliceContains(userGroups, db.GroupAdmin) {
-		return response(ctx, http.StatusForbidden, "You are not authorized to create proxied records. Please contact Packetframe support.", nil)
+// checkProxyRecord checks if a user can add a proxied record, which requires admin privileges, and responds with the reason if they can't
+func checkProxyRecord(c *fiber.Ctx, r db.Record, user *db.User) (bool, error) {
+	admin, err := db.UserAdminAuthorized(Database, user)
+	if err != nil {
+		return false, internalServerError(c, err)
+	}
+	if !admin {
+		return false, response(c, http.StatusForbidden, "You are not authorized to create proxied records. Please contact Packetframe support.", nil)
 	}
 	if strings.Contains(r.Label, "*") {
-		return response(ctx, http.StatusBadRequest, "Proxied records cannot contain wildcards", nil)
+		return false, response(c, http.StatusBadRequest, "Proxied records cannot contain wildcards", nil)
 	}
 	if !(r.Type == "A" || r.Type == "AAAA") {
-		return response(ctx, http.StatusBadRequest, "Proxied records must be A or AAAA", nil)
+		return false, response(c, http.StatusBadRequest, "Proxied records must be A or AAAA", nil)
 	}
+	return true, nil
+}
+
+// recordValueFromData sets a record's value from its structured fields if the value isn't set
+func recordValueFromData(r *db.Record) error {
+	if r.Value != "" || r.Data == nil {
+		return nil
+	}
+	value, err := db.RecordValueFromData(r.Type, r.Data)
+	if err != nil {
+		return err
+	}
+	r.Value = value
+	r.Data = nil
 	return nil
 }
 
+// checkRecordConflicts locks the zone and checks changed records against the rest of the zone, skipping existing records that are changed or removed, and responds with the conflicts if there are any.
+// The changes must be written in the same transaction, so concurrent changes can't add conflicting records after the check.
+func checkRecordConflicts(c *fiber.Ctx, tx *gorm.DB, zoneID string, changed []db.Record, removed map[string]bool) (bool, error) {
+	zone, err := db.ZoneLock(tx, zoneID)
+	if err != nil {
+		return false, internalServerError(c, err)
+	}
+	if zone.IsSecondary() {
+		return false, response(c, http.StatusBadRequest, db.ErrSecondaryZone.Error(), nil)
+	}
+	records, err := db.RecordList(tx, zoneID)
+	if err != nil {
+		return false, internalServerError(c, err)
+	}
+
+	for _, record := range changed {
+		if record.ID != "" {
+			removed[record.ID] = true
+		}
+	}
+	var existing []db.Record
+	for _, record := range records {
+		if !removed[record.ID] {
+			existing = append(existing, record)
+		}
+	}
+
+	if conflicts := validation.RecordConflicts(zone.Zone, existing, changed); len(conflicts) > 0 {
+		return false, response(c, http.StatusConflict, "Record conflicts with existing records", map[string]interface{}{"conflicts": conflicts})
+	}
+	return true, nil
+}
+
 // RecordAdd handles a POST request to add a DNS record
 func RecordAdd(c *fiber.Ctx) error {
 	var r db.Record
@@ -31,18 +86,21 @@ func RecordAdd(c *fiber.Ctx) error {
 		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
 	}
 	r.ID = ""
+	if err := recordValueFromData(&r); err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
 	if err := validation.Validate(r); err != nil {
 		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
 	}
 
 	// Check if user is authorized for zone
-	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID)
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
 	if err != nil || !ok {
 		return err
 	}
 
 	if r.Proxy {
-		if err := checkProxyRecord(r, c, user.Groups); err != nil {
+		if ok, err := checkProxyRecord(c, r, user); err != nil || !ok {
 			return err
 		}
 	}
@@ -55,9 +113,19 @@ func RecordAdd(c *fiber.Ctx) error {
 	}
 
 	// Add the record
-	if err := db.RecordAdd(Database, &r); err != nil {
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if ok, err = checkRecordConflicts(c, tx, r.ZoneID, []db.Record{r}, map[string]bool{}); err != nil || !ok {
+			return err
+		}
+		return db.RecordAdd(db.WithActor(tx, user.Email), &r)
+	})
+	if !ok {
+		return err
+	}
+	if err != nil {
 		return internalServerError(c, err)
 	}
+	audit(c, user, db.AuditEntry{Action: "record.add", ZoneID: r.ZoneID, RecordID: r.ID}, nil, r)
 
 	return response(c, http.StatusOK, "Record added", nil)
 }
@@ -67,7 +135,7 @@ func RecordList(c *fiber.Ctx) error {
 	zoneID := c.Params("id")
 
 	// Check if user is authorized for zone
-	if _, ok, err := checkUserAuthorizationByID(c, zoneID); err != nil || !ok {
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
 		return err
 	}
 
@@ -76,10 +144,37 @@ func RecordList(c *fiber.Ctx) error {
 	if err != nil {
 		return internalServerError(c, err)
 	}
+	for i := range records {
+		records[i].Data = db.RecordDataFromValue(records[i].Type, records[i].Value)
+	}
 
 	return response(c, http.StatusOK, "Zone added", map[string]interface{}{"records": records})
 }
 
+// RecordHealth handles a GET request to get the health of a zone's health checked records on each edge node
+func RecordHealth(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	health, err := db.RecordHealthList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	status, err := db.RecordHealthStatus(Database, zoneID, time.Now())
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Record health retrieved", map[string]interface{}{
+		"nodes":   health,
+		"healthy": status, // Health of each record agreed by the nodes, which decides the answers of record set policies
+	})
+}
+
 // RecordDelete handles a DELETE request to delete a DNS record
 func RecordDelete(c *fiber.Ctx) error {
 	var r struct {
@@ -94,18 +189,28 @@ func RecordDelete(c *fiber.Ctx) error {
 	}
 
 	// Check if user is authorized for zone
-	if _, ok, err := checkUserAuthorizationByID(c, r.ZoneID); err != nil || !ok {
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
 		return err
 	}
 
+	before, err := db.RecordFindByID(Database, r.RecordID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if before == nil || before.ZoneID != r.ZoneID {
+		return response(c, http.StatusNotFound, "Record not found", nil)
+	}
+
 	// Delete the record
-	deleted, err := db.RecordDelete(Database, r.RecordID)
+	deleted, err := db.RecordDelete(db.WithActor(Database, user.Email), r.ZoneID, r.RecordID)
 	if err != nil {
 		return internalServerError(c, err)
 	}
 	if !deleted {
 		return response(c, http.StatusOK, "Record doesn't exist, nothing to delete", nil)
 	}
+	audit(c, user, db.AuditEntry{Action: "record.delete", ZoneID: r.ZoneID, RecordID: r.RecordID}, before, nil)
 
 	return response(c, http.StatusOK, "Record deleted", nil)
 }
@@ -116,18 +221,21 @@ func RecordUpdate(c *fiber.Ctx) error {
 	if err := c.BodyParser(&r); err != nil {
 		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
 	}
+	if err := recordValueFromData(&r); err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
 	if err := validation.Validate(r); err != nil {
 		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
 	}
 
 	// Check if user is authorized for zone
-	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID)
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
 	if err != nil || !ok {
 		return err
 	}
 
 	if r.Proxy {
-		if err := checkProxyRecord(r, c, user.Groups); err != nil {
+		if ok, err := checkProxyRecord(c, r, user); err != nil || !ok {
 			return err
 		}
 	}
@@ -139,11 +247,132 @@ func RecordUpdate(c *fiber.Ctx) error {
 		}
 	}
 
+	// The record must be in the zone the user is authorized for
+	before, err := db.RecordFindByID(Database, r.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if before == nil || before.ZoneID != r.ZoneID {
+		return response(c, http.StatusNotFound, "Record not found", nil)
+	}
+
 	// Update the record
-	// TODO: This doesn't update the proxy field
-	if err := db.RecordUpdate(Database, &r); err != nil {
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		// All fields are replaced, so check the record as it will be stored
+		if ok, err = checkRecordConflicts(c, tx, r.ZoneID, []db.Record{r}, map[string]bool{}); err != nil || !ok {
+			return err
+		}
+		return db.RecordUpdate(db.WithActor(tx, user.Email), &r)
+	})
+	if !ok {
+		return err
+	}
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return response(c, http.StatusNotFound, "Record not found", nil)
+	}
+	if err != nil {
 		return internalServerError(c, err)
 	}
 
+	after, err := db.RecordFindByID(Database, r.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "record.update", ZoneID: r.ZoneID, RecordID: r.ID}, before, after)
+
 	return response(c, http.StatusOK, "Record updated", nil)
 }
+
+// RecordChangeset handles a POST request to atomically apply a set of record changes to a zone
+func RecordChangeset(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID  string            `json:"zone"`
+		DryRun  bool              `json:"dry_run"`
+		Changes []db.RecordChange `json:"changes"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if len(r.Changes) == 0 {
+		return response(c, http.StatusBadRequest, "Changeset must contain at least one change", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
+		return err
+	}
+
+	for i := range r.Changes {
+		if err := recordValueFromData(&r.Changes[i].Record); err != nil {
+			return response(c, http.StatusBadRequest, fmt.Sprintf("Invalid record in change %d: %s", i, err), nil)
+		}
+		change := r.Changes[i]
+		switch change.Op {
+		case "add", "update":
+			if err := validation.Validate(change.Record); err != nil {
+				return response(c, http.StatusBadRequest, fmt.Sprintf("Invalid record in change %d", i), map[string]interface{}{"reason": err})
+			}
+			if change.Record.Proxy {
+				if ok, err := checkProxyRecord(c, change.Record, user); err != nil || !ok {
+					return err
+				}
+			}
+			if change.Record.Type == "SCRIPT" {
+				if err := db.ScriptValidate(change.Record.Value, change.Record.Label); err != nil {
+					return response(c, http.StatusBadRequest, fmt.Sprintf("Error compiling DNS script in change %d: %s", i, err), nil)
+				}
+			}
+		case "delete":
+		default:
+			return response(c, http.StatusBadRequest, fmt.Sprintf("Invalid operation in change %d, must be one of add, update or delete", i), nil)
+		}
+	}
+
+	// Check the records added or updated by the changeset against the zone as it will be once the changeset is applied
+	var changed []db.Record
+	removed := map[string]bool{}
+	for _, change := range r.Changes {
+		for i := range changed {
+			if changed[i].ID != "" && changed[i].ID == change.Record.ID {
+				changed = append(changed[:i], changed[i+1:]...)
+				break
+			}
+		}
+		switch change.Op {
+		case "add":
+			record := change.Record
+			record.ID = ""
+			changed = append(changed, record)
+		case "update":
+			changed = append(changed, change.Record)
+		case "delete":
+			removed[change.Record.ID] = true
+		}
+	}
+	var diff []db.RecordDiff
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if ok, err = checkRecordConflicts(c, tx, r.ZoneID, changed, removed); err != nil || !ok {
+			return err
+		}
+		diff, err = db.RecordApplyChanges(db.WithActor(tx, user.Email), r.ZoneID, r.Changes, r.DryRun)
+		return err
+	})
+	if !ok {
+		return err
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	message := "Changeset applied"
+	if r.DryRun {
+		message = "Changeset validated, no changes were made"
+	} else {
+		audit(c, user, db.AuditEntry{Action: "record.changeset", ZoneID: r.ZoneID}, nil, diff)
+	}
+	return response(c, http.StatusOK, message, map[string]interface{}{"diff": diff})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -114,6 +115,25 @@ func TestRoutesRecordAddListDelete(t *testing.T) {
 	assert.Equal(t, uint32(600), records[0].TTL)
 	assert.Equal(t, "192.0.2.2", records[0].Value)
 
+	// Records of other users' zones can't be changed through an authorized zone
+	err = db.UserAdd(Database, "user2@example.com", "password2", "example referrer")
+	assert.Nil(t, err)
+	other, err := db.ZoneAdd(Database, "example.net", "user2@example.com", 0)
+	assert.Nil(t, err)
+	otherRecord := &db.Record{Type: "A", Label: "@", Value: "192.0.2.3", TTL: 300, ZoneID: other.ID}
+	err = db.RecordAdd(Database, otherRecord)
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.4", "ttl": 300, "id": "%s"}`, zones[0].ID, otherRecord.ID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/dns/records", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+	httpResp, _, err = testReq(app, http.MethodDelete, "/dns/records", fmt.Sprintf(`{"zone": "%s", "record": "%s"}`, zones[0].ID, otherRecord.ID), map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+	unchanged, err := db.RecordFindByID(Database, otherRecord.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.0.2.3", unchanged.Value)
+
 	// Delete record from example.com
 	httpResp, _, err = testReq(app, http.MethodDelete, "/dns/records", fmt.Sprintf(`{"zone": "%s", "record": "%s"}`, zones[0].ID, records[0].ID), map[string]string{"Authorization": "Token " + userToken})
 	assert.Nil(t, err)
@@ -131,3 +151,305 @@ func TestRoutesRecordAddListDelete(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 0, len(records))
 }
+
+func TestRoutesRecordChangeset(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Sign up user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	assert.True(t, apiResp.Success)
+
+	// Enable user1@example.com
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+
+	// Log in user1@example.com
+	content = `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userToken := apiResp.Data["token"].(string)
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Add example.com
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	changeset := fmt.Sprintf(`{"zone": "%s", "dry_run": %%t, "changes": [
+		{"op": "add", "record": {"label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}},
+		{"op": "add", "record": {"label": "www", "type": "CNAME", "value": "example.com.", "ttl": 300}}
+	]}`, zone.ID)
+
+	// Dry run
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records/changeset", fmt.Sprintf(changeset, true), map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 2, len(apiResp.Data["diff"].([]interface{})))
+	records, err := db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+
+	// Apply
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records/changeset", fmt.Sprintf(changeset, false), map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	records, err = db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+
+	// Invalid operation
+	content = fmt.Sprintf(`{"zone": "%s", "changes": [{"op": "replace", "record": {}}]}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records/changeset", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Invalid record
+	content = fmt.Sprintf(`{"zone": "%s", "changes": [{"op": "add", "record": {"label": "@", "type": "A", "value": "not an ip", "ttl": 300}}]}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records/changeset", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+}
+
+func TestRoutesRecordStructuredData(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// Add a CAA record from structured fields
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "CAA", "ttl": 300, "data": {"flag": 0, "tag": "issue", "value": "letsencrypt.org"}}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Add an HTTPS record from a value
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "HTTPS", "ttl": 300, "value": "1 . alpn=\"h2\""}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Invalid structured fields are rejected
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "TLSA", "ttl": 300, "data": {"usage": 3, "certificate": "not hex"}}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Records are listed with both the value and structured fields
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	records := apiResp.Data["records"].([]interface{})
+	assert.Equal(t, 2, len(records))
+	for _, r := range records {
+		record := r.(map[string]interface{})
+		data := record["data"].(map[string]interface{})
+		switch record["type"] {
+		case "CAA":
+			assert.Equal(t, `0 issue "letsencrypt.org"`, record["value"])
+			assert.Equal(t, "letsencrypt.org", data["value"])
+		case "HTTPS":
+			assert.Equal(t, "h2", data["params"].(map[string]interface{})["alpn"])
+		}
+	}
+}
+
+func TestRoutesRecordConflicts(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "CNAME", "value": "example.net.", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Other data at a CNAME is rejected
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "TXT", "value": "\"hello\"", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+
+	// Duplicate records are rejected
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "CNAME", "value": "example.net.", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+
+	// A changeset that replaces the CNAME with an A record is accepted
+	records, err := db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone": "%s", "changes": [
+		{"op": "add", "record": {"label": "www", "type": "A", "value": "192.0.2.1", "ttl": 300}},
+		{"op": "delete", "record": {"id": "%s"}}
+	]}`, zone.ID, records[0].ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records/changeset", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Records in a set must have the same TTL
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 600}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+}
+
+func TestRoutesRecordPolicy(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.1", "ttl": 300, "policy": "failover", "priority": 1, "health_check": {"type": "https", "path": "/health"}}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Records in a set must share a policy
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+
+	// ICMP health checks aren't supported
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 300, "policy": "failover", "priority": 2, "health_check": {"type": "icmp"}}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 300, "policy": "failover", "priority": 2}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	records, err := db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, db.HealthCheck{Type: db.HealthCheckHTTPS, Path: "/health"}, records[0].HealthCheck)
+
+	err = db.RecordHealthSet(Database, &db.RecordHealth{RecordID: records[0].ID, ZoneID: zone.ID, Node: "node1", Healthy: false, CheckedAt: time.Now()})
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/record-health", "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["nodes"].([]interface{})))
+	assert.Equal(t, false, apiResp.Data["healthy"].(map[string]interface{})[records[0].ID])
+
+	// Updates are checked with their new policy
+	content = fmt.Sprintf(`{"zone": "%s", "id": "%s", "label": "www", "type": "A", "value": "192.0.2.2", "ttl": 300}`, zone.ID, records[1].ID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusConflict, httpResp.StatusCode)
+
+	// Updates can clear the health check and set the priority to 0
+	content = fmt.Sprintf(`{"zone": "%s", "id": "%s", "label": "www", "type": "A", "value": "192.0.2.1", "ttl": 300, "policy": "failover", "priority": 0}`, zone.ID, records[0].ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/records", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	updated, err := db.RecordFindByID(Database, records[0].ID)
+	assert.Nil(t, err)
+	assert.False(t, updated.HealthCheck.Configured())
+	assert.Equal(t, uint32(0), updated.Priority)
+}
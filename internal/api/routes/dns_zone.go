@@ -1,13 +1,20 @@
 package routes
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
 
 	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/api/verification"
 	"github.com/packetframe/api/internal/common/db"
 	"github.com/packetframe/api/internal/common/util"
 )
@@ -32,6 +39,11 @@ func ZoneAdd(c *fiber.Ctx) error {
 		return response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
 	}
 
+	// API keys restricted to zones can't add new zones
+	if user.Key != nil && len(user.Key.Zones) > 0 {
+		return response(c, http.StatusForbidden, "Forbidden, this API key is restricted to specific zones", nil)
+	}
+
 	// Suffixes should never be empty because a go routine is updating it
 	if len(Suffixes) == 0 {
 		return internalServerError(c, errors.New("public suffix list is empty"))
@@ -42,15 +54,27 @@ func ZoneAdd(c *fiber.Ctx) error {
 		return response(c, http.StatusBadRequest, "This zone is a public suffix and requires additional verification. Contact Packetframe for more information.", nil)
 	}
 
-	if err := db.ZoneAdd(Database, z.Zone, user.Email); err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+	zone, err := db.ZoneAdd(Database, z.Zone, user.Email, z.DNSSECAlgorithm)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidDNSSECAlgorithm) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		if errors.Is(err, db.ErrZoneExists) || strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return response(c, http.StatusConflict, "Zone already exists", nil)
-		} else {
-			return internalServerError(c, err)
 		}
+		return internalServerError(c, err)
 	}
+	audit(c, user, db.AuditEntry{Action: "zone.add", ZoneID: zone.ID}, nil, zone)
 
-	return response(c, http.StatusOK, "Zone added", nil)
+	name, value := zone.VerificationTXT()
+	return response(c, http.StatusOK, "Zone added, delegate it to our nameservers or add the verification TXT record to activate it", map[string]interface{}{
+		"id": zone.ID,
+		"verification": map[string]string{
+			"name":  name,
+			"value": value,
+		},
+		"nameservers": db.Nameservers,
+	})
 }
 
 // ZoneList handles a GET request to list zones for a user
@@ -68,6 +92,17 @@ func ZoneList(c *fiber.Ctx) error {
 		return internalServerError(c, err)
 	}
 
+	// Only list zones the API key is restricted to
+	if user.Key != nil && len(user.Key.Zones) > 0 {
+		var allowed []db.Zone
+		for _, zone := range zones {
+			if user.Key.AllowsZone(zone.ID) {
+				allowed = append(allowed, zone)
+			}
+		}
+		zones = allowed
+	}
+
 	return response(c, http.StatusOK, "Zone added", map[string]interface{}{"zones": zones})
 }
 
@@ -81,10 +116,16 @@ func ZoneDelete(c *fiber.Ctx) error {
 	}
 
 	// Check if user is authorized for zone
-	if _, ok, err := checkUserAuthorizationByID(c, z.ID); err != nil || !ok {
+	user, ok, err := checkUserAuthorizationByID(c, z.ID, db.RoleOwner)
+	if err != nil || !ok {
 		return err
 	}
 
+	zone, err := db.ZoneFindByID(Database, z.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
 	deleted, err := db.ZoneDelete(Database, z.ID)
 	if !deleted {
 		return response(c, http.StatusOK, "Zone doesn't exist, nothing to delete", nil)
@@ -92,35 +133,139 @@ func ZoneDelete(c *fiber.Ctx) error {
 	if err != nil {
 		return internalServerError(c, err)
 	}
+	audit(c, user, db.AuditEntry{Action: "zone.delete", ZoneID: zone.ID}, zone, nil)
 
 	return response(c, http.StatusOK, "Zone deleted", nil)
 }
 
+// ZoneVerify handles a POST request to verify ownership of a pending zone
+func ZoneVerify(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !zone.PendingVerification {
+		return response(c, http.StatusOK, "Zone already verified", nil)
+	}
+
+	verified, err := verification.Verify(Database, zone)
+	if err != nil {
+		return response(c, http.StatusBadRequest, "Unable to verify zone: "+err.Error(), nil)
+	}
+	if !verified {
+		name, value := zone.VerificationTXT()
+		return response(c, http.StatusBadRequest, "Zone isn't delegated to our nameservers and the verification TXT record wasn't found", map[string]interface{}{
+			"verification": map[string]string{
+				"name":  name,
+				"value": value,
+			},
+			"nameservers": db.Nameservers,
+		})
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.verify", ZoneID: zone.ID}, nil, nil)
+
+	return response(c, http.StatusOK, "Zone verified", nil)
+}
+
+// ZoneSetSOA handles a PUT request to set a zone's SOA timers, RNAME and nameservers
+func ZoneSetSOA(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID      string       `json:"zone"`
+		SOA         db.SOATimers `json:"soa"`
+		RName       string       `json:"rname"`
+		Nameservers []string     `json:"nameservers"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	before, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	nameservers, err := db.NormalizeNameservers(r.Nameservers)
+	if err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if err := verification.Glue(Database, before, nameservers); err != nil {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+
+	if err := db.ZoneSetSOA(Database, r.ZoneID, r.SOA, r.RName, nameservers); err != nil {
+		if errors.Is(err, db.ErrInvalidSOATimers) || errors.Is(err, db.ErrInvalidSOARName) || errors.Is(err, db.ErrInvalidNameservers) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	after, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.soa", ZoneID: r.ZoneID}, before.SOA().String(), after.SOA().String())
+
+	return response(c, http.StatusOK, "Zone SOA updated", map[string]interface{}{
+		"soa":         after.SOA().String(),
+		"nameservers": after.Nameservers(),
+	})
+}
+
 // ZoneUserAdd handles a PUT request to add a user to a zone
 func ZoneUserAdd(c *fiber.Ctx) error {
 	var z struct {
 		ZoneID    string `json:"zone"`
 		UserEmail string `json:"user"`
+		Role      string `json:"role"`
 	}
 	if err := c.BodyParser(&z); err != nil {
 		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
 	}
 
+	// Members added without a role are owners to match the behavior before roles existed
+	if z.Role == "" {
+		z.Role = db.RoleOwner
+	}
+
 	// Check if user is authorized for zone
-	if _, ok, err := checkUserAuthorizationByID(c, z.ZoneID); err != nil || !ok {
+	user, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
 		return err
 	}
 
-	if err := db.ZoneUserAdd(Database, z.ZoneID, z.UserEmail); err != nil {
+	if err := db.ZoneUserAdd(Database, z.ZoneID, z.UserEmail, z.Role); err != nil {
 		if errors.Is(err, db.ErrUserExistingZoneMember) {
 			return response(c, http.StatusBadRequest, err.Error(), nil)
 		} else if errors.Is(err, db.ErrUserNotFound) {
 			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrInvalidRole) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
 		} else {
 			return internalServerError(c, err)
 		}
 	}
 
+	audit(c, user, db.AuditEntry{Action: "zone.user.add", ZoneID: z.ZoneID, TargetUser: z.UserEmail}, nil, map[string]string{"role": z.Role})
+
 	return response(c, http.StatusOK, "User added to zone", nil)
 }
 
@@ -134,10 +279,16 @@ func ZoneUserDelete(c *fiber.Ctx) error {
 		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
 	}
 
-	// Check if user is authorized for zone
-	if _, ok, err := checkUserAuthorizationByID(c, z.ZoneID); err != nil || !ok {
+	// Check if user is authorized for zone, any member can remove themselves
+	user, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleViewer)
+	if err != nil || !ok {
 		return err
 	}
+	if user.Email != z.UserEmail {
+		if _, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleOwner); err != nil || !ok {
+			return err
+		}
+	}
 
 	// Delete user
 	if err := db.ZoneUserDelete(Database, z.ZoneID, z.UserEmail); err != nil {
@@ -147,10 +298,236 @@ func ZoneUserDelete(c *fiber.Ctx) error {
 			return response(c, http.StatusBadRequest, err.Error(), nil)
 		} else if errors.Is(err, db.ErrLastZoneUser) {
 			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrLastZoneOwner) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
 		} else {
 			return internalServerError(c, err)
 		}
 	}
 
+	audit(c, user, db.AuditEntry{Action: "zone.user.delete", ZoneID: z.ZoneID, TargetUser: z.UserEmail}, nil, nil)
+
 	return response(c, http.StatusOK, "User removed from zone", nil)
 }
+
+// ZoneUserSetRole handles a PUT request to change a zone member's role
+func ZoneUserSetRole(c *fiber.Ctx) error {
+	var z struct {
+		ZoneID    string `json:"zone"`
+		UserEmail string `json:"user"`
+		Role      string `json:"role"`
+	}
+	if err := c.BodyParser(&z); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Find the current role for the audit log
+	var before map[string]string
+	if target, err := db.UserFindByEmail(Database, z.UserEmail); err == nil && target != nil {
+		if role, err := db.ZoneUserRole(Database, z.ZoneID, target.ID); err == nil {
+			before = map[string]string{"role": role}
+		}
+	}
+
+	if err := db.ZoneUserSetRole(Database, z.ZoneID, z.UserEmail, z.Role); err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrInvalidRole) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrLastZoneOwner) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else {
+			return internalServerError(c, err)
+		}
+	}
+
+	audit(c, user, db.AuditEntry{Action: "zone.user.role", ZoneID: z.ZoneID, TargetUser: z.UserEmail}, before, map[string]string{"role": z.Role})
+
+	return response(c, http.StatusOK, "User role updated", nil)
+}
+
+// importRejection stores a resource record that was rejected during a zone import
+type importRejection struct {
+	Record string `json:"record"`
+	Reason string `json:"reason"`
+}
+
+// ZoneImport handles a POST request to import records from a zone file
+func ZoneImport(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID   string `json:"zone"`
+		ZoneFile string `json:"zonefile"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if zone.IsSecondary() {
+		return response(c, http.StatusBadRequest, db.ErrSecondaryZone.Error(), nil)
+	}
+
+	var rrs []dns.RR
+	var candidates []db.Record
+	rejected := []importRejection{}
+
+	parser := dns.NewZoneParser(strings.NewReader(r.ZoneFile), zone.Zone, "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		hdr := rr.Header()
+		if !dns.IsSubDomain(zone.Zone, hdr.Name) {
+			rejected = append(rejected, importRejection{rr.String(), "record is outside of zone " + zone.Zone})
+			continue
+		}
+
+		// SOA and apex NS records are generated for every zone
+		if hdr.Rrtype == dns.TypeSOA || (hdr.Rrtype == dns.TypeNS && dns.Fqdn(hdr.Name) == zone.Zone) {
+			rejected = append(rejected, importRejection{rr.String(), dns.Type(hdr.Rrtype).String() + " records at the zone apex are managed by Packetframe"})
+			continue
+		}
+
+		record := db.RecordFromRR(rr, zone.Zone)
+		if errs := validation.Validate(record); errs != nil {
+			rejected = append(rejected, importRejection{rr.String(), validationReason(errs)})
+			continue
+		}
+		rrs = append(rrs, rr)
+		candidates = append(candidates, record)
+	}
+	if err := parser.Err(); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid zone file: "+err.Error(), nil)
+	}
+
+	// Check the records against the zone's records and each other while the zone is locked, so concurrent changes can't add conflicting records
+	var records []db.Record
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if _, err := db.ZoneLock(tx, zone.ID); err != nil {
+			return err
+		}
+		zoneRecords, err := db.RecordList(tx, zone.ID)
+		if err != nil {
+			return err
+		}
+		for i, record := range candidates {
+			if conflicts := validation.RecordConflicts(zone.Zone, zoneRecords, []db.Record{record}); len(conflicts) > 0 {
+				rejected = append(rejected, importRejection{rrs[i].String(), conflicts[0]})
+				continue
+			}
+			records = append(records, record)
+			zoneRecords = append(zoneRecords, record)
+		}
+		return db.RecordAddBatch(db.WithActor(tx, user.Email), zone.ID, records)
+	})
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.import", ZoneID: zone.ID}, nil, records)
+
+	return response(c, http.StatusOK, fmt.Sprintf("Imported %d records", len(records)), map[string]interface{}{
+		"imported": len(records),
+		"rejected": rejected,
+	})
+}
+
+// ZoneExport handles a GET request to export a zone in BIND, JSON or CSV format
+func ZoneExport(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	records, err := db.RecordList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	// Select format by query parameter, falling back to the Accept header
+	format := c.Query("format")
+	if format == "" {
+		switch c.Accepts("text/dns", "application/json", "text/csv") {
+		case "application/json":
+			format = "json"
+		case "text/csv":
+			format = "csv"
+		default:
+			format = "bind"
+		}
+	}
+
+	// SOA and NS records are generated for every zone, so prepend them to the user records
+	exported := []db.Record{db.RecordFromRR(zone.SOA(), zone.Zone)}
+	for _, ns := range zone.NS() {
+		exported = append(exported, db.RecordFromRR(ns, zone.Zone))
+	}
+	exported = append(exported, records...)
+
+	switch format {
+	case "bind":
+		zoneFile := fmt.Sprintf("; %s exported from Packetframe\n$ORIGIN %s\n", zone.Zone, zone.Zone)
+		for _, record := range exported {
+			if record.Type == "SCRIPT" {
+				// SCRIPT records have no zone file representation
+				zoneFile += fmt.Sprintf("; %s SCRIPT record omitted, export as JSON or CSV to include scripts\n", record.Label)
+				continue
+			}
+			if record.Type == "ALIAS" {
+				// ALIAS records are resolved to A and AAAA records when served
+				zoneFile += fmt.Sprintf("; %s ALIAS %s omitted, ALIAS isn't a standard record type\n", record.Label, record.Value)
+				continue
+			}
+			zoneFile += fmt.Sprintf("%s %d IN %s %s\n", record.Label, record.TTL, record.Type, record.Value)
+		}
+		if zone.DNSSEC.DSRecordString != "" {
+			zoneFile += "; DS record to publish at the parent zone\n; " + zone.DNSSEC.DSRecordString + "\n"
+		}
+		c.Set(fiber.HeaderContentType, "text/dns")
+		return c.Status(http.StatusOK).SendString(zoneFile)
+	case "json":
+		return response(c, http.StatusOK, "Zone exported", map[string]interface{}{
+			"zone":    zone.Zone,
+			"serial":  zone.Serial,
+			"records": exported,
+			"ds":      zone.DNSSEC.DSRecordString,
+		})
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		rows := [][]string{{"label", "ttl", "type", "value", "proxy", "policy", "priority", "weight", "geo"}}
+		if ds, err := dns.NewRR(zone.DNSSEC.DSRecordString); err == nil && ds != nil {
+			exported = append(exported, db.RecordFromRR(ds, zone.Zone))
+		}
+		for _, record := range exported {
+			rows = append(rows, []string{
+				record.Label, strconv.Itoa(int(record.TTL)), record.Type, record.Value, strconv.FormatBool(record.Proxy),
+				record.Policy, strconv.Itoa(int(record.Priority)), strconv.Itoa(int(record.Weight)), record.Geo,
+			})
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return internalServerError(c, err)
+		}
+		c.Set(fiber.HeaderContentType, "text/csv")
+		return c.Status(http.StatusOK).Send(buf.Bytes())
+	default:
+		return response(c, http.StatusBadRequest, "Invalid export format, must be one of bind, json or csv", nil)
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"testing"
 
@@ -11,6 +12,7 @@ import (
 
 	"github.com/packetframe/api/internal/api/validation"
 	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
 )
 
 func TestRoutesZoneAddListDelete(t *testing.T) {
@@ -214,3 +216,393 @@ func TestRoutesZoneUserAddDelete(t *testing.T) {
 	assert.Equal(t, 1, len(zones))
 	assert.Equal(t, 1, len(zones[0].Users))
 }
+
+func TestRoutesZoneImport(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	assert.True(t, apiResp.Success)
+
+	// Enable user1@example.com
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+
+	// Log in user1@example.com
+	content = `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userToken := apiResp.Data["token"].(string)
+
+	// Add example.com
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	zoneFile := `$TTL 3600
+@ IN SOA ns1.example.net. hostmaster.example.com. 1 7200 3600 1209600 300
+@ IN NS ns1.example.net.
+@ IN A 192.0.2.1
+www 600 IN CNAME example.com.
+mail IN MX 10 mail.example.com.
+short 60 IN A 192.0.2.2
+other.org. IN A 192.0.2.3
+`
+	body, err := json.Marshal(map[string]string{"zone": zone.ID, "zonefile": zoneFile})
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/import", string(body), map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, float64(3), apiResp.Data["imported"])
+	assert.Equal(t, 4, len(apiResp.Data["rejected"].([]interface{}))) // SOA, NS, short TTL, out of zone
+
+	records, err := db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+
+	// Import a zone file with a syntax error
+	body, err = json.Marshal(map[string]string{"zone": zone.ID, "zonefile": "@ IN A not-an-ip\n"})
+	assert.Nil(t, err)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/zones/import", string(body), map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+}
+
+func TestRoutesZoneExport(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	assert.True(t, apiResp.Success)
+
+	// Enable user1@example.com
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+
+	// Log in user1@example.com
+	content = `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userToken := apiResp.Data["token"].(string)
+
+	// Add example.com with a record
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	err = db.RecordAdd(Database, &db.Record{Type: "A", Label: "lb", Value: "192.0.2.2", TTL: 300, Policy: db.PolicyWeighted, Weight: 10, ZoneID: zone.ID})
+	assert.Nil(t, err)
+
+	// Export as JSON
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/export?format=json", "", map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, "example.com.", apiResp.Data["zone"])
+	assert.Equal(t, 5, len(apiResp.Data["records"].([]interface{}))) // SOA, 2x NS, 2x A
+	assert.NotEmpty(t, apiResp.Data["ds"])
+
+	// Export as BIND and CSV by Accept header
+	// The DS record is a comment in BIND and a row in CSV, and CSV includes record set policies
+	for accept, expected := range map[string][]string{
+		"text/dns": {"www 300 IN A 192.0.2.1", "; DS record to publish at the parent zone"},
+		"text/csv": {"label,ttl,type,value,proxy,policy,priority,weight,geo", "www,300,A,192.0.2.1,false,,0,0,", "lb,300,A,192.0.2.2,false,weighted,0,10,", ",DS,"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/dns/zones/"+zone.ID+"/export", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Token "+userToken)
+		req.Header.Set("Accept", accept)
+		httpResp, err = app.Test(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+		body, err := io.ReadAll(httpResp.Body)
+		assert.Nil(t, err)
+		for _, line := range expected {
+			assert.Contains(t, string(body), line)
+		}
+		assert.Contains(t, string(body), "SOA")
+	}
+
+	// Export in an unknown format
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/export?format=xml", "", map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+}
+
+func TestRoutesZoneUserRoles(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com and user2@example.com
+	tokens := map[string]string{}
+	for _, email := range []string{"user1@example.com", "user2@example.com"} {
+		content := fmt.Sprintf(`{"email":"%s", "password":"example-users-password'"}`, email)
+		httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+		assert.True(t, apiResp.Success)
+
+		u, err := db.UserFindByEmail(Database, email)
+		assert.Nil(t, err)
+		err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+		assert.Nil(t, err)
+
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+		tokens[email] = apiResp.Data["token"].(string)
+	}
+	user1Auth := map[string]string{"Authorization": "Token " + tokens["user1@example.com"]}
+	user2Auth := map[string]string{"Authorization": "Token " + tokens["user2@example.com"]}
+
+	// Add the zone
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// Add user2 as a viewer
+	content := fmt.Sprintf(`{"zone":"%s", "user": "user2@example.com", "role": "viewer"}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/user", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// List zones as user2 and check the roles
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones", "", user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	respJSON, err := json.Marshal(apiResp.Data["zones"])
+	assert.Nil(t, err)
+	var zones []db.Zone
+	err = json.Unmarshal(respJSON, &zones)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+	assert.Equal(t, 2, len(zones[0].UserRoles))
+	assert.Contains(t, zones[0].UserRoles, "viewer")
+
+	// user2 can list records
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// user2 can't add records
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// user2 can't delete the zone
+	httpResp, _, err = testReq(app, http.MethodDelete, "/dns/zones", fmt.Sprintf(`{"id":"%s"}`, zone.ID), user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// Promote user2 to editor, who can then add records
+	content = fmt.Sprintf(`{"zone":"%s", "user": "user2@example.com", "role": "editor"}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/user/role", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// user2 can remove themselves from the zone
+	content = fmt.Sprintf(`{"zone":"%s", "user": "user2@example.com"}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/dns/zones/user", content, user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+}
+
+func TestRoutesZoneVerify(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	// New zones are pending verification
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	verificationRecord := apiResp.Data["verification"].(map[string]interface{})
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	assert.True(t, zone.PendingVerification)
+
+	// The zone isn't delegated and has no TXT record yet
+	shutdown, err := resolver.TestServer([]string{
+		"com. 3600 IN NS ns.parent.test.",
+		"ns.parent.test. 3600 IN A 127.0.0.1",
+	})
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone":"%s"}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/zones/verify", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+	assert.Nil(t, shutdown())
+
+	// Add the TXT record and verify the zone
+	shutdown, err = resolver.TestServer([]string{
+		"com. 3600 IN NS ns.parent.test.",
+		"ns.parent.test. 3600 IN A 127.0.0.1",
+		fmt.Sprintf(`%s 300 IN TXT "%s"`, verificationRecord["name"], verificationRecord["value"]),
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/verify", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err = db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	assert.False(t, zone.PendingVerification)
+}
+
+func TestRoutesZoneSetSOA(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// Set a negative cache TTL and RNAME
+	content = fmt.Sprintf(`{"zone":"%s", "soa":{"min_ttl":60}, "rname":"hostmaster.example.com."}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/soa", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err = db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(60), zone.SOA().Minttl)
+
+	// Invalid timers are rejected
+	content = fmt.Sprintf(`{"zone":"%s", "soa":{"refresh":10}}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/dns/zones/soa", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	shutdown, err := resolver.TestServer([]string{
+		"ns1.packetframe.com. 3600 IN A 192.0.2.1",
+		"ns2.packetframe.com. 3600 IN A 192.0.2.2",
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+
+	// In-zone vanity nameservers need glue records in the zone
+	content = fmt.Sprintf(`{"zone":"%s", "nameservers":["ns1.example.com", "ns2.example.com"]}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/dns/zones/soa", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	for i, addr := range []string{"192.0.2.1", "192.0.2.2"} {
+		record := fmt.Sprintf(`{"zone": "%s", "label": "ns%d", "type": "A", "value": "%s", "ttl": 300}`, zone.ID, i+1, addr)
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", record, userAuth)
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	}
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/soa", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err = db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ns1.example.com.", "ns2.example.com."}, zone.Nameservers())
+	assert.Equal(t, uint32(300), zone.SOA().Minttl)
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// ZoneVersionList handles a GET request to list the versions of a zone
+func ZoneVersionList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	versions, err := db.ZoneVersionList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Zone versions retrieved successfully", map[string]interface{}{"versions": versions})
+}
+
+// ZoneVersionDiff handles a GET request to diff two versions of a zone
+func ZoneVersionDiff(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	from, err := strconv.ParseUint(c.Query("from"), 10, 64)
+	if err != nil {
+		return response(c, http.StatusBadRequest, "Invalid from serial", nil)
+	}
+	to, err := strconv.ParseUint(c.Query("to"), 10, 64)
+	if err != nil {
+		return response(c, http.StatusBadRequest, "Invalid to serial", nil)
+	}
+
+	diff, err := db.ZoneVersionDiff(Database, zoneID, from, to)
+	if err != nil {
+		if errors.Is(err, db.ErrZoneVersionNotFound) {
+			return response(c, http.StatusNotFound, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Zone versions compared successfully", map[string]interface{}{"diff": diff})
+}
+
+// ZoneRollback handles a POST request to restore a previous version of a zone
+func ZoneRollback(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+		Serial uint64 `json:"serial"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
+		return err
+	}
+
+	// The version's records replace all of the zone's records, so check them against each other while the zone is locked
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if _, err := db.ZoneLock(tx, r.ZoneID); err != nil {
+			return err
+		}
+		records, err := db.ZoneVersionRecords(tx, r.ZoneID, r.Serial)
+		if err != nil {
+			return err
+		}
+		current, err := db.RecordList(tx, r.ZoneID)
+		if err != nil {
+			return err
+		}
+		removed := map[string]bool{}
+		for _, record := range current {
+			removed[record.ID] = true
+		}
+		if ok, err = checkRecordConflicts(c, tx, r.ZoneID, records, removed); err != nil || !ok {
+			return err
+		}
+		return db.ZoneRollback(db.WithActor(tx, user.Email), r.ZoneID, r.Serial)
+	})
+	if !ok {
+		return err
+	}
+	if errors.Is(err, db.ErrZoneVersionNotFound) {
+		return response(c, http.StatusNotFound, err.Error(), nil)
+	}
+	if errors.Is(err, db.ErrSecondaryZone) {
+		return response(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "zone.rollback", ZoneID: r.ZoneID}, nil, map[string]uint64{"serial": r.Serial})
+
+	return response(c, http.StatusOK, "Zone rolled back", nil)
+}
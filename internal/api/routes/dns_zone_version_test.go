@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesZoneVersionRollback(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	assert.True(t, apiResp.Success)
+
+	// Enable user1@example.com
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+
+	// Log in user1@example.com
+	content = `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userToken := apiResp.Data["token"].(string)
+
+	// Add example.com with a record
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// List versions
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/versions", "", map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	versions := apiResp.Data["versions"].([]interface{})
+	assert.Equal(t, 2, len(versions))
+	assert.Equal(t, "user1@example.com", versions[0].(map[string]interface{})["actor"])
+
+	// Diff the two versions
+	path := fmt.Sprintf("/dns/zones/%s/versions/diff?from=%d&to=%d", zone.ID, zone.Serial, zone.Serial+1)
+	httpResp, apiResp, err = testReq(app, http.MethodGet, path, "", map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["diff"].([]interface{})))
+
+	// Roll back to the original version
+	content = fmt.Sprintf(`{"zone": "%s", "serial": %d}`, zone.ID, zone.Serial)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/rollback", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	records, err := db.RecordList(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+
+	// Roll back to a version that doesn't exist
+	content = fmt.Sprintf(`{"zone": "%s", "serial": 1}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/zones/rollback", content, map[string]string{"Authorization": "Token " + userToken})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// dnssecKeyInfo stores the public parts of a DNSSEC key as returned by the API
+type dnssecKeyInfo struct {
+	KeyTag     int    `json:"key_tag"`
+	Algorithm  int    `json:"algorithm"`
+	DigestType int    `json:"digest_type,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	DS         string `json:"ds,omitempty"`
+	DNSKEY     string `json:"dnskey"`
+}
+
+// newDNSSECKeyInfo returns the public parts of a key, or nil if the key is empty
+func newDNSSECKeyInfo(key db.DNSSECKey) *dnssecKeyInfo {
+	if key.Key == "" {
+		return nil
+	}
+	return &dnssecKeyInfo{
+		KeyTag:     key.DSKeyTag,
+		Algorithm:  key.DSAlgo,
+		DigestType: key.DSDigestType,
+		Digest:     key.DSDigest,
+		DS:         key.DSRecordString,
+		DNSKEY:     key.Key,
+	}
+}
+
+// newZSKInfo returns the public parts of a zone signing key, which has no DS record at the parent
+func newZSKInfo(key db.DNSSECKey) *dnssecKeyInfo {
+	info := newDNSSECKeyInfo(key)
+	if info != nil {
+		info.DigestType = 0
+		info.Digest = ""
+		info.DS = ""
+	}
+	return info
+}
+
+// ZoneDNSSEC handles a GET request to get the DS and DNSKEY records of a zone
+func ZoneDNSSEC(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "DNSSEC keys retrieved", map[string]interface{}{
+		"algorithm": zone.DNSSECAlgorithm,
+		"key":       newDNSSECKeyInfo(zone.DNSSEC),
+		"next":      newDNSSECKeyInfo(zone.DNSSECNext),
+		"zsk":       newZSKInfo(zone.ZSK),
+		"zsk_next":  newZSKInfo(zone.ZSKNext),
+		"rollover":  zone.DNSSECRolloverInProgress(),
+	})
+}
+
+// ZoneDNSSECRolloverStart handles a POST request to start a DNSSEC key rollover, optionally to a new algorithm
+func ZoneDNSSECRolloverStart(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID    string `json:"zone"`
+		Algorithm uint8  `json:"algorithm"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.ZoneDNSSECRolloverStart(Database, r.ZoneID, r.Algorithm); err != nil {
+		if errors.Is(err, db.ErrDNSSECRolloverInProgress) || errors.Is(err, db.ErrInvalidDNSSECAlgorithm) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		if errors.Is(err, db.ErrDNSSECKeysChanged) {
+			return response(c, http.StatusConflict, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	zone, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	next := newDNSSECKeyInfo(zone.DNSSECNext)
+	audit(c, user, db.AuditEntry{Action: "zone.dnssec.rollover.start", ZoneID: zone.ID}, newDNSSECKeyInfo(zone.DNSSEC), next)
+
+	return response(c, http.StatusOK, "DNSSEC rollover started, add the new DS record at your registrar and confirm the rollover once it's live", map[string]interface{}{
+		"next": next,
+	})
+}
+
+// ZoneDNSSECRolloverConfirm handles a POST request to finish a DNSSEC key rollover once the new DS record is live
+func ZoneDNSSECRolloverConfirm(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	before, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	if err := db.ZoneDNSSECRolloverConfirm(Database, r.ZoneID); err != nil {
+		if errors.Is(err, db.ErrDNSSECRolloverNotInProgress) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		if errors.Is(err, db.ErrDNSSECKeysChanged) {
+			return response(c, http.StatusConflict, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.dnssec.rollover.confirm", ZoneID: r.ZoneID}, newDNSSECKeyInfo(before.DNSSEC), newDNSSECKeyInfo(before.DNSSECNext))
+
+	return response(c, http.StatusOK, "DNSSEC rollover complete, the old DS record can be removed from your registrar", nil)
+}
+
+// ZoneDNSSECRolloverCancel handles a DELETE request to cancel a DNSSEC key rollover
+func ZoneDNSSECRolloverCancel(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	before, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	if err := db.ZoneDNSSECRolloverCancel(Database, r.ZoneID); err != nil {
+		if errors.Is(err, db.ErrDNSSECRolloverNotInProgress) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		if errors.Is(err, db.ErrDNSSECKeysChanged) {
+			return response(c, http.StatusConflict, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.dnssec.rollover.cancel", ZoneID: r.ZoneID}, newDNSSECKeyInfo(before.DNSSECNext), nil)
+
+	return response(c, http.StatusOK, "DNSSEC rollover cancelled", nil)
+}
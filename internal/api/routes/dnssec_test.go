@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesZoneDNSSEC(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	user1Auth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// Get the DS record
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dnssec", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, zone.DNSSEC.DSRecordString, apiResp.Data["key"].(map[string]interface{})["ds"])
+	assert.Nil(t, apiResp.Data["next"])
+	assert.False(t, apiResp.Data["rollover"].(bool))
+
+	// Unauthenticated users can't see the keys
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dnssec", "", map[string]string{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// Start a rollover
+	content = fmt.Sprintf(`{"zone":"%s"}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/dnssec/rollover", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	newDS := apiResp.Data["next"].(map[string]interface{})["ds"]
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/zones/dnssec/rollover", content, user1Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Both keys are returned during the rollover
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dnssec", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, zone.DNSSEC.DSRecordString, apiResp.Data["key"].(map[string]interface{})["ds"])
+	assert.Equal(t, newDS, apiResp.Data["next"].(map[string]interface{})["ds"])
+	assert.True(t, apiResp.Data["rollover"].(bool))
+
+	// Confirm the rollover
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones/dnssec/rollover/confirm", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dnssec", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, newDS, apiResp.Data["key"].(map[string]interface{})["ds"])
+	assert.Nil(t, apiResp.Data["next"])
+
+	// Nothing to cancel
+	httpResp, _, err = testReq(app, http.MethodDelete, "/dns/zones/dnssec/rollover", content, user1Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Add a zone with a selected algorithm
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.net", "dnssec_algorithm": 15}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err = db.ZoneFind(Database, "example.net")
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dnssec", "", user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, float64(15), apiResp.Data["algorithm"])
+	zsk := apiResp.Data["zsk"].(map[string]interface{})
+	assert.Equal(t, float64(15), zsk["algorithm"])
+	assert.Nil(t, zsk["ds"])
+
+	// Unsupported algorithms are rejected
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.org", "dnssec_algorithm": 5}`, user1Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+}
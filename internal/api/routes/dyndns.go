@@ -0,0 +1,252 @@
+package routes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// Responses defined by the dyndns2 protocol
+const (
+	dynDNSGood    = "good"    // Record updated
+	dynDNSNoChg   = "nochg"   // Record already has the address
+	dynDNSBadAuth = "badauth" // Invalid username or password
+	dynDNSNoHost  = "nohost"  // Hostname isn't the credential's record, or no address of the record's family was given
+	dynDNSNotFQDN = "notfqdn" // Hostname missing
+	dynDNSDNSErr  = "dnserr"  // Address is invalid for the record or conflicts with the zone's other records
+	dynDNSError   = "911"     // Server error
+)
+
+// basicAuth parses the username and password from an HTTP basic Authorization header
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	header := string(c.Request().Header.Peek("Authorization"))
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// NicUpdate handles a dyndns2 protocol GET request to update an A or AAAA record. Responses are plain text as expected by dyndns2 clients.
+func NicUpdate(c *fiber.Ctx) error {
+	username, password, ok := basicAuth(c)
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Packetframe"`)
+		return c.Status(http.StatusUnauthorized).SendString(dynDNSBadAuth)
+	}
+	credential, err := db.DynDNSCredentialFind(Database, username, password)
+	if err != nil {
+		sentry.CaptureException(err)
+		return c.Status(http.StatusInternalServerError).SendString(dynDNSError)
+	}
+	if credential == nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Packetframe"`)
+		return c.Status(http.StatusUnauthorized).SendString(dynDNSBadAuth)
+	}
+
+	if c.Query("hostname") == "" {
+		return c.SendString(dynDNSNotFQDN)
+	}
+
+	// Use the client's address if no addresses are given
+	var addresses []net.IP
+	for _, address := range strings.Split(c.Query("myip", c.IP()), ",") {
+		if ip := net.ParseIP(strings.TrimSpace(address)); ip != nil {
+			addresses = append(addresses, ip)
+		}
+	}
+
+	// Clients may update several hostnames at once and expect a response line for each
+	var results []string
+	for _, hostname := range strings.Split(c.Query("hostname"), ",") {
+		results = append(results, dynDNSUpdate(c, credential, strings.TrimSpace(hostname), addresses))
+	}
+	return c.SendString(strings.Join(results, "\n"))
+}
+
+// dynDNSUpdate updates the credential's record if it's at hostname and returns the dyndns2 response
+func dynDNSUpdate(c *fiber.Ctx, credential *db.DynDNSCredential, hostname string, addresses []net.IP) string {
+	record, err := db.RecordFindByID(Database, credential.RecordID)
+	if err != nil {
+		sentry.CaptureException(err)
+		return dynDNSError
+	}
+	if record == nil {
+		return dynDNSNoHost
+	}
+	zone, err := db.ZoneFindByID(Database, record.ZoneID)
+	if err != nil {
+		sentry.CaptureException(err)
+		return dynDNSError
+	}
+	if zone.IsSecondary() || validation.RecordName(zone.Zone, record.Label) != strings.ToLower(dns.Fqdn(hostname)) {
+		return dynDNSNoHost
+	}
+
+	// The record's type may have changed since the credential was created
+	if record.Type != "A" && record.Type != "AAAA" {
+		return dynDNSNoHost
+	}
+
+	// Use the first address of the record's family
+	var address string
+	for _, ip := range addresses {
+		if (ip.To4() != nil) == (record.Type == "A") {
+			address = ip.String()
+			break
+		}
+	}
+	if address == "" {
+		return dynDNSNoHost
+	}
+	if address == record.Value {
+		return dynDNSNoChg + " " + address
+	}
+
+	updated := *record
+	updated.Value = address
+	if err := validation.Validate(updated); err != nil {
+		return dynDNSDNSErr
+	}
+
+	// Check for conflicts while the zone is locked, so concurrent changes can't add conflicting records
+	actor := "dyndns:" + credential.Username
+	conflicting := false
+	err = Database.Transaction(func(tx *gorm.DB) error {
+		if _, err := db.ZoneLock(tx, zone.ID); err != nil {
+			return err
+		}
+		records, err := db.RecordList(tx, zone.ID)
+		if err != nil {
+			return err
+		}
+		var existing []db.Record
+		for _, r := range records {
+			if r.ID != record.ID {
+				existing = append(existing, r)
+			}
+		}
+		if conflicts := validation.RecordConflicts(zone.Zone, existing, []db.Record{updated}); len(conflicts) > 0 {
+			conflicting = true
+			return nil
+		}
+		return db.RecordUpdate(db.WithActor(tx, actor), &updated)
+	})
+	if err != nil {
+		sentry.CaptureException(err)
+		return dynDNSError
+	}
+	if conflicting {
+		return dynDNSDNSErr
+	}
+	err = db.AuditAdd(Database, &db.AuditEntry{Actor: actor, Action: "record.dyndns", ZoneID: zone.ID, RecordID: record.ID, IP: c.IP()}, record, &updated)
+	if err != nil {
+		fmt.Printf("Unable to write audit entry record.dyndns: %s\n", err)
+		sentry.CaptureException(err)
+	}
+
+	return dynDNSGood + " " + address
+}
+
+// DynDNSCredentialList handles a GET request to list a zone's dynamic DNS credentials
+func DynDNSCredentialList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	credentials, err := db.DynDNSCredentialList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Dynamic DNS credentials retrieved", map[string]interface{}{"credentials": credentials})
+}
+
+// DynDNSCredentialAdd handles a POST request to create a dynamic DNS credential for an A or AAAA record
+func DynDNSCredentialAdd(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID   string `json:"zone"`
+		RecordID string `json:"record"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
+		return err
+	}
+
+	record, err := db.RecordFindByID(Database, r.RecordID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if record == nil || record.ZoneID != r.ZoneID {
+		return response(c, http.StatusNotFound, "Record not found", nil)
+	}
+
+	credential, password, err := db.DynDNSCredentialAdd(Database, record)
+	if err != nil {
+		if errors.Is(err, db.ErrDynDNSRecordType) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "record.dyndns.add", ZoneID: r.ZoneID, RecordID: record.ID}, nil, credential)
+
+	// The password is only returned once
+	return response(c, http.StatusOK, "Dynamic DNS credential created", map[string]interface{}{
+		"credential": credential,
+		"password":   password,
+	})
+}
+
+// DynDNSCredentialDelete handles a DELETE request to revoke a dynamic DNS credential
+func DynDNSCredentialDelete(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID       string `json:"zone"`
+		CredentialID string `json:"credential"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleEditor)
+	if err != nil || !ok {
+		return err
+	}
+
+	deleted, err := db.DynDNSCredentialDelete(Database, r.ZoneID, r.CredentialID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !deleted {
+		return response(c, http.StatusOK, "Dynamic DNS credential doesn't exist, nothing to delete", nil)
+	}
+	audit(c, user, db.AuditEntry{Action: "record.dyndns.delete", ZoneID: r.ZoneID}, r.CredentialID, nil)
+
+	return response(c, http.StatusOK, "Dynamic DNS credential deleted", nil)
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// nicUpdate makes a dyndns2 request and returns the status code and plain text response
+func nicUpdate(t *testing.T, app *fiber.App, query, username, password string) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, "/nic/update?"+query, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	resp, err := app.Test(req)
+	assert.Nil(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestRoutesDynDNS(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	record := &db.Record{Type: "A", Label: "home", Value: "192.0.2.1", TTL: 300, ZoneID: zone.ID}
+	err = db.RecordAdd(Database, record)
+	assert.Nil(t, err)
+
+	// The password is returned once
+	content = fmt.Sprintf(`{"zone": "%s", "record": "%s"}`, zone.ID, record.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/records/dyndns", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	password := apiResp.Data["password"].(string)
+	credential := apiResp.Data["credential"].(map[string]interface{})
+	username := credential["username"].(string)
+
+	status, body := nicUpdate(t, app, "hostname=home.example.com&myip=192.0.2.2", username, "wrong password")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "badauth", body)
+
+	_, body = nicUpdate(t, app, "hostname=other.example.com&myip=192.0.2.2", username, password)
+	assert.Equal(t, "nohost", body)
+	_, body = nicUpdate(t, app, "hostname=home.example.com&myip=2001:db8::1", username, password)
+	assert.Equal(t, "nohost", body)
+
+	serial := zone.Serial
+	_, body = nicUpdate(t, app, "hostname=home.example.com&myip=192.0.2.2,2001:db8::1", username, password)
+	assert.Equal(t, "good 192.0.2.2", body)
+	updated, err := db.RecordFindByID(Database, record.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.0.2.2", updated.Value)
+	zone, err = db.ZoneFindByID(Database, zone.ID)
+	assert.Nil(t, err)
+	assert.Greater(t, zone.Serial, serial)
+
+	_, body = nicUpdate(t, app, "hostname=HOME.example.com&myip=192.0.2.2", username, password)
+	assert.Equal(t, "nochg 192.0.2.2", body)
+
+	// Records that are no longer A or AAAA records can't be updated
+	changed := *updated
+	changed.Type, changed.Value = "TXT", `"home"`
+	err = db.RecordUpdate(Database, &changed)
+	assert.Nil(t, err)
+	_, body = nicUpdate(t, app, "hostname=home.example.com&myip=2001:db8::1", username, password)
+	assert.Equal(t, "nohost", body)
+	err = db.RecordUpdate(Database, updated)
+	assert.Nil(t, err)
+
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/dyndns", "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["credentials"].([]interface{})))
+
+	content = fmt.Sprintf(`{"zone": "%s", "credential": "%s"}`, zone.ID, credential["id"])
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/dns/records/dyndns", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, "Dynamic DNS credential deleted", apiResp.Message)
+
+	status, body = nicUpdate(t, app, "hostname=home.example.com&myip=192.0.2.3", username, password)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "badauth", body)
+}
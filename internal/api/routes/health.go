@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/api/health"
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+// ZoneHealth handles a GET request to get the delegation and DNSSEC health of a zone, checking it again if refresh is set
+func ZoneHealth(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if zone.PendingVerification {
+		return response(c, http.StatusBadRequest, "Zone is pending verification", nil)
+	}
+
+	if c.Query("refresh") == "true" {
+		if err := db.ZoneHealthSet(Database, health.Check(resolver.Default, zone)); err != nil {
+			return internalServerError(c, err)
+		}
+	}
+
+	zoneHealth, err := db.ZoneHealthGet(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Zone health retrieved", map[string]interface{}{
+		"health":      zoneHealth,
+		"nameservers": zone.Nameservers(),
+		"ds":          zone.DNSSEC.DSRecordString,
+	})
+}
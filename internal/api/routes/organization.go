@@ -0,0 +1,293 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// checkOrganizationAuthorization checks if the current user has at least the given role in an organization
+func checkOrganizationAuthorization(c *fiber.Ctx, orgID string, role string) (*db.User, bool, error) {
+	user, err := findUser(c)
+	if err != nil {
+		return nil, false, internalServerError(c, err)
+	}
+	if user == nil {
+		return nil, false, response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
+	}
+
+	// Check enabled group
+	if !util.StrSliceContains(user.Groups, db.GroupEnabled) {
+		return user, false, response(c, http.StatusForbidden, errUserDisabled, nil)
+	}
+
+	// API keys restricted to zones can't manage organizations
+	if user.Key != nil && len(user.Key.Zones) > 0 {
+		return user, false, response(c, http.StatusForbidden, "Forbidden, this API key is restricted to specific zones", nil)
+	}
+
+	// Allow admins access to all organizations
+	admin, err := db.UserAdminAuthorized(Database, user)
+	if err != nil {
+		return user, false, internalServerError(c, err)
+	}
+	if admin {
+		return user, true, nil
+	}
+
+	userRole, err := db.OrganizationUserRole(Database, orgID, user.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrOrganizationNotFound) {
+			return user, false, response(c, http.StatusForbidden, "Forbidden", nil)
+		}
+		return user, false, internalServerError(c, err)
+	}
+	if !db.RoleAtLeast(userRole, role) {
+		return user, false, response(c, http.StatusForbidden, fmt.Sprintf("Forbidden, this action requires the %s role", role), nil)
+	}
+
+	return user, true, nil
+}
+
+// OrganizationAdd handles a POST request to create an organization
+func OrganizationAdd(c *fiber.Ctx) error {
+	var o db.Organization
+	if err := c.BodyParser(&o); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if err := validation.Validate(o); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
+	}
+
+	user, err := findUser(c)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if user == nil {
+		return response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
+	}
+	if !util.StrSliceContains(user.Groups, db.GroupEnabled) {
+		return response(c, http.StatusForbidden, errUserDisabled, nil)
+	}
+
+	org, err := db.OrganizationAdd(Database, o.Name, user.Email)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "organization.add", OrganizationID: org.ID}, nil, org)
+
+	return response(c, http.StatusOK, "Organization added", map[string]interface{}{"id": org.ID})
+}
+
+// OrganizationList handles a GET request to list organizations for a user
+func OrganizationList(c *fiber.Ctx) error {
+	user, err := findUser(c)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if user == nil {
+		return response(c, http.StatusUnauthorized, "Authentication credentials must be provided", nil)
+	}
+
+	orgs, err := db.OrganizationUserGetOrganizations(Database, user.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Organizations retrieved", map[string]interface{}{"organizations": orgs})
+}
+
+// OrganizationDelete handles a DELETE request to delete an organization
+func OrganizationDelete(c *fiber.Ctx) error {
+	var o struct {
+		ID string `json:"organization"`
+	}
+	if err := c.BodyParser(&o); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	user, ok, err := checkOrganizationAuthorization(c, o.ID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.OrganizationDelete(Database, o.ID); err != nil {
+		if errors.Is(err, db.ErrOrganizationOwnsZones) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "organization.delete", OrganizationID: o.ID}, nil, nil)
+
+	return response(c, http.StatusOK, "Organization deleted", nil)
+}
+
+// OrganizationUserAdd handles a PUT request to add a user to an organization
+func OrganizationUserAdd(c *fiber.Ctx) error {
+	var o struct {
+		ID        string `json:"organization"`
+		UserEmail string `json:"user"`
+		Role      string `json:"role"`
+	}
+	if err := c.BodyParser(&o); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	user, ok, err := checkOrganizationAuthorization(c, o.ID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.OrganizationUserAdd(Database, o.ID, o.UserEmail, o.Role); err != nil {
+		if errors.Is(err, db.ErrUserExistingOrganizationMember) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrUserNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrInvalidRole) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else {
+			return internalServerError(c, err)
+		}
+	}
+
+	audit(c, user, db.AuditEntry{Action: "organization.user.add", OrganizationID: o.ID, TargetUser: o.UserEmail}, nil, map[string]string{"role": o.Role})
+
+	return response(c, http.StatusOK, "User added to organization", nil)
+}
+
+// OrganizationUserDelete handles a DELETE request to remove a user from an organization
+func OrganizationUserDelete(c *fiber.Ctx) error {
+	var o struct {
+		ID        string `json:"organization"`
+		UserEmail string `json:"user"`
+	}
+	if err := c.BodyParser(&o); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Any member can remove themselves
+	user, ok, err := checkOrganizationAuthorization(c, o.ID, db.RoleViewer)
+	if err != nil || !ok {
+		return err
+	}
+	if user.Email != o.UserEmail {
+		if _, ok, err := checkOrganizationAuthorization(c, o.ID, db.RoleOwner); err != nil || !ok {
+			return err
+		}
+	}
+
+	if err := db.OrganizationUserDelete(Database, o.ID, o.UserEmail); err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrLastOrganizationOwner) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else {
+			return internalServerError(c, err)
+		}
+	}
+
+	audit(c, user, db.AuditEntry{Action: "organization.user.delete", OrganizationID: o.ID, TargetUser: o.UserEmail}, nil, nil)
+
+	return response(c, http.StatusOK, "User removed from organization", nil)
+}
+
+// OrganizationUserSetRole handles a PUT request to change an organization member's role
+func OrganizationUserSetRole(c *fiber.Ctx) error {
+	var o struct {
+		ID        string `json:"organization"`
+		UserEmail string `json:"user"`
+		Role      string `json:"role"`
+	}
+	if err := c.BodyParser(&o); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	user, ok, err := checkOrganizationAuthorization(c, o.ID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.OrganizationUserSetRole(Database, o.ID, o.UserEmail, o.Role); err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrInvalidRole) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else if errors.Is(err, db.ErrLastOrganizationOwner) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		} else {
+			return internalServerError(c, err)
+		}
+	}
+
+	audit(c, user, db.AuditEntry{Action: "organization.user.role", OrganizationID: o.ID, TargetUser: o.UserEmail}, nil, map[string]string{"role": o.Role})
+
+	return response(c, http.StatusOK, "User role updated", nil)
+}
+
+// ZoneSetOrganization handles a PUT request to transfer a zone to an organization
+func ZoneSetOrganization(c *fiber.Ctx) error {
+	var z struct {
+		ZoneID         string `json:"zone"`
+		OrganizationID string `json:"organization"`
+	}
+	if err := c.BodyParser(&z); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if z.OrganizationID == "" {
+		return response(c, http.StatusBadRequest, "Organization must be provided", nil)
+	}
+
+	// The user must own both the zone and the organization
+	user, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+	if _, ok, err := checkOrganizationAuthorization(c, z.OrganizationID, db.RoleOwner); err != nil || !ok {
+		return err
+	}
+
+	if err := db.ZoneSetOrganization(Database, z.ZoneID, z.OrganizationID); err != nil {
+		if errors.Is(err, db.ErrOrganizationNotFound) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "zone.organization.set", ZoneID: z.ZoneID, OrganizationID: z.OrganizationID}, nil, nil)
+
+	return response(c, http.StatusOK, "Zone added to organization", nil)
+}
+
+// ZoneDeleteOrganization handles a DELETE request to remove a zone from its organization
+func ZoneDeleteOrganization(c *fiber.Ctx) error {
+	var z struct {
+		ZoneID string `json:"zone"`
+	}
+	if err := c.BodyParser(&z); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	user, ok, err := checkUserAuthorizationByID(c, z.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.ZoneSetOrganization(Database, z.ZoneID, ""); err != nil {
+		if errors.Is(err, db.ErrLastZoneOwner) {
+			return response(c, http.StatusBadRequest, "Zone has no direct owners, add an owner to the zone before removing it from its organization", nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "zone.organization.delete", ZoneID: z.ZoneID}, nil, nil)
+
+	return response(c, http.StatusOK, "Zone removed from organization", nil)
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesOrganizations(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com and user2@example.com
+	tokens := map[string]string{}
+	for _, email := range []string{"user1@example.com", "user2@example.com"} {
+		content := fmt.Sprintf(`{"email":"%s", "password":"example-users-password'"}`, email)
+		httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+		assert.True(t, apiResp.Success)
+
+		u, err := db.UserFindByEmail(Database, email)
+		assert.Nil(t, err)
+		err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+		assert.Nil(t, err)
+
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+		tokens[email] = apiResp.Data["token"].(string)
+	}
+	user1Auth := map[string]string{"Authorization": "Token " + tokens["user1@example.com"]}
+	user2Auth := map[string]string{"Authorization": "Token " + tokens["user2@example.com"]}
+
+	// Create an organization
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/organizations", `{"name":"Example"}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	orgID := apiResp.Data["id"].(string)
+
+	// Add the zone and transfer it to the organization
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+	content := fmt.Sprintf(`{"zone":"%s", "organization":"%s"}`, zone.ID, orgID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/organization", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// user2 can't access the zone or manage the organization
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	content = fmt.Sprintf(`{"organization":"%s", "user":"user2@example.com", "role":"owner"}`, orgID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/organizations/user", content, user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// Add user2 to the organization as a viewer
+	content = fmt.Sprintf(`{"organization":"%s", "user":"user2@example.com", "role":"viewer"}`, orgID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/organizations/user", content, user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// user2 can list the organization and read the zone's records, but not add records
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/organizations", "", user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["organizations"].([]interface{})))
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// The organization can't be deleted while it owns zones
+	httpResp, _, err = testReq(app, http.MethodDelete, "/organizations", fmt.Sprintf(`{"organization":"%s"}`, orgID), user1Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// user2 leaves the organization and loses access to the zone
+	content = fmt.Sprintf(`{"organization":"%s", "user":"user2@example.com"}`, orgID)
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/organizations/user", content, user2Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/records/"+zone.ID, "", user2Auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// Remove the zone from the organization and delete the organization
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/dns/zones/organization", fmt.Sprintf(`{"zone":"%s"}`, zone.ID), user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/organizations", fmt.Sprintf(`{"organization":"%s"}`, orgID), user1Auth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"github.com/packetframe/api/internal/api/validation"
 	"github.com/packetframe/api/internal/common/db"
 	"github.com/packetframe/api/internal/common/util"
 )
@@ -24,6 +26,7 @@ var routes = []*route{
 
 	// Authentication
 	{Path: "/user/login", Method: http.MethodPost, Handler: UserLogin, Description: "Log a user in", InvalidJSONTest: true},
+	{Path: "/user/login/totp", Method: http.MethodPost, Handler: UserLoginTOTP, Description: "Complete a login with a two-factor authentication code", InvalidJSONTest: true},
 	{Path: "/user/signup", Method: http.MethodPost, Handler: UserSignup, Description: "Create a new user account", InvalidJSONTest: true},
 	{Path: "/user/logout", Method: http.MethodPost, Handler: UserLogout, Description: "Log a user out", InvalidJSONTest: false},
 
@@ -33,25 +36,84 @@ var routes = []*route{
 	{Path: "/user/info", Method: http.MethodGet, Handler: UserInfo, Description: "Get user info", InvalidJSONTest: false},
 	{Path: "/user/request_password_reset", Method: http.MethodPost, Handler: UserRequestPasswordReset, Description: "Request a password reset", InvalidJSONTest: true},
 	{Path: "/user/confirm_password_reset", Method: http.MethodPost, Handler: UserConfirmPasswordReset, Description: "Confirm a requested password reset", InvalidJSONTest: true},
+	{Path: "/user/totp", Method: http.MethodPost, Handler: UserTOTPEnroll, Description: "Start two-factor authentication enrollment", InvalidJSONTest: false},
+	{Path: "/user/totp/confirm", Method: http.MethodPost, Handler: UserTOTPConfirm, Description: "Confirm two-factor authentication enrollment", InvalidJSONTest: true},
+	{Path: "/user/totp", Method: http.MethodDelete, Handler: UserTOTPDisable, Description: "Disable two-factor authentication", InvalidJSONTest: true},
+	{Path: "/user/sessions", Method: http.MethodGet, Handler: SessionList, Description: "List a user's active sessions", InvalidJSONTest: false},
+	{Path: "/user/sessions", Method: http.MethodDelete, Handler: SessionDelete, Description: "Revoke one or all other sessions", InvalidJSONTest: true},
+	{Path: "/user/keys", Method: http.MethodGet, Handler: APIKeyList, Description: "List a user's API keys", InvalidJSONTest: false},
+	{Path: "/user/keys", Method: http.MethodPost, Handler: APIKeyAdd, Description: "Create an API key", InvalidJSONTest: true},
+	{Path: "/user/keys", Method: http.MethodDelete, Handler: APIKeyDelete, Description: "Revoke an API key", InvalidJSONTest: true},
 
 	// Zone management
 	{Path: "/dns/zones", Method: http.MethodGet, Handler: ZoneList, Description: "List all DNS zones authorized for a user", InvalidJSONTest: false},
 	{Path: "/dns/zones", Method: http.MethodPost, Handler: ZoneAdd, Description: "Add a new DNS zone", InvalidJSONTest: true},
 	{Path: "/dns/zones", Method: http.MethodDelete, Handler: ZoneDelete, Description: "Delete a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/verify", Method: http.MethodPost, Handler: ZoneVerify, Description: "Verify ownership of a pending DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/soa", Method: http.MethodPut, Handler: ZoneSetSOA, Description: "Set the SOA timers, RNAME and nameservers of a DNS zone", InvalidJSONTest: true},
 	{Path: "/dns/zones/user", Method: http.MethodPut, Handler: ZoneUserAdd, Description: "Add a user to a DNS zone", InvalidJSONTest: true},
 	{Path: "/dns/zones/user", Method: http.MethodDelete, Handler: ZoneUserDelete, Description: "Remove a user from a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/user/role", Method: http.MethodPut, Handler: ZoneUserSetRole, Description: "Change a user's role in a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/:id/export", Method: http.MethodGet, Handler: ZoneExport, Description: "Export a DNS zone in BIND, JSON or CSV format", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/versions", Method: http.MethodGet, Handler: ZoneVersionList, Description: "List versions of a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/versions/diff", Method: http.MethodGet, Handler: ZoneVersionDiff, Description: "Compare two versions of a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/audit", Method: http.MethodGet, Handler: ZoneAuditList, Description: "List audit entries for a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/transfers", Method: http.MethodGet, Handler: ZoneTransfers, Description: "Get the transfer status of a secondary DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/peers", Method: http.MethodGet, Handler: TransferPeerList, Description: "List external secondaries allowed to transfer a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/peers", Method: http.MethodPost, Handler: TransferPeerAdd, Description: "Allow an external secondary to transfer a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/peers", Method: http.MethodDelete, Handler: TransferPeerDelete, Description: "Stop an external secondary from transferring a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/:id/update-keys", Method: http.MethodGet, Handler: UpdateKeyList, Description: "List TSIG keys allowed to change a DNS zone with DNS UPDATE", InvalidJSONTest: false},
+	{Path: "/dns/zones/update-keys", Method: http.MethodPost, Handler: UpdateKeyAdd, Description: "Add a TSIG key allowed to change a DNS zone with DNS UPDATE", InvalidJSONTest: true},
+	{Path: "/dns/zones/update-keys", Method: http.MethodDelete, Handler: UpdateKeyDelete, Description: "Remove a DNS UPDATE key from a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/:id/acme", Method: http.MethodGet, Handler: ACMECredentialList, Description: "List ACME credentials for a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/acme", Method: http.MethodPost, Handler: ACMECredentialAdd, Description: "Create a credential that can only publish ACME challenges in a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/acme", Method: http.MethodDelete, Handler: ACMECredentialDelete, Description: "Revoke an ACME credential", InvalidJSONTest: true},
+	{Path: "/dns/zones/secondary", Method: http.MethodPut, Handler: ZoneSetSecondary, Description: "Make a DNS zone a secondary of external primaries", InvalidJSONTest: true},
+	{Path: "/dns/zones/:id/health", Method: http.MethodGet, Handler: ZoneHealth, Description: "Get the delegation and DNSSEC health of a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/record-health", Method: http.MethodGet, Handler: RecordHealth, Description: "Get the health of a zone's health checked records", InvalidJSONTest: false},
+	{Path: "/dns/zones/:id/dnssec", Method: http.MethodGet, Handler: ZoneDNSSEC, Description: "Get the DS and DNSKEY records of a DNS zone", InvalidJSONTest: false},
+	{Path: "/dns/zones/dnssec/rollover", Method: http.MethodPost, Handler: ZoneDNSSECRolloverStart, Description: "Start a DNSSEC key rollover", InvalidJSONTest: true},
+	{Path: "/dns/zones/dnssec/rollover/confirm", Method: http.MethodPost, Handler: ZoneDNSSECRolloverConfirm, Description: "Finish a DNSSEC key rollover once the new DS record is live", InvalidJSONTest: true},
+	{Path: "/dns/zones/dnssec/rollover", Method: http.MethodDelete, Handler: ZoneDNSSECRolloverCancel, Description: "Cancel a DNSSEC key rollover", InvalidJSONTest: true},
+	{Path: "/dns/zones/rollback", Method: http.MethodPost, Handler: ZoneRollback, Description: "Restore a previous version of a DNS zone", InvalidJSONTest: true},
+	{Path: "/dns/zones/import", Method: http.MethodPost, Handler: ZoneImport, Description: "Import DNS records from a zone file", InvalidJSONTest: true},
+	{Path: "/dns/zones/organization", Method: http.MethodPut, Handler: ZoneSetOrganization, Description: "Transfer a DNS zone to an organization", InvalidJSONTest: true},
+	{Path: "/dns/zones/organization", Method: http.MethodDelete, Handler: ZoneDeleteOrganization, Description: "Remove a DNS zone from its organization", InvalidJSONTest: true},
+
+	// Organization management
+	{Path: "/organizations", Method: http.MethodGet, Handler: OrganizationList, Description: "List organizations", InvalidJSONTest: false},
+	{Path: "/organizations", Method: http.MethodPost, Handler: OrganizationAdd, Description: "Create an organization", InvalidJSONTest: true},
+	{Path: "/organizations", Method: http.MethodDelete, Handler: OrganizationDelete, Description: "Delete an organization", InvalidJSONTest: true},
+	{Path: "/organizations/user", Method: http.MethodPut, Handler: OrganizationUserAdd, Description: "Add a user to an organization", InvalidJSONTest: true},
+	{Path: "/organizations/user", Method: http.MethodDelete, Handler: OrganizationUserDelete, Description: "Remove a user from an organization", InvalidJSONTest: true},
+	{Path: "/organizations/user/role", Method: http.MethodPut, Handler: OrganizationUserSetRole, Description: "Change a user's role in an organization", InvalidJSONTest: true},
 
 	// Record management
 	{Path: "/dns/records/:id", Method: http.MethodGet, Handler: RecordList, Description: "List DNS records for a zone", InvalidJSONTest: false},
 	{Path: "/dns/records", Method: http.MethodPost, Handler: RecordAdd, Description: "Add a DNS record to a zone", InvalidJSONTest: true},
 	{Path: "/dns/records", Method: http.MethodDelete, Handler: RecordDelete, Description: "Delete a DNS record from a zone", InvalidJSONTest: true},
 	{Path: "/dns/records", Method: http.MethodPut, Handler: RecordUpdate, Description: "Update a DNS record", InvalidJSONTest: true},
+	{Path: "/dns/records/changeset", Method: http.MethodPost, Handler: RecordChangeset, Description: "Atomically apply a set of record changes to a zone", InvalidJSONTest: true},
+
+	{Path: "/dns/zones/:id/dyndns", Method: http.MethodGet, Handler: DynDNSCredentialList, Description: "List dynamic DNS credentials for a zone", InvalidJSONTest: false},
+	{Path: "/dns/records/dyndns", Method: http.MethodPost, Handler: DynDNSCredentialAdd, Description: "Create a dynamic DNS credential for an A or AAAA record", InvalidJSONTest: true},
+	{Path: "/dns/records/dyndns", Method: http.MethodDelete, Handler: DynDNSCredentialDelete, Description: "Revoke a dynamic DNS credential", InvalidJSONTest: true},
+
+	// Dynamic DNS
+	{Path: "/nic/update", Method: http.MethodGet, Handler: NicUpdate, Description: "Update an A or AAAA record with the dyndns2 protocol", InvalidJSONTest: false},
+
+	// ACME DNS-01 challenges, compatible with lego's httpreq provider
+	{Path: "/acme/present", Method: http.MethodPost, Handler: ACMEPresent, Description: "Publish an ACME DNS-01 challenge", InvalidJSONTest: true},
+	{Path: "/acme/cleanup", Method: http.MethodPost, Handler: ACMECleanup, Description: "Remove an ACME DNS-01 challenge", InvalidJSONTest: true},
 
 	// Admin
 	{Path: "/admin/user/list", Method: http.MethodGet, Handler: AdminUserList, Description: "Get a list of all users", InvalidJSONTest: false},
 	{Path: "/admin/user/groups", Method: http.MethodPut, Handler: AdminUserGroupAdd, Description: "Add a group to a user", InvalidJSONTest: false},
 	{Path: "/admin/user/groups", Method: http.MethodDelete, Handler: AdminUserGroupRemove, Description: "Remove a group from a user", InvalidJSONTest: false},
 	{Path: "/admin/user/impersonate", Method: http.MethodPost, Handler: AdminUserImpersonate, Description: "Log in as another user", InvalidJSONTest: false},
+	{Path: "/admin/audit", Method: http.MethodGet, Handler: AdminAuditList, Description: "List audit entries", InvalidJSONTest: false},
+	{Path: "/admin/settings", Method: http.MethodGet, Handler: AdminSettingsGet, Description: "Get global settings", InvalidJSONTest: false},
+	{Path: "/admin/settings", Method: http.MethodPut, Handler: AdminSettingsSet, Description: "Change global settings", InvalidJSONTest: true},
 
 	// Monitor
 	{Path: "/admin/status/targets", Method: http.MethodGet, Handler: MonitorTargets, Description: "Get target status", InvalidJSONTest: false},
@@ -101,6 +163,11 @@ func response(c *fiber.Ctx, status int, message string, data map[string]interfac
 
 // internalServerError logs and returns a 503 Internal Server Error
 func internalServerError(c *fiber.Ctx, err error) error {
+	// API key scope errors from findUser are returned as errors, but aren't server errors
+	if errors.Is(err, errAPIKeyReadOnly) {
+		return response(c, http.StatusForbidden, "Forbidden, "+err.Error(), nil)
+	}
+
 	fmt.Printf("503 Internal Server Error ---------------------- %s ----------------------\n", err)
 	sentry.CaptureException(err)
 	return response(c, http.StatusInternalServerError, "Internal Server Error", nil)
@@ -118,8 +185,8 @@ func Document() string {
 	return table
 }
 
-// checkUserAuthorizationByID checks if a user is authorized for a zone given a zone ID
-func checkUserAuthorizationByID(c *fiber.Ctx, zoneId string) (*db.User, bool, error) {
+// checkUserAuthorizationByID checks if a user is authorized for a zone with at least the given role given a zone ID
+func checkUserAuthorizationByID(c *fiber.Ctx, zoneId string, role string) (*db.User, bool, error) {
 	// Find user
 	user, err := findUser(c)
 	if err != nil {
@@ -134,19 +201,48 @@ func checkUserAuthorizationByID(c *fiber.Ctx, zoneId string) (*db.User, bool, er
 		return user, false, response(c, http.StatusForbidden, errUserDisabled, nil)
 	}
 
+	// Check if the API key is restricted to other zones
+	if user.Key != nil && !user.Key.AllowsZone(zoneId) {
+		return user, false, response(c, http.StatusForbidden, "Forbidden, this API key is not authorized for this zone", nil)
+	}
+
 	// Allow admins access to all zones
-	if util.StrSliceContains(user.Groups, db.GroupAdmin) {
+	admin, err := db.UserAdminAuthorized(Database, user)
+	if err != nil {
+		return user, false, internalServerError(c, err)
+	}
+	if admin {
 		return user, true, nil
 	}
 
 	// Check if user is authorized for zone
-	if err := db.ZoneUserAuthorized(Database, zoneId, user.ID); err != nil {
+	userRole, err := db.ZoneUserRole(Database, zoneId, user.ID)
+	if err != nil {
 		return user, false, response(c, http.StatusForbidden, "Forbidden", nil)
 	}
+	if !db.RoleAtLeast(userRole, role) {
+		return user, false, response(c, http.StatusForbidden, fmt.Sprintf("Forbidden, this action requires the %s role", role), nil)
+	}
 
 	return user, true, nil
 }
 
+// validationReason formats a list of validation errors as a human readable string
+func validationReason(errs []*validation.ErrorResponse) string {
+	var reasons []string
+	for _, e := range errs {
+		switch {
+		case e.Tag == "record": // Record parse errors are reported in the field name
+			reasons = append(reasons, strings.TrimPrefix(e.FailedField, "Record."))
+		case e.Value != "":
+			reasons = append(reasons, fmt.Sprintf("%s failed %s=%s validation", e.FailedField, e.Tag, e.Value))
+		default:
+			reasons = append(reasons, fmt.Sprintf("%s failed %s validation", e.FailedField, e.Tag))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
 // meta handles a GET request to get API metadata
 func meta(c *fiber.Ctx) error {
 	return response(c, http.StatusOK, "Metadata retrieved successfully", buildMetadata)
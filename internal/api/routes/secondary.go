@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// ZoneSetSecondary handles a PUT request to make a zone a secondary of external primaries, or a primary zone again if no primaries are set
+func ZoneSetSecondary(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID    string   `json:"zone"`
+		Primaries []string `json:"primaries"`
+		TSIG      struct {
+			Name      string `json:"name"`
+			Algorithm string `json:"algorithm"`
+			Secret    string `json:"secret"`
+		} `json:"tsig"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	before, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	key := db.TSIGKey{Name: r.TSIG.Name, Algorithm: r.TSIG.Algorithm, Secret: r.TSIG.Secret}
+	if err := db.ZoneSetSecondary(Database, r.ZoneID, r.Primaries, key); err != nil {
+		if errors.Is(err, db.ErrInvalidPrimaries) || errors.Is(err, db.ErrInvalidTSIGKey) || errors.Is(err, db.ErrTSIGKeyNameInUse) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	after, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.secondary", ZoneID: r.ZoneID},
+		map[string]interface{}{"kind": before.Kind, "primaries": before.Primaries, "tsig": before.TSIG},
+		map[string]interface{}{"kind": after.Kind, "primaries": after.Primaries, "tsig": after.TSIG})
+
+	return response(c, http.StatusOK, "Zone kind updated", map[string]interface{}{
+		"kind":      after.Kind,
+		"primaries": after.Primaries,
+		"tsig":      after.TSIG,
+	})
+}
+
+// ZoneTransfers handles a GET request to get the transfer status of a secondary zone on each edge node
+func ZoneTransfers(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !zone.IsSecondary() {
+		return response(c, http.StatusBadRequest, "Zone isn't a secondary zone", nil)
+	}
+
+	transfers, err := db.ZoneTransferList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Zone transfers retrieved", map[string]interface{}{
+		"primaries": zone.Primaries,
+		"transfers": transfers,
+	})
+}
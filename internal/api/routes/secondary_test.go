@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesZoneSecondary(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Populate suffixes slice. This normally happens in a go routine, but this is required for testing
+	Suffixes, err = db.SuffixList()
+	assert.Nil(t, err)
+
+	// Sign up, enable and log in user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/dns/zones", `{"zone":"example.com"}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	zone, err := db.ZoneFind(Database, "example.com")
+	assert.Nil(t, err)
+
+	// Primary zones have no transfer status
+	httpResp, _, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/transfers", "", userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Invalid TSIG keys are rejected
+	content = fmt.Sprintf(`{"zone": "%s", "primaries": ["192.0.2.1"], "tsig": {"name": "transfer.example.com", "algorithm": "hmac-md4", "secret": "c2VjcmV0"}}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPut, "/dns/zones/secondary", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	content = fmt.Sprintf(`{"zone": "%s", "primaries": ["192.0.2.1", "[2001:db8::1]:5353"], "tsig": {"name": "transfer.example.com", "algorithm": "hmac-sha256", "secret": "c2VjcmV0"}}`, zone.ID)
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/dns/zones/secondary", content, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, db.ZoneKindSecondary, apiResp.Data["kind"])
+	assert.Nil(t, apiResp.Data["tsig"].(map[string]interface{})["secret"])
+
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/dns/zones/"+zone.ID+"/transfers", "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 2, len(apiResp.Data["primaries"].([]interface{})))
+
+	// Records can't be added to secondary zones
+	content = fmt.Sprintf(`{"zone": "%s", "label": "@", "type": "A", "value": "192.0.2.1", "ttl": 300}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+}
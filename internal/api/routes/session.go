@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// sessionInfo stores a session as returned by the API
+type sessionInfo struct {
+	db.Session
+	Current bool `json:"current"`
+}
+
+// SessionList handles a GET request to list a user's active sessions
+func SessionList(c *fiber.Ctx) error {
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	sessions, err := db.SessionList(Database, user.ID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	var infos []sessionInfo
+	for _, s := range sessions {
+		infos = append(infos, sessionInfo{
+			Session: s,
+			Current: user.Session != nil && user.Session.ID == s.ID,
+		})
+	}
+
+	return response(c, http.StatusOK, "Sessions retrieved", map[string]interface{}{"sessions": infos})
+}
+
+// SessionDelete handles a DELETE request to revoke one session, or all sessions except the current one
+func SessionDelete(c *fiber.Ctx) error {
+	var s struct {
+		ID  string `json:"id"`
+		All bool   `json:"all"`
+	}
+	if err := c.BodyParser(&s); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if s.ID == "" && !s.All {
+		return response(c, http.StatusBadRequest, "Either a session ID or all must be provided", nil)
+	}
+
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	if s.All {
+		currentSessionID := ""
+		if user.Session != nil {
+			currentSessionID = user.Session.ID
+		}
+		if err := db.SessionDeleteAll(Database, user.ID, currentSessionID); err != nil {
+			return internalServerError(c, err)
+		}
+		audit(c, user, db.AuditEntry{Action: "user.session.delete_all", TargetUser: user.Email}, nil, nil)
+		return response(c, http.StatusOK, "All other sessions revoked", nil)
+	}
+
+	if err := db.SessionDelete(Database, user.ID, s.ID); err != nil {
+		if errors.Is(err, db.ErrSessionNotFound) {
+			return response(c, http.StatusNotFound, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.session.delete", TargetUser: user.Email}, map[string]string{"id": s.ID}, nil)
+
+	return response(c, http.StatusOK, "Session revoked", nil)
+}
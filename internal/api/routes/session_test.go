@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesSessions(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Sign up and enable user1@example.com
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+
+	// Log in three times
+	var sessionAuth []map[string]string
+	for i := 0; i < 3; i++ {
+		httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+		sessionAuth = append(sessionAuth, map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)})
+	}
+
+	// List sessions
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/user/sessions", "", sessionAuth[0])
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	sessions := apiResp.Data["sessions"].([]interface{})
+	assert.Equal(t, 3, len(sessions))
+
+	// Revoke the second session
+	user, err := db.UserFindByAuth(Database, strings.TrimPrefix(sessionAuth[1]["Authorization"], "Token "))
+	assert.Nil(t, err)
+	secondSessionID := user.Session.ID
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/user/sessions", fmt.Sprintf(`{"id":"%s"}`, secondSessionID), sessionAuth[0])
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, _, err = testReq(app, http.MethodGet, "/user/info", "", sessionAuth[1])
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// Logging out revokes the session server side
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/logout", "", sessionAuth[2])
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, _, err = testReq(app, http.MethodGet, "/user/info", "", sessionAuth[2])
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// Revoke all other sessions, keeping the current one
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/user/sessions", `{"all":true}`, sessionAuth[0])
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, apiResp, err = testReq(app, http.MethodGet, "/user/sessions", "", sessionAuth[0])
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Equal(t, 1, len(apiResp.Data["sessions"].([]interface{})))
+	assert.True(t, apiResp.Data["sessions"].([]interface{})[0].(map[string]interface{})["current"].(bool))
+}
@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// totpIssuer is the issuer shown in authenticator apps
+const totpIssuer = "Packetframe"
+
+// UserLoginTOTP handles a POST request to complete a login with a TOTP or recovery code
+func UserLoginTOTP(c *fiber.Ctx) error {
+	var l struct {
+		Email     string `json:"email" validate:"required,email"`
+		Challenge string `json:"challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+	if err := c.BodyParser(&l); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if err := validation.Validate(l); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
+	}
+
+	user, err := db.UserValidateLoginChallenge(Database, l.Email, l.Challenge)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidOrExpiredLoginChallenge) {
+			return response(c, http.StatusUnauthorized, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	valid, err := db.UserTOTPValidate(Database, user, l.Code)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !valid {
+		return response(c, http.StatusUnauthorized, db.ErrInvalidTOTPCode.Error(), nil)
+	}
+
+	return loginSuccess(c, user, "")
+}
+
+// UserTOTPEnroll handles a POST request to start TOTP enrollment
+func UserTOTPEnroll(c *fiber.Ctx) error {
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	secret, err := db.UserTOTPEnroll(Database, user.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrTOTPAlreadyEnabled) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.totp.enroll", TargetUser: user.Email}, nil, nil)
+
+	return response(c, http.StatusOK, "TOTP enrollment started, confirm with a code to enable two-factor authentication", map[string]interface{}{
+		"secret": secret,
+		"uri":    auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// UserTOTPConfirm handles a POST request to confirm TOTP enrollment
+func UserTOTPConfirm(c *fiber.Ctx) error {
+	var t struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := c.BodyParser(&t); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if err := validation.Validate(t); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
+	}
+
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	codes, err := db.UserTOTPConfirm(Database, user.ID, t.Code)
+	if err != nil {
+		if errors.Is(err, db.ErrTOTPAlreadyEnabled) || errors.Is(err, db.ErrTOTPNotEnrolled) || errors.Is(err, db.ErrInvalidTOTPCode) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.totp.confirm", TargetUser: user.Email}, nil, nil)
+
+	return response(c, http.StatusOK, "Two-factor authentication enabled, store the recovery codes now as they can't be shown again", map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// UserTOTPDisable handles a DELETE request to disable TOTP
+func UserTOTPDisable(c *fiber.Ctx) error {
+	var t struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := c.BodyParser(&t); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+	if err := validation.Validate(t); err != nil {
+		return response(c, http.StatusBadRequest, "Invalid JSON data", map[string]interface{}{"reason": err})
+	}
+
+	user, ok, err := checkSessionUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := db.UserTOTPDisable(Database, user.ID, t.Code); err != nil {
+		if errors.Is(err, db.ErrInvalidTOTPCode) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+
+	audit(c, user, db.AuditEntry{Action: "user.totp.disable", TargetUser: user.Email}, nil, nil)
+
+	return response(c, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// AdminSettingsGet handles a GET request to get global settings
+func AdminSettingsGet(c *fiber.Ctx) error {
+	ok, _, err := checkAdminUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	requireAdminTOTP, err := db.SettingGet(Database, db.SettingRequireAdminTOTP)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Settings retrieved successfully", map[string]interface{}{
+		"require_admin_totp": requireAdminTOTP == "true",
+	})
+}
+
+// AdminSettingsSet handles a PUT request to change global settings
+func AdminSettingsSet(c *fiber.Ctx) error {
+	ok, user, err := checkAdminUserAuth(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	var s struct {
+		RequireAdminTOTP bool `json:"require_admin_totp"`
+	}
+	if err := c.BodyParser(&s); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Prevent admins from locking themselves out
+	if s.RequireAdminTOTP && !user.TOTPEnabled {
+		return response(c, http.StatusBadRequest, "Enable two-factor authentication on your account before requiring it for admins", nil)
+	}
+
+	before, err := db.SettingGet(Database, db.SettingRequireAdminTOTP)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if err := db.SettingSet(Database, db.SettingRequireAdminTOTP, strconv.FormatBool(s.RequireAdminTOTP)); err != nil {
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "admin.settings"}, map[string]string{db.SettingRequireAdminTOTP: before}, map[string]string{db.SettingRequireAdminTOTP: strconv.FormatBool(s.RequireAdminTOTP)})
+
+	return response(c, http.StatusOK, "Settings updated successfully", nil)
+}
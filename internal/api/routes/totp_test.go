@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRoutesUserTOTP(t *testing.T) {
+	err := validation.Register()
+	assert.Nil(t, err)
+
+	Database, err = db.TestSetup()
+	assert.Nil(t, err)
+
+	app := fiber.New()
+	Register(app, map[string]interface{}{"version": "dev"})
+
+	// Sign up, enable and log in
+	content := `{"email":"user1@example.com", "password":"example-users-password'"}`
+	httpResp, apiResp, err := testReq(app, http.MethodPost, "/user/signup", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, httpResp.StatusCode)
+	u, err := db.UserFindByEmail(Database, "user1@example.com")
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupEnabled)
+	assert.Nil(t, err)
+	err = db.UserGroupAdd(Database, u.ID, db.GroupAdmin)
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	userAuth := map[string]string{"Authorization": "Token " + apiResp.Data["token"].(string)}
+
+	// Admins can't require TOTP without enabling it first
+	httpResp, _, err = testReq(app, http.MethodPut, "/admin/settings", `{"require_admin_totp":true}`, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// Enroll and confirm TOTP
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/totp", "", userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	secret := apiResp.Data["secret"].(string)
+	assert.Contains(t, apiResp.Data["uri"], secret)
+	code, err := auth.TOTPCode(secret, time.Now())
+	assert.Nil(t, err)
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/totp/confirm", fmt.Sprintf(`{"code":"%s"}`, code), userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	recoveryCodes := apiResp.Data["recovery_codes"].([]interface{})
+	assert.NotEmpty(t, recoveryCodes)
+
+	// Require TOTP for admins
+	httpResp, apiResp, err = testReq(app, http.MethodPut, "/admin/settings", `{"require_admin_totp":true}`, userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+
+	// Logging in now requires a second step
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.Nil(t, apiResp.Data["token"])
+	assert.True(t, apiResp.Data["totp_required"].(bool))
+	challenge := apiResp.Data["challenge"].(string)
+
+	// An invalid code is rejected
+	content = fmt.Sprintf(`{"email":"user1@example.com", "challenge":"%s", "code":"invalid"}`, challenge)
+	httpResp, _, err = testReq(app, http.MethodPost, "/user/login/totp", content, map[string]string{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// A recovery code completes the login
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login", `{"email":"user1@example.com", "password":"example-users-password'"}`, map[string]string{})
+	assert.Nil(t, err)
+	challenge = apiResp.Data["challenge"].(string)
+	content = fmt.Sprintf(`{"email":"user1@example.com", "challenge":"%s", "code":"%s"}`, challenge, recoveryCodes[0])
+	httpResp, apiResp, err = testReq(app, http.MethodPost, "/user/login/totp", content, map[string]string{})
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	assert.NotEmpty(t, apiResp.Data["token"])
+
+	// Disabling TOTP removes admin privileges while it's required
+	httpResp, apiResp, err = testReq(app, http.MethodDelete, "/user/totp", fmt.Sprintf(`{"code":"%s"}`, recoveryCodes[1]), userAuth)
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, httpResp.StatusCode, apiResp.Message)
+	httpResp, _, err = testReq(app, http.MethodGet, "/admin/user/list", "", userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	// Proxied records require admin privileges too
+	zone, err := db.ZoneAdd(Database, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	content = fmt.Sprintf(`{"zone": "%s", "label": "www", "type": "A", "value": "192.0.2.1", "ttl": 300, "proxy": true}`, zone.ID)
+	httpResp, _, err = testReq(app, http.MethodPost, "/dns/records", content, userAuth)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// TransferPeerList handles a GET request to list the external secondaries allowed to transfer a zone
+func TransferPeerList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	peers, err := db.TransferPeerList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "Transfer peers retrieved", map[string]interface{}{"peers": peers})
+}
+
+// TransferPeerAdd handles a POST request to allow an external secondary to transfer a zone
+func TransferPeerAdd(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID  string `json:"zone"`
+		Address string `json:"address"`
+		Notify  bool   `json:"notify"`
+		TSIG    struct {
+			Name      string `json:"name"`
+			Algorithm string `json:"algorithm"`
+			Secret    string `json:"secret"`
+		} `json:"tsig"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	key := db.TSIGKey{Name: r.TSIG.Name, Algorithm: r.TSIG.Algorithm, Secret: r.TSIG.Secret}
+	peer, err := db.TransferPeerAdd(Database, r.ZoneID, r.Address, r.Notify, key)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidAddress) || errors.Is(err, db.ErrInvalidTSIGKey) || errors.Is(err, db.ErrTSIGKeyNameInUse) ||
+			errors.Is(err, db.ErrTooManyTransferPeers) || errors.Is(err, db.ErrTransferPeerExists) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.peer.add", ZoneID: r.ZoneID}, nil, peer)
+
+	// The secret is only returned once so a generated secret can be configured on the peer
+	return response(c, http.StatusOK, "Transfer peer added", map[string]interface{}{
+		"peer":   peer,
+		"secret": peer.TSIG.Secret,
+	})
+}
+
+// TransferPeerDelete handles a DELETE request to stop an external secondary from transferring a zone
+func TransferPeerDelete(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+		PeerID string `json:"peer"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	deleted, err := db.TransferPeerDelete(Database, r.ZoneID, r.PeerID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !deleted {
+		return response(c, http.StatusOK, "Transfer peer doesn't exist, nothing to delete", nil)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.peer.delete", ZoneID: r.ZoneID}, r.PeerID, nil)
+
+	return response(c, http.StatusOK, "Transfer peer deleted", nil)
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// UpdateKeyList handles a GET request to list the TSIG keys allowed to change a zone with DNS UPDATE
+func UpdateKeyList(c *fiber.Ctx) error {
+	zoneID := c.Params("id")
+
+	// Check if user is authorized for zone
+	if _, ok, err := checkUserAuthorizationByID(c, zoneID, db.RoleViewer); err != nil || !ok {
+		return err
+	}
+
+	keys, err := db.UpdateKeyList(Database, zoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+
+	return response(c, http.StatusOK, "DNS UPDATE keys retrieved", map[string]interface{}{"keys": keys})
+}
+
+// UpdateKeyAdd handles a POST request to add a TSIG key allowed to change a zone with DNS UPDATE
+func UpdateKeyAdd(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID    string `json:"zone"`
+		Name      string `json:"name"`
+		Algorithm string `json:"algorithm"`
+		Secret    string `json:"secret"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	zone, err := db.ZoneFindByID(Database, r.ZoneID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if zone.IsSecondary() {
+		return response(c, http.StatusBadRequest, db.ErrSecondaryZone.Error(), nil)
+	}
+
+	key, err := db.UpdateKeyAdd(Database, r.ZoneID, db.TSIGKey{Name: r.Name, Algorithm: r.Algorithm, Secret: r.Secret})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidTSIGKey) || errors.Is(err, db.ErrUpdateKeyOutsideZone) || errors.Is(err, db.ErrUpdateKeyNameInUse) {
+			return response(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		return internalServerError(c, err)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.updatekey.add", ZoneID: r.ZoneID}, nil, key)
+
+	// The secret is only returned once so a generated secret can be configured on the client
+	return response(c, http.StatusOK, "DNS UPDATE key added", map[string]interface{}{
+		"key":    key,
+		"secret": key.TSIG.Secret,
+	})
+}
+
+// UpdateKeyDelete handles a DELETE request to remove a DNS UPDATE key from a zone
+func UpdateKeyDelete(c *fiber.Ctx) error {
+	var r struct {
+		ZoneID string `json:"zone"`
+		KeyID  string `json:"key"`
+	}
+	if err := c.BodyParser(&r); err != nil {
+		return response(c, http.StatusUnprocessableEntity, "Invalid request", nil)
+	}
+
+	// Check if user is authorized for zone
+	user, ok, err := checkUserAuthorizationByID(c, r.ZoneID, db.RoleOwner)
+	if err != nil || !ok {
+		return err
+	}
+
+	deleted, err := db.UpdateKeyDelete(Database, r.ZoneID, r.KeyID)
+	if err != nil {
+		return internalServerError(c, err)
+	}
+	if !deleted {
+		return response(c, http.StatusOK, "DNS UPDATE key doesn't exist, nothing to delete", nil)
+	}
+	audit(c, user, db.AuditEntry{Action: "zone.updatekey.delete", ZoneID: r.ZoneID}, r.KeyID, nil)
+
+	return response(c, http.StatusOK, "DNS UPDATE key deleted", nil)
+}
@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// exclusiveRRTypes can't share a name with any other record
+var exclusiveRRTypes = []string{"CNAME", "SCRIPT"}
+
+// addressRRTypes are served as A and AAAA records, which includes flattened ALIAS records
+var addressRRTypes = []string{"A", "AAAA", "ALIAS"}
+
+// RecordName returns the lowercase FQDN of a record's label within a zone
+func RecordName(zone string, label string) string {
+	zone = strings.ToLower(dns.Fqdn(zone))
+	label = strings.ToLower(label)
+	if label == "@" || label == zone {
+		return zone
+	}
+	if dns.IsFqdn(label) {
+		return label
+	}
+	return label + "." + zone
+}
+
+// policyName returns a record set policy for display
+func policyName(policy string) string {
+	if policy == "" {
+		return "none"
+	}
+	return policy
+}
+
+// sameValue checks if two records of the same type have equal RDATA
+func sameValue(a, b db.Record) bool {
+	if a.Type == "ALIAS" {
+		return strings.EqualFold(dns.Fqdn(a.Value), dns.Fqdn(b.Value))
+	}
+	rrA, errA := dns.NewRR(fmt.Sprintf(". %d IN %s %s", a.TTL, a.Type, a.Value))
+	rrB, errB := dns.NewRR(fmt.Sprintf(". %d IN %s %s", b.TTL, b.Type, b.Value))
+	if errA != nil || errB != nil || rrA == nil || rrB == nil {
+		return a.Value == b.Value
+	}
+	return dns.IsDuplicate(rrA, rrB)
+}
+
+// recordConflict returns a reason why two records at the same name can't coexist, or an empty string if they can
+func recordConflict(name string, changed, other db.Record) string {
+	if util.StrSliceContains(exclusiveRRTypes, changed.Type) || util.StrSliceContains(exclusiveRRTypes, other.Type) {
+		return fmt.Sprintf("%s record at %s conflicts with existing %s record, %s records can't coexist with other records", changed.Type, name, other.Type, strings.Join(exclusiveRRTypes, " and "))
+	}
+
+	// Proxied records are served as the proxy's A and AAAA records
+	if (changed.Proxy || other.Proxy) && util.StrSliceContains(addressRRTypes, changed.Type) && util.StrSliceContains(addressRRTypes, other.Type) {
+		return fmt.Sprintf("%s record at %s conflicts with existing %s record, proxied records can't coexist with other address records", changed.Type, name, other.Type)
+	}
+	if (changed.Type == "ALIAS") != (other.Type == "ALIAS") && util.StrSliceContains(addressRRTypes, changed.Type) && util.StrSliceContains(addressRRTypes, other.Type) {
+		return fmt.Sprintf("%s record at %s conflicts with existing %s record, ALIAS records can't coexist with A or AAAA records", changed.Type, name, other.Type)
+	}
+
+	// Geo and weighted records are answered by edge nodes, which are delegated the whole name
+	if (db.PerQueryPolicy(changed.Policy) || db.PerQueryPolicy(other.Policy)) && changed.Policy != other.Policy {
+		policy := changed.Policy
+		if !db.PerQueryPolicy(policy) {
+			policy = other.Policy
+		}
+		return fmt.Sprintf("%s record at %s conflicts with existing %s record, %s records can only coexist with other %s records", changed.Type, name, other.Type, policy, policy)
+	}
+
+	if changed.Type != other.Type {
+		return ""
+	}
+	if sameValue(changed, other) && strings.EqualFold(changed.Geo, other.Geo) {
+		return fmt.Sprintf("%s record at %s with value %s already exists", changed.Type, name, changed.Value)
+	}
+	if changed.TTL != other.TTL {
+		return fmt.Sprintf("%s record at %s has TTL %d but existing %s records at %s have TTL %d, all records in a set must have the same TTL", changed.Type, name, changed.TTL, other.Type, name, other.TTL)
+	}
+	if changed.Policy != other.Policy {
+		return fmt.Sprintf("%s record at %s has policy %s but existing %s records at %s have policy %s, all records in a set must have the same policy", changed.Type, name, policyName(changed.Policy), other.Type, name, policyName(other.Policy))
+	}
+	return ""
+}
+
+// belowDelegatedConflict returns a reason why a record can't be below a geo or weighted record's name or the reverse, as the name is delegated to edge nodes which don't serve names below it
+func belowDelegatedConflict(name string, changed db.Record, otherName string, other db.Record) string {
+	if db.PerQueryPolicy(other.Policy) && name != otherName && dns.IsSubDomain(otherName, name) {
+		return fmt.Sprintf("%s record at %s is below existing %s record at %s, names below %s records can't have records", changed.Type, name, other.Policy, otherName, other.Policy)
+	}
+	if db.PerQueryPolicy(changed.Policy) && name != otherName && dns.IsSubDomain(name, otherName) {
+		return fmt.Sprintf("%s %s record at %s is above existing %s record at %s, names below %s records can't have records", changed.Policy, changed.Type, name, other.Type, otherName, changed.Policy)
+	}
+	return ""
+}
+
+// RecordConflicts checks changed records against each other and the rest of a zone's records and returns a reason for each conflict.
+// Conflicts between two records in existing aren't reported, so records that predate these checks don't block unrelated changes.
+func RecordConflicts(zone string, existing, changed []db.Record) []string {
+	var conflicts []string
+	for i, record := range changed {
+		name := RecordName(zone, record.Label)
+		if record.Type == "CNAME" && name == strings.ToLower(dns.Fqdn(zone)) {
+			conflicts = append(conflicts, fmt.Sprintf("CNAME record at %s conflicts with the zone's SOA and NS records, use an ALIAS record at the zone apex instead", name))
+			continue
+		}
+		if db.PerQueryPolicy(record.Policy) && name == strings.ToLower(dns.Fqdn(zone)) {
+			conflicts = append(conflicts, fmt.Sprintf("%s %s record at %s conflicts with the zone's SOA and NS records, %s records can't be at the zone apex", record.Policy, record.Type, name, record.Policy))
+			continue
+		}
+
+		// Compare against existing records and the changed records before this one so each pair is only reported once
+		others := append(append([]db.Record{}, existing...), changed[:i]...)
+		for _, other := range others {
+			if other.ID != "" && other.ID == record.ID {
+				continue
+			}
+			otherName := RecordName(zone, other.Label)
+			if reason := belowDelegatedConflict(name, record, otherName, other); reason != "" {
+				conflicts = append(conflicts, reason)
+				break
+			}
+			if otherName != name {
+				continue
+			}
+			if reason := recordConflict(name, record, other); reason != "" {
+				conflicts = append(conflicts, reason)
+				break
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestRecordConflictsGeo(t *testing.T) {
+	existing := []db.Record{
+		{ID: "1", Label: "www", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"},
+	}
+
+	// The same address can answer several regions, but only once per region
+	assert.Empty(t, RecordConflicts("example.com.", existing, []db.Record{{Label: "www", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "country:US"}}))
+	assert.Empty(t, RecordConflicts("example.com.", existing, []db.Record{{Label: "www", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"}}))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "www", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "DEFAULT"}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "www", Type: "TXT", Value: `"example"`, TTL: 300}})))
+
+	// Geo names are delegated to edge nodes, so they can't be at the apex or have names below them
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", nil, []db.Record{{Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "api.www", Type: "A", Value: "192.0.2.1", TTL: 300}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", []db.Record{{ID: "2", Label: "a.b", Type: "TXT", Value: `"example"`, TTL: 300}}, []db.Record{{Label: "b", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"}})))
+	assert.Empty(t, RecordConflicts("example.com.", existing, []db.Record{{Label: "api", Type: "A", Value: "192.0.2.1", TTL: 300}}))
+}
+
+func TestRecordConflictsWeighted(t *testing.T) {
+	existing := []db.Record{
+		{ID: "1", Label: "lb", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyWeighted, Weight: 1},
+	}
+
+	// Weighted names are delegated to edge nodes like geo names
+	assert.Empty(t, RecordConflicts("example.com.", existing, []db.Record{{Label: "lb", Type: "A", Value: "192.0.2.2", TTL: 300, Policy: db.PolicyWeighted, Weight: 3}}))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "lb", Type: "TXT", Value: `"example"`, TTL: 300}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "lb", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", nil, []db.Record{{Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyWeighted, Weight: 1}})))
+	assert.Equal(t, 1, len(RecordConflicts("example.com.", existing, []db.Record{{Label: "api.lb", Type: "A", Value: "192.0.2.1", TTL: 300}})))
+}
+
+func TestRecordConflicts(t *testing.T) {
+	existing := []db.Record{
+		{ID: "1", Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300},
+		{ID: "2", Label: "www", Type: "CNAME", Value: "example.com.", TTL: 300},
+		{ID: "3", Label: "@", Type: "MX", Value: "10 mail.example.com.", TTL: 300},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		changed   []db.Record
+		conflicts int
+	}{
+		{"new A record in set", []db.Record{{Label: "@", Type: "A", Value: "192.0.2.2", TTL: 300}}, 0},
+		{"duplicate A record", []db.Record{{Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300}}, 1},
+		{"duplicate with different case and FQDN label", []db.Record{{Label: "example.com.", Type: "MX", Value: "10 MAIL.example.com.", TTL: 300}}, 1},
+		{"different TTL in set", []db.Record{{Label: "@", Type: "A", Value: "192.0.2.2", TTL: 600}}, 1},
+		{"A record at CNAME", []db.Record{{Label: "WWW", Type: "A", Value: "192.0.2.2", TTL: 300}}, 1},
+		{"CNAME at apex", []db.Record{{Label: "@", Type: "CNAME", Value: "example.net.", TTL: 300}}, 1},
+		{"ALIAS with A record", []db.Record{{Label: "@", Type: "ALIAS", Value: "example.net.", TTL: 300}}, 1},
+		{"proxied A record with A record", []db.Record{{Label: "@", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Proxy: true}}, 1},
+		{"different policy in set", []db.Record{{Label: "@", Type: "A", Value: "192.0.2.2", TTL: 300, Policy: db.PolicyFailover}}, 1},
+		{"geo record with other records", []db.Record{{Label: "@", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"}}, 1},
+		{"updated record doesn't conflict with itself", []db.Record{{ID: "1", Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300}}, 0},
+		{"conflict within changes", []db.Record{
+			{Label: "api", Type: "CNAME", Value: "example.net.", TTL: 300},
+			{Label: "api", Type: "TXT", Value: "\"hello\"", TTL: 300},
+		}, 1},
+	} {
+		assert.Equalf(t, tc.conflicts, len(RecordConflicts("example.com.", existing, tc.changed)), tc.name)
+	}
+}
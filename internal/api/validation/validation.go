@@ -2,6 +2,8 @@ package validation
 
 import (
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/miekg/dns"
@@ -11,7 +13,7 @@ import (
 )
 
 // Validation parameters
-var validRRTypes = []string{"SCRIPT", "A", "AAAA", "CNAME", "TXT", "MX", "SRV", "NS", "PTR"}
+var validRRTypes = []string{"SCRIPT", "A", "AAAA", "CNAME", "TXT", "MX", "SRV", "NS", "PTR", "CAA", "TLSA", "SSHFP", "DS", "HTTPS", "SVCB", "NAPTR", "LOC", "ALIAS"}
 
 // localValidator is the singleton validator used for all validations
 var localValidator *validator.Validate
@@ -44,13 +46,50 @@ func Register() error {
 	// Register validator for full Record type
 	localValidator.RegisterStructValidation(func(sl validator.StructLevel) {
 		record := sl.Current().Interface().(db.Record)
-		if record.Type != "SCRIPT" {
+		if record.Type == "ALIAS" {
+			// ALIAS isn't a real RR type, its value is the hostname to resolve
+			if _, ok := dns.IsDomainName(record.Value); !ok || record.Value == "" || record.Value == "." {
+				sl.ReportError(record.Value, "ALIAS target must be a hostname", "", "record", "")
+			}
+		} else if record.Type != "SCRIPT" {
 			rrString := fmt.Sprintf("%s %d IN %s %s", record.Label, record.TTL, record.Type, record.Value)
 			_, err := dns.NewRR(rrString) // This is only used to catch an error so ignore resulting RR
 			if err != nil {
 				sl.ReportError(record.Value, err.Error(), "", "record", "")
 			}
 		}
+
+		// Policies choose between addresses, which edge nodes health check
+		if record.Policy != "" && (record.Proxy || (record.Type != "A" && record.Type != "AAAA")) {
+			sl.ReportError(record.Policy, "record set policies are only supported for A and AAAA records that aren't proxied", "", "record", "")
+		}
+		if record.Policy == db.PolicyGeo {
+			if _, err := db.ParseGeo(record.Geo); err != nil {
+				sl.ReportError(record.Geo, err.Error(), "", "record", "")
+			}
+		} else if record.Geo != "" {
+			sl.ReportError(record.Geo, "geo matches require the geo record set policy", "", "record", "")
+		}
+		if check := record.HealthCheck; check.Configured() {
+			if record.Policy == "" || record.Policy == db.PolicyGeo {
+				sl.ReportError(check.Type, "health checks require a failover, weighted or multivalue record set policy", "", "record", "")
+			}
+			if ip := net.ParseIP(record.Value); ip != nil && !util.PublicIP(ip) {
+				sl.ReportError(record.Value, "health checks require a public address", "", "record", "")
+			}
+			switch check.Type {
+			case db.HealthCheckHTTP, db.HealthCheckHTTPS:
+				if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+					sl.ReportError(check.Path, "health check path must start with /", "", "record", "")
+				}
+			case db.HealthCheckTCP:
+				if check.Port == 0 {
+					sl.ReportError(check.Port, "TCP health checks require a port", "", "record", "")
+				}
+			default:
+				sl.ReportError(check.Type, "health check type must be http, https or tcp", "", "record", "")
+			}
+		}
 	}, db.Record{})
 
 	return nil // nil error
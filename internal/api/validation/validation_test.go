@@ -20,3 +20,43 @@ func TestValidate(t *testing.T) {
 	errors = Validate(u)
 	assert.Equal(t, 0, len(errors))
 }
+
+func TestValidateAlias(t *testing.T) {
+	err := Register()
+	assert.Nil(t, err)
+
+	r := &db.Record{Type: "ALIAS", Label: "@", Value: "example.net.", TTL: 300}
+	assert.Equal(t, 0, len(Validate(r)))
+
+	r = &db.Record{Type: "ALIAS", Label: "@", Value: "", TTL: 300}
+	assert.Equal(t, 1, len(Validate(r)))
+}
+
+func TestValidatePolicy(t *testing.T) {
+	err := Register()
+	assert.Nil(t, err)
+
+	check := db.HealthCheck{Type: db.HealthCheckHTTPS, Path: "/health"}
+	r := &db.Record{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: check}
+	assert.Equal(t, 0, len(Validate(r)))
+	r = &db.Record{Type: "AAAA", Label: "@", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "continent:EU"}
+	assert.Equal(t, 0, len(Validate(r)))
+
+	for _, r := range []*db.Record{
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: "random"},
+		{Type: "TXT", Label: "@", Value: `"example"`, TTL: 300, Policy: db.PolicyMultivalue},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyWeighted, Proxy: true},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, HealthCheck: check},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: db.HealthCheck{Type: db.HealthCheckTCP}},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: db.HealthCheck{Type: "icmp"}},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: db.HealthCheck{Type: db.HealthCheckHTTP, Path: "health"}},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "planet:earth"},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, Geo: "country:US"},
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default", HealthCheck: check},
+		{Type: "A", Label: "@", Value: "10.0.0.1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: check},
+		{Type: "A", Label: "@", Value: "169.254.169.254", TTL: 300, Policy: db.PolicyFailover, HealthCheck: check},
+		{Type: "AAAA", Label: "@", Value: "::1", TTL: 300, Policy: db.PolicyFailover, HealthCheck: check},
+	} {
+		assert.Equalf(t, 1, len(Validate(r)), "%+v", r)
+	}
+}
@@ -0,0 +1,84 @@
+package verification
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var ErrInvalidGlue = errors.New("vanity nameservers must only have addresses of the platform nameservers")
+
+// platformAddresses resolves the addresses of the platform nameservers
+func platformAddresses() ([]string, error) {
+	var addrs []string
+	for _, ns := range db.Nameservers {
+		nsAddrs, err := resolver.Addresses(ns)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, nsAddrs...)
+	}
+	return addrs, nil
+}
+
+// zoneAddresses returns the A and AAAA values of a name from a zone's own records, which is the glue for in-zone nameservers
+func zoneAddresses(database *gorm.DB, zone *db.Zone, name string) ([]string, error) {
+	records, err := db.RecordList(database, zone.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	label := db.RelativeLabel(name, zone.Zone)
+	var addrs []string
+	for _, record := range records {
+		if (record.Type == "A" || record.Type == "AAAA") && (strings.EqualFold(record.Label, label) || strings.EqualFold(dns.Fqdn(record.Label), name)) {
+			if ip := net.ParseIP(record.Value); ip != nil {
+				addrs = append(addrs, ip.String())
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// Glue checks that a zone's vanity nameservers resolve to the platform nameservers. In-zone nameservers are checked against the zone's own records, others are resolved.
+func Glue(database *gorm.DB, zone *db.Zone, nameservers []string) error {
+	vanity := (&db.Zone{ZoneNameservers: nameservers}).VanityNameservers()
+	if len(vanity) == 0 {
+		return nil
+	}
+
+	platform, err := platformAddresses()
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range vanity {
+		var addrs []string
+		if dns.IsSubDomain(zone.Zone, ns) {
+			addrs, err = zoneAddresses(database, zone, ns)
+		} else {
+			addrs, err = resolver.Addresses(ns)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(addrs) == 0 {
+			return fmt.Errorf("%w: %s has no addresses", ErrInvalidGlue, ns)
+		}
+		for _, addr := range addrs {
+			if !util.StrSliceContains(platform, addr) {
+				return fmt.Errorf("%w: %s has address %s", ErrInvalidGlue, ns, addr)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package verification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+func TestGlue(t *testing.T) {
+	shutdown, err := resolver.TestServer([]string{
+		"ns1.packetframe.com. 3600 IN A 192.0.2.1",
+		"ns2.packetframe.com. 3600 IN A 192.0.2.2",
+		"ns2.packetframe.com. 3600 IN AAAA 2001:db8::2",
+		"ns1.example.net. 3600 IN A 192.0.2.1",
+		"ns2.example.net. 3600 IN AAAA 2001:db8::2",
+		"ns3.example.net. 3600 IN A 198.51.100.1",
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+
+	zone := &db.Zone{Zone: "example.com."}
+
+	// Platform nameservers are always valid
+	assert.Nil(t, Glue(nil, zone, db.Nameservers))
+
+	// Vanity nameservers pointing at the platform nameservers
+	assert.Nil(t, Glue(nil, zone, []string{"ns1.example.net.", "ns2.example.net."}))
+
+	// Vanity nameservers pointing elsewhere or without addresses
+	assert.ErrorIs(t, Glue(nil, zone, []string{"ns1.example.net.", "ns3.example.net."}), ErrInvalidGlue)
+	assert.ErrorIs(t, Glue(nil, zone, []string{"ns1.example.net.", "ns4.example.net."}), ErrInvalidGlue)
+}
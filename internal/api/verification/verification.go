@@ -0,0 +1,68 @@
+package verification
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// Check checks if a zone is delegated to our nameservers or has its verification TXT record
+func Check(zone *db.Zone) (bool, error) {
+	nameservers, nsErr := resolver.DelegatedNS(zone.Zone)
+	for _, ns := range nameservers {
+		if util.StrSliceContains(db.Nameservers, strings.ToLower(ns)) {
+			return true, nil
+		}
+	}
+
+	name, value := zone.VerificationTXT()
+	txts, err := resolver.TXT(name)
+	if err != nil {
+		// Report the delegation error if neither lookup worked
+		if nsErr != nil {
+			return false, nsErr
+		}
+		return false, err
+	}
+	return util.StrSliceContains(txts, value), nil
+}
+
+// Verify checks a zone pending verification and marks it verified if the check passes
+func Verify(database *gorm.DB, zone *db.Zone) (bool, error) {
+	verified, err := Check(zone)
+	if err != nil || !verified {
+		return false, err
+	}
+	if err := db.ZoneSetVerified(database, zone.ID); err != nil {
+		return false, err
+	}
+	log.Infof("Verified zone %s", zone.Zone)
+	return true, nil
+}
+
+// Verifier runs a ticker to periodically verify pending zones and delete expired ones
+func Verifier(database *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		zones, err := db.ZoneListPendingVerification(database)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		for i := range zones {
+			if _, err := Verify(database, &zones[i]); err != nil {
+				log.Debugf("verifying zone %s: %s", zones[i].Zone, err)
+			}
+		}
+
+		if err := db.ZoneDeleteExpiredPending(database); err != nil {
+			log.Warn(err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package verification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+func TestCheck(t *testing.T) {
+	shutdown, err := resolver.TestServer([]string{
+		"com. 3600 IN NS ns.parent.test.",
+		"ns.parent.test. 3600 IN A 127.0.0.1",
+		"delegated.com. 3600 IN NS ns1.packetframe.com.",
+		"elsewhere.com. 3600 IN NS ns1.example.net.",
+		`_packetframe-verification.txt.com. 300 IN TXT "packetframe-verification=token"`,
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+
+	// Delegated to our nameservers
+	verified, err := Check(&db.Zone{Zone: "delegated.com.", VerificationToken: "token"})
+	assert.Nil(t, err)
+	assert.True(t, verified)
+
+	// Delegated elsewhere without a TXT record
+	verified, err = Check(&db.Zone{Zone: "elsewhere.com.", VerificationToken: "token"})
+	assert.Nil(t, err)
+	assert.False(t, verified)
+
+	// Verification TXT record present
+	verified, err = Check(&db.Zone{Zone: "txt.com.", VerificationToken: "token"})
+	assert.Nil(t, err)
+	assert.True(t, verified)
+
+	// Wrong token
+	verified, err = Check(&db.Zone{Zone: "txt.com.", VerificationToken: "other"})
+	assert.Nil(t, err)
+	assert.False(t, verified)
+}
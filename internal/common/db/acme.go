@@ -0,0 +1,210 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ACMEChallengeTTL is the TTL of published ACME challenge records. It's below the minimum record TTL so resolvers don't cache challenges between validation attempts.
+const ACMEChallengeTTL = 60
+
+// MaxACMEChallenges is the maximum number of ACME challenges published in a zone at once
+const MaxACMEChallenges = 32
+
+// ACMEChallengeExpiry is how long a challenge is published for if it isn't cleaned up, well beyond the time ACME servers take to validate it
+const ACMEChallengeExpiry = time.Hour
+
+var (
+	ErrInvalidACMEChallenge  = errors.New("invalid ACME challenge, name must be an _acme-challenge name in the zone and value a base64url encoded digest")
+	ErrTooManyACMEChallenges = errors.New("zones can have at most 32 ACME challenges at once")
+)
+
+// acmeValue matches base64url encoded key authorization digests
+var acmeValue = regexp.MustCompile(`^[A-Za-z0-9_-]{1,255}$`)
+
+// ACMECredential stores a username and password that can only publish ACME DNS-01 challenges in a zone. Only the SHA256 hash of the password is stored.
+type ACMECredential struct {
+	ID        string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	Username  string    `gorm:"uniqueIndex" json:"username"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ACMEChallenge stores an ACME DNS-01 challenge TXT record. Challenges are served alongside the zone's records but aren't versioned.
+type ACMEChallenge struct {
+	ID        string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	Name      string    `json:"name"` // Lowercase FQDN
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Record returns the challenge as a TXT record in a zone
+func (c ACMEChallenge) Record(zone string) Record {
+	return Record{
+		Type:   "TXT",
+		Label:  RelativeLabel(c.Name, zone),
+		Value:  fmt.Sprintf("%q", c.Value),
+		TTL:    ACMEChallengeTTL,
+		ZoneID: c.ZoneID,
+	}
+}
+
+// ACMECredentialAdd creates an ACME credential for a zone and returns the plaintext password, which can't be retrieved again
+func ACMECredentialAdd(db *gorm.DB, zoneID string) (*ACMECredential, string, error) {
+	username, password, hash, err := newBasicAuthCredential()
+	if err != nil {
+		return nil, "", err
+	}
+
+	credential := &ACMECredential{ZoneID: zoneID, Username: username, Hash: hash}
+	if err := db.Create(credential).Error; err != nil {
+		return nil, "", err
+	}
+	return credential, password, nil
+}
+
+// ACMECredentialFind finds an ACME credential by username and password and returns nil if the credentials are invalid
+func ACMECredentialFind(db *gorm.DB, username, password string) (*ACMECredential, error) {
+	var credentials []ACMECredential
+	if err := db.Limit(1).Find(&credentials, "username = ?", username).Error; err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 || !checkCredentialHash(credentials[0].Hash, password) {
+		return nil, nil
+	}
+	return &credentials[0], nil
+}
+
+// ACMECredentialList returns a zone's ACME credentials
+func ACMECredentialList(db *gorm.DB, zoneID string) ([]ACMECredential, error) {
+	var credentials []ACMECredential
+	err := db.Order("created_at").Find(&credentials, "zone_id = ?", zoneID).Error
+	return credentials, err
+}
+
+// ACMECredentialDelete revokes an ACME credential
+func ACMECredentialDelete(db *gorm.DB, zoneID, credentialID string) (bool, error) {
+	res := db.Where("id = ? AND zone_id = ?", credentialID, zoneID).Delete(&ACMECredential{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// normalizeACMEChallenge validates a challenge name and value in a zone and returns the lowercase FQDN
+func normalizeACMEChallenge(zone, name, value string) (string, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	if _, ok := dns.IsDomainName(name); !ok || !strings.HasPrefix(name, "_acme-challenge.") || !dns.IsSubDomain(strings.ToLower(dns.Fqdn(zone)), name) {
+		return "", ErrInvalidACMEChallenge
+	}
+	if !acmeValue.MatchString(value) {
+		return "", ErrInvalidACMEChallenge
+	}
+	return name, nil
+}
+
+// ACMEChallengeRecord validates a challenge name and value in a zone and returns the TXT record it's published as
+func ACMEChallengeRecord(zone *Zone, name, value string) (Record, error) {
+	name, err := normalizeACMEChallenge(zone.Zone, name, value)
+	if err != nil {
+		return Record{}, err
+	}
+	return ACMEChallenge{ZoneID: zone.ID, Name: name, Value: value}.Record(zone.Zone), nil
+}
+
+// ACMEChallengePresent publishes an ACME challenge in a zone. Publishing an existing challenge again does nothing.
+// Challenges older than ACMEChallengeExpiry are removed first, so clients that never clean up don't block the zone.
+func ACMEChallengePresent(db *gorm.DB, zone *Zone, name, value string) error {
+	name, err := normalizeACMEChallenge(zone.Zone, name, value)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Lock the zone so concurrent challenges can't exceed the limit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Zone{}, "id = ?", zone.ID).Error; err != nil {
+			return err
+		}
+
+		expired := tx.Where("zone_id = ? AND created_at < ?", zone.ID, time.Now().Add(-ACMEChallengeExpiry)).Delete(&ACMEChallenge{})
+		if expired.Error != nil {
+			return expired.Error
+		}
+
+		challenges, err := ACMEChallengeList(tx, zone.ID)
+		if err != nil {
+			return err
+		}
+		for _, challenge := range challenges {
+			if challenge.Name == name && challenge.Value == value {
+				if expired.RowsAffected > 0 {
+					return ZoneIncrementSerial(tx, zone.ID)
+				}
+				return nil
+			}
+		}
+		if len(challenges) >= MaxACMEChallenges {
+			return ErrTooManyACMEChallenges
+		}
+
+		if err := tx.Create(&ACMEChallenge{ZoneID: zone.ID, Name: name, Value: value}).Error; err != nil {
+			return err
+		}
+		// Bump the serial, which notifies edge nodes to publish the challenge without waiting for their next zone refresh
+		return ZoneIncrementSerial(tx, zone.ID)
+	})
+}
+
+// ACMEChallengeCleanup removes a published ACME challenge from a zone
+func ACMEChallengeCleanup(db *gorm.DB, zone *Zone, name, value string) (bool, error) {
+	name, err := normalizeACMEChallenge(zone.Zone, name, value)
+	if err != nil {
+		return false, err
+	}
+
+	var deleted bool
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("zone_id = ? AND name = ? AND value = ?", zone.ID, name, value).Delete(&ACMEChallenge{})
+		if res.Error != nil {
+			return res.Error
+		}
+		deleted = res.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		return ZoneIncrementSerial(tx, zone.ID)
+	})
+	return deleted, err
+}
+
+// ACMEChallengeList returns the ACME challenges published in a zone
+func ACMEChallengeList(db *gorm.DB, zoneID string) ([]ACMEChallenge, error) {
+	var challenges []ACMEChallenge
+	err := db.Order("created_at").Find(&challenges, "zone_id = ?", zoneID).Error
+	return challenges, err
+}
+
+// ACMEChallengeDeleteExpired removes challenges older than ACMEChallengeExpiry from all zones
+func ACMEChallengeDeleteExpired(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var zoneIDs []string
+		if err := tx.Model(&ACMEChallenge{}).Where("created_at < ?", time.Now().Add(-ACMEChallengeExpiry)).Distinct().Pluck("zone_id", &zoneIDs).Error; err != nil {
+			return err
+		}
+		for _, zoneID := range zoneIDs {
+			if err := tx.Where("zone_id = ? AND created_at < ?", zoneID, time.Now().Add(-ACMEChallengeExpiry)).Delete(&ACMEChallenge{}).Error; err != nil {
+				return err
+			}
+			if err := ZoneIncrementSerial(tx, zoneID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
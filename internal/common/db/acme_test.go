@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeACMEChallenge(t *testing.T) {
+	name, err := normalizeACMEChallenge("example.com.", "_acme-challenge.WWW.example.com", "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0")
+	assert.Nil(t, err)
+	assert.Equal(t, "_acme-challenge.www.example.com.", name)
+
+	for _, tc := range []struct{ name, value string }{
+		{"www.example.com.", "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"},
+		{"_acme-challenge.example.net.", "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"},
+		{"_acme-challenge.example.com.", ""},
+		{"_acme-challenge.example.com.", `"quoted" value`},
+	} {
+		_, err := normalizeACMEChallenge("example.com.", tc.name, tc.value)
+		assert.Equal(t, ErrInvalidACMEChallenge, err, tc.name+" "+tc.value)
+	}
+}
+
+func TestACMEChallengeRecord(t *testing.T) {
+	challenge := ACMEChallenge{ZoneID: "zone", Name: "_acme-challenge.www.example.com.", Value: "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"}
+	assert.Equal(t, Record{
+		Type:   "TXT",
+		Label:  "_acme-challenge.www",
+		Value:  `"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"`,
+		TTL:    ACMEChallengeTTL,
+		ZoneID: "zone",
+	}, challenge.Record("example.com."))
+}
+
+func TestACMEChallenges(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+
+	credential, password, err := ACMECredentialAdd(db, zone.ID)
+	assert.Nil(t, err)
+	found, err := ACMECredentialFind(db, credential.Username, password)
+	assert.Nil(t, err)
+	assert.Equal(t, zone.ID, found.ZoneID)
+	found, err = ACMECredentialFind(db, credential.Username, "wrong password")
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	// Presenting the same challenge twice publishes it once
+	err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", "value1")
+	assert.Nil(t, err)
+	err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", "value1")
+	assert.Nil(t, err)
+	err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", "value2")
+	assert.Nil(t, err)
+	challenges, err := ACMEChallengeList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(challenges))
+
+	updated, err := ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, zone.Serial+2, updated.Serial)
+
+	deleted, err := ACMEChallengeCleanup(db, zone, "_acme-challenge.example.com.", "value1")
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	deleted, err = ACMEChallengeCleanup(db, zone, "_acme-challenge.example.com.", "value1")
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+
+	// Challenges that were never cleaned up expire
+	for i := 0; i < MaxACMEChallenges-1; i++ {
+		err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", fmt.Sprintf("value%d", i+3))
+		assert.Nil(t, err)
+	}
+	err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", "value-over-limit")
+	assert.Equal(t, ErrTooManyACMEChallenges, err)
+	err = db.Model(&ACMEChallenge{}).Where("zone_id = ?", zone.ID).Update("created_at", time.Now().Add(-ACMEChallengeExpiry-time.Minute)).Error
+	assert.Nil(t, err)
+	err = ACMEChallengePresent(db, zone, "_acme-challenge.example.com.", "value-over-limit")
+	assert.Nil(t, err)
+	challenges, err = ACMEChallengeList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(challenges))
+
+	err = db.Model(&ACMEChallenge{}).Where("zone_id = ?", zone.ID).Update("created_at", time.Now().Add(-ACMEChallengeExpiry-time.Minute)).Error
+	assert.Nil(t, err)
+	err = ACMEChallengeDeleteExpired(db)
+	assert.Nil(t, err)
+	challenges, err = ACMEChallengeList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, challenges)
+
+	deleted, err = ACMECredentialDelete(db, zone.ID, credential.ID)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+}
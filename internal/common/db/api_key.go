@@ -0,0 +1,135 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKey stores a named API key. Only the SHA256 hash of the key is stored.
+type APIKey struct {
+	ID        string         `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID    string         `gorm:"index" json:"-"`
+	Name      string         `json:"name" validate:"required,min=1,max=64"`
+	Prefix    string         `json:"prefix"` // First characters of the key to help users identify it
+	Hash      string         `gorm:"uniqueIndex" json:"-"`
+	Zones     pq.StringArray `gorm:"type:text[]" json:"zones"` // Zone IDs the key is restricted to, or empty for all zones
+	ReadOnly  bool           `json:"read_only"`
+	ExpiresAt *time.Time     `json:"expires_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Expired checks if the key has expired
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// AllowsZone checks if the key is permitted to access a zone
+func (k *APIKey) AllowsZone(zoneID string) bool {
+	return len(k.Zones) == 0 || util.StrSliceContains(k.Zones, zoneID)
+}
+
+// APIKeyAdd creates a new API key for a user and returns the plaintext key, which can't be retrieved again
+func APIKeyAdd(db *gorm.DB, userID string, key *APIKey) (string, error) {
+	plaintext, err := auth.RandomString(48)
+	if err != nil {
+		return "", err
+	}
+	hash, err := util.SHA256(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	key.ID = ""
+	key.UserID = userID
+	key.Prefix = plaintext[:8]
+	key.Hash = hash
+	if key.Zones == nil {
+		key.Zones = []string{}
+	}
+	if err := db.Create(key).Error; err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// APIKeyList gets all API keys for a user
+func APIKeyList(db *gorm.DB, userID string) ([]APIKey, error) {
+	var keys []APIKey
+	if err := db.Order("created_at").Find(&keys, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// APIKeyDelete revokes one of a user's API keys
+func APIKeyDelete(db *gorm.DB, userID string, keyID string) error {
+	res := db.Delete(&APIKey{}, "id = ? AND user_id = ?", keyID, userID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// apiKeyFind finds an unexpired API key by its plaintext value and returns nil if no key exists
+func apiKeyFind(db *gorm.DB, plaintext string) (*APIKey, error) {
+	hash, err := util.SHA256(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKey
+	if err := db.Limit(1).Find(&keys, "hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 || keys[0].Expired() {
+		return nil, nil
+	}
+	return &keys[0], nil
+}
+
+// apiKeyMigrateLegacy moves plaintext API keys from the users table into unrestricted hashed API keys so existing clients keep working
+func apiKeyMigrateLegacy(db *gorm.DB) error {
+	var users []User
+	if err := db.Find(&users, "COALESCE(api_key, '') <> ''").Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		hash, err := util.SHA256(user.APIKey)
+		if err != nil {
+			return err
+		}
+		prefix := user.APIKey
+		if len(prefix) > 8 {
+			prefix = prefix[:8]
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&APIKey{
+				UserID: user.ID,
+				Name:   "Legacy API key",
+				Prefix: prefix,
+				Hash:   hash,
+				Zones:  []string{},
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&User{}).Where("id = ?", user.ID).Update("api_key", "").Error
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAPIKeyAddFindDelete tests creating, authenticating with and revoking API keys
+func TestAPIKeyAddFindDelete(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+
+	// Create a read-only key restricted to a zone
+	key := APIKey{Name: "ci", Zones: []string{"zone-id"}, ReadOnly: true}
+	plaintext, err := APIKeyAdd(db, user1.ID, &key)
+	assert.Nil(t, err)
+	assert.Equal(t, 48, len(plaintext))
+	assert.Equal(t, plaintext[:8], key.Prefix)
+	assert.NotContains(t, key.Hash, plaintext)
+
+	// Authenticate with the key
+	user, err := UserFindByAuth(db, plaintext)
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Equal(t, user1.ID, user.ID)
+	assert.NotNil(t, user.Key)
+	assert.True(t, user.Key.ReadOnly)
+	assert.True(t, user.Key.AllowsZone("zone-id"))
+	assert.False(t, user.Key.AllowsZone("other-zone-id"))
+
+	// Expired keys aren't accepted
+	expired := time.Now().Add(-time.Hour)
+	expiredPlaintext, err := APIKeyAdd(db, user1.ID, &APIKey{Name: "expired", ExpiresAt: &expired})
+	assert.Nil(t, err)
+	user, err = UserFindByAuth(db, expiredPlaintext)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+
+	keys, err := APIKeyList(db, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(keys))
+
+	// Revoke the key
+	err = APIKeyDelete(db, user1.ID, key.ID)
+	assert.Nil(t, err)
+	err = APIKeyDelete(db, user1.ID, key.ID)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	user, err = UserFindByAuth(db, plaintext)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+}
+
+// TestAPIKeyMigrateLegacy tests moving plaintext API keys into hashed API keys
+func TestAPIKeyMigrateLegacy(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	legacy := "legacy-plaintext-api-key-0123456789abcdefghijklm"
+	assert.Nil(t, db.Model(&User{}).Where("id = ?", user1.ID).Update("api_key", legacy).Error)
+
+	// Plaintext keys aren't accepted until they're migrated
+	user, err := UserFindByAuth(db, legacy)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+
+	assert.Nil(t, apiKeyMigrateLegacy(db))
+	user, err = UserFindByAuth(db, legacy)
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Equal(t, user1.ID, user.ID)
+	assert.Equal(t, "", user.APIKey)
+	assert.Equal(t, "legacy-p", user.Key.Prefix)
+
+	// Migrating again doesn't create another key
+	assert.Nil(t, apiKeyMigrateLegacy(db))
+	keys, err := APIKeyList(db, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(keys))
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditEntry stores a record of a mutating API call
+type AuditEntry struct {
+	ID             string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	ActorID        string    `gorm:"index" json:"-"`
+	Actor          string    `gorm:"index" json:"actor"`                // Email of the user who made the change
+	ImpersonatorID string    `json:"impersonator,omitempty"`            // ID of the admin impersonating the actor, if any
+	APIKeyID       string    `json:"api_key,omitempty"`                 // ID of the API key used, if any
+	Action         string    `gorm:"index" json:"action"`               // Dot separated action such as record.add
+	ZoneID         string    `gorm:"index" json:"zone,omitempty"`       // Target zone ID
+	RecordID       string    `json:"record,omitempty"`                  // Target record ID
+	OrganizationID string    `json:"organization,omitempty"`            // Target organization ID
+	TargetUser     string    `gorm:"index" json:"user,omitempty"`       // Email of the target user
+	Before         string    `gorm:"type:text" json:"before,omitempty"` // JSON encoded value before the change
+	After          string    `gorm:"type:text" json:"after,omitempty"`  // JSON encoded value after the change
+	IP             string    `json:"ip"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+// AuditFilter stores optional filters for listing audit entries
+type AuditFilter struct {
+	Actor      string
+	Action     string // Matches the action exactly or as a prefix when ending in a dot, such as record.
+	ZoneID     string
+	TargetUser string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditAdd adds an audit entry, encoding the before and after values as JSON
+func AuditAdd(db *gorm.DB, entry *AuditEntry, before interface{}, after interface{}) error {
+	for _, v := range []struct {
+		value interface{}
+		field *string
+	}{{before, &entry.Before}, {after, &entry.After}} {
+		if v.value == nil {
+			continue
+		}
+		encoded, err := json.Marshal(v.value)
+		if err != nil {
+			return err
+		}
+		*v.field = string(encoded)
+	}
+	return db.Create(entry).Error
+}
+
+// AuditList lists audit entries matching a filter, newest first
+func AuditList(db *gorm.DB, filter AuditFilter) ([]AuditEntry, error) {
+	tx := db.Model(&AuditEntry{}).Order("created_at DESC")
+	if filter.Actor != "" {
+		tx = tx.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		if filter.Action[len(filter.Action)-1] == '.' {
+			tx = tx.Where("action LIKE ?", filter.Action+"%")
+		} else {
+			tx = tx.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.ZoneID != "" {
+		tx = tx.Where("zone_id = ?", filter.ZoneID)
+	}
+	if filter.TargetUser != "" {
+		tx = tx.Where("target_user = ?", filter.TargetUser)
+	}
+	if !filter.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		tx = tx.Where("created_at < ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		tx = tx.Offset(filter.Offset)
+	}
+
+	var entries []AuditEntry
+	if err := tx.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuditAddList tests adding and filtering audit entries
+func TestAuditAddList(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = AuditAdd(db, &AuditEntry{Actor: "user1@example.com", Action: "record.add", ZoneID: "zone1"}, nil, Record{Label: "www", Type: "A", Value: "192.0.2.1"})
+	assert.Nil(t, err)
+	err = AuditAdd(db, &AuditEntry{Actor: "user1@example.com", Action: "record.delete", ZoneID: "zone1"}, Record{Label: "www"}, nil)
+	assert.Nil(t, err)
+	err = AuditAdd(db, &AuditEntry{Actor: "user2@example.com", Action: "zone.add", ZoneID: "zone2"}, nil, nil)
+	assert.Nil(t, err)
+
+	entries, err := AuditList(db, AuditFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+
+	entries, err = AuditList(db, AuditFilter{ZoneID: "zone1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	entries, err = AuditList(db, AuditFilter{Action: "record.add"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Contains(t, entries[0].After, `"label":"www"`)
+	assert.Equal(t, "", entries[0].Before)
+
+	entries, err = AuditList(db, AuditFilter{Action: "record."})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	entries, err = AuditList(db, AuditFilter{Actor: "user2@example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	entries, err = AuditList(db, AuditFilter{Since: time.Now().Add(time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	entries, err = AuditList(db, AuditFilter{Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
@@ -8,15 +8,21 @@ import (
 	"time"
 )
 
+// testDSN is the database used by tests
+const testDSN = "host=localhost user=api password=api dbname=api port=5432 sslmode=disable"
+
+// tables lists the tables of all models
+var tables = []string{"records", "users", "zones", "zone_versions", "zone_roles", "organizations", "organization_members", "api_keys", "settings", "sessions", "audit_entries", "zone_healths", "zone_transfers", "transfer_peers", "update_keys", "dyn_dns_credentials", "acme_credentials", "acme_challenges", "record_healths"}
+
 // TestSetup sets up the test environment by opening a database connection, dropping all tables, and inserting test data
 func TestSetup() (*gorm.DB, error) {
-	db, err := Connect("host=localhost user=api password=api dbname=api port=5432 sslmode=disable")
+	db, err := Connect(testDSN)
 	if err != nil {
 		return nil, err
 	}
 
 	// Drop tables
-	for _, table := range []string{"records", "users", "zones"} {
+	for _, table := range tables {
 		err = db.Exec("DELETE FROM " + table).Error
 		if err != nil {
 			return nil, err
@@ -38,37 +44,65 @@ func Open(dsn string) (*gorm.DB, error) {
 	})
 }
 
-// Connect opens a connection to the database and runs migrations
+// Connect opens a connection to the database and migrates its schema
 func Connect(dsn string) (*gorm.DB, error) {
 	db, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	// Select a table to check if the database has been initialized
-	if err := db.Exec("SELECT * FROM users;").Error; err != nil {
-		// Create UUID extension
-		err = db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`).Error
-		if err != nil {
-			// This seems to be a race condition in postgres
-			// ref: https://stackoverflow.com/questions/63104126/create-extention-if-not-exists-doesnt-really-check-if-extention-does-not-exis
-			log.Warn(err)
-		}
+	// Create UUID extension
+	err = db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`).Error
+	if err != nil {
+		// This seems to be a race condition in postgres
+		// ref: https://stackoverflow.com/questions/63104126/create-extention-if-not-exists-doesnt-really-check-if-extention-does-not-exis
+		log.Warn(err)
+	}
 
-		// Run schema migrations
-		if err := migrate(db); err != nil {
-			return nil, err
-		}
+	// Run schema migrations on every start, so existing databases get new tables, columns and indexes
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
+	// Hash API keys that were stored in plaintext
+	if err := apiKeyMigrateLegacy(db); err != nil {
+		return nil, err
 	}
 
 	return db, nil
 }
 
-// migrate runs migrations on all models
+// migrate runs migrations on all models. AutoMigrate only adds missing tables, columns and indexes, so it's safe to run on an existing database.
 func migrate(db *gorm.DB) error {
-	db.Exec(`CREATE ROLE readonly LOGIN PASSWORD 'readonly';`)
+	db.Exec(`DO $$ BEGIN CREATE ROLE readonly LOGIN PASSWORD 'readonly'; EXCEPTION WHEN duplicate_object THEN NULL; END $$;`)
+	if err := zoneMigrateVerifiedIndex(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&User{}, &Zone{}, &Record{}, &Credential{}, &ZoneVersion{}, &ZoneRole{}, &Organization{}, &OrganizationMember{}, &APIKey{}, &Setting{}, &Session{}, &AuditEntry{}, &ZoneHealth{}, &ZoneTransfer{}, &TransferPeer{}, &UpdateKey{}, &DynDNSCredential{}, &ACMECredential{}, &ACMEChallenge{}, &RecordHealth{}); err != nil {
+		return err
+	}
+
 	db.Exec(`GRANT SELECT ON TABLE zones TO readonly;`)
 	db.Exec(`GRANT SELECT ON TABLE records TO readonly;`)
 	db.Exec(`GRANT SELECT ON TABLE credentials TO readonly;`)
-	return db.AutoMigrate(&User{}, &Zone{}, &Record{}, &Credential{})
+
+	// Edge nodes report the transfer status of secondary zones and the health of health checked records
+	db.Exec(`GRANT SELECT, INSERT, UPDATE ON TABLE zone_transfers TO readonly;`)
+	db.Exec(`GRANT SELECT, INSERT, UPDATE ON TABLE record_healths TO readonly;`)
+	db.Exec(`GRANT SELECT ON TABLE transfer_peers TO readonly;`)
+	db.Exec(`GRANT SELECT ON TABLE acme_challenges TO readonly;`)
+	return nil
+}
+
+// zoneMigrateVerifiedIndex drops the unique zone name index of databases created before zone names were only unique among verified zones.
+// AutoMigrate then creates idx_zones_zone again without the constraint, alongside the partial idx_zones_verified_zone.
+func zoneMigrateVerifiedIndex(db *gorm.DB) error {
+	var unique bool
+	if err := db.Raw(`SELECT COALESCE(bool_or(i.indisunique), false) FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE c.relname = 'idx_zones_zone'`).Scan(&unique).Error; err != nil {
+		return err
+	}
+	if !unique {
+		return nil
+	}
+	return db.Exec(`DROP INDEX idx_zones_zone;`).Error
 }
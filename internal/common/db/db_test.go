@@ -2,11 +2,100 @@ package db
 
 import (
 	"testing"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/util"
 )
 
 func TestDbConnect(t *testing.T) {
 	_, err := TestSetup()
 	assert.Nil(t, err)
 }
+
+// baselineUser and baselineZone are the users and zones of the first schema, to test migrating existing databases
+type baselineUser struct {
+	ID                 string `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()"`
+	Email              string `gorm:"uniqueIndex"`
+	Refer              string
+	Groups             pq.StringArray `gorm:"type:text[]"`
+	PasswordHash       []byte
+	APIKey             string
+	Token              string
+	PasswordResetToken string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+type baselineZone struct {
+	ID         string `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()"`
+	Zone       string `gorm:"uniqueIndex"`
+	Serial     uint64
+	DNSSEC     DNSSECKey      `gorm:"embedded"`
+	Users      pq.StringArray `gorm:"type:text[]"`
+	UserEmails pq.StringArray `gorm:"type:text[]"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (baselineZone) TableName() string {
+	return "zones"
+}
+
+func TestDbConnectMigratesBaseline(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Replace the schema with the first schema
+	for _, table := range tables {
+		assert.Nil(t, db.Exec("DROP TABLE IF EXISTS "+table+" CASCADE").Error)
+	}
+	assert.Nil(t, db.AutoMigrate(&baselineUser{}, &baselineZone{}))
+	user := baselineUser{Email: "user1@example.com", Groups: []string{GroupEnabled}, APIKey: "legacy-api-key"}
+	assert.Nil(t, db.Create(&user).Error)
+	assert.Nil(t, db.Create(&baselineZone{Zone: "example.com.", Users: []string{user.ID}, UserEmails: []string{user.Email}}).Error)
+
+	db, err = Connect(testDSN)
+	assert.Nil(t, err)
+
+	// Legacy API keys are hashed into the new table
+	migrated, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	assert.Empty(t, migrated.APIKey)
+	keys, err := APIKeyList(db, user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(keys))
+	hash, err := util.SHA256("legacy-api-key")
+	assert.Nil(t, err)
+	assert.Equal(t, hash, keys[0].Hash)
+
+	// Existing zones are verified primary zones
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+	assert.False(t, zone.PendingVerification)
+	assert.Equal(t, ZoneKindPrimary, zone.Kind)
+
+	// Zone names are only unique among verified zones
+	var unique bool
+	assert.Nil(t, db.Raw(`SELECT indisunique FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE c.relname = 'idx_zones_zone'`).Scan(&unique).Error)
+	assert.False(t, unique)
+	assert.True(t, db.Migrator().HasIndex(&Zone{}, "idx_zones_verified_zone"))
+	for _, email := range []string{"user2@example.com", "user3@example.com"} {
+		assert.Nil(t, UserAdd(db, email, "password1", "example referrer"))
+		_, err = ZoneAdd(db, "example.net", email, 0)
+		assert.Nil(t, err)
+	}
+
+	// Connecting again doesn't change a migrated database
+	_, err = Connect(testDSN)
+	assert.Nil(t, err)
+	keys, err = APIKeyList(db, user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(keys))
+}
@@ -1,11 +1,30 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/miekg/dns"
 	"gorm.io/gorm"
 )
 
+// recordColumns are the columns written when a record is updated, so zero values such as an empty policy are stored too
+var recordColumns = []string{"type", "label", "value", "ttl", "proxy", "policy", "priority", "weight", "geo", "health_type", "health_port", "health_path", "health_host"}
+
+// sameColumns checks if two records have the same values in all of recordColumns
+func sameColumns(a, b Record) bool {
+	return a.Type == b.Type && a.Label == b.Label && a.Value == b.Value && a.TTL == b.TTL && a.Proxy == b.Proxy &&
+		a.Policy == b.Policy && a.Priority == b.Priority && a.Weight == b.Weight && a.Geo == b.Geo && a.HealthCheck == b.HealthCheck
+}
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+
+	errDryRun = errors.New("dry run") // Returned to roll back a dry run transaction
+)
+
 // Record stores a DNS record
 type Record struct {
 	ID     string `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
@@ -16,18 +35,102 @@ type Record struct {
 	Proxy  bool   `json:"proxy"`
 	ZoneID string `json:"zone"`
 
+	Policy      string      `json:"policy,omitempty" validate:"omitempty,oneof=failover weighted multivalue geo"` // Record set policy, empty to always answer with all records
+	Priority    uint32      `json:"priority,omitempty"`                                                           // Failover order, lowest first
+	Weight      uint32      `json:"weight,omitempty"`                                                             // Relative weight of weighted records
+	Geo         string      `json:"geo,omitempty"`                                                                // Clients answered by a geo record, see ParseGeo
+	HealthCheck HealthCheck `gorm:"embedded;embeddedPrefix:health_" json:"health_check" validate:"-"`
+
+	Data map[string]interface{} `gorm:"-" json:"data,omitempty" validate:"-"` // Structured fields as an alternative to Value for types such as CAA and HTTPS
+
 	Zone      Zone      `json:"-" validate:"-"` // Zone is populated by the database so will be zero value at record creation time
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
 }
 
+// RecordChange stores a single operation in a record changeset
+type RecordChange struct {
+	Op     string `json:"op"` // add, update or delete
+	Record Record `json:"record"`
+}
+
+// RecordDiff stores a record before and after a change
+type RecordDiff struct {
+	Op     string  `json:"op"`
+	Before *Record `json:"before,omitempty"`
+	After  *Record `json:"after,omitempty"`
+}
+
 // RecordAdd adds a new record to a zone
 func RecordAdd(db *gorm.DB, record *Record) error {
-	if err := ZoneIncrementSerial(db, record.ZoneID); err != nil {
-		return err
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return ZoneIncrementSerial(tx, record.ZoneID)
+	})
+}
+
+// RecordAddBatch adds a list of records to a zone in a single transaction and increments the zone serial once
+func RecordAddBatch(db *gorm.DB, zoneID string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range records {
+			records[i].ZoneID = zoneID
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return ZoneIncrementSerial(tx, zoneID)
+	})
+}
+
+// RecordFromRR converts a dns.RR to a Record with a label relative to the given zone
+func RecordFromRR(rr dns.RR, zone string) Record {
+	hdr := rr.Header()
+	return Record{
+		Type:  dns.Type(hdr.Rrtype).String(),
+		Label: RelativeLabel(hdr.Name, zone),
+		Value: strings.TrimPrefix(rr.String(), hdr.String()),
+		TTL:   hdr.Ttl,
 	}
+}
 
-	return db.Create(record).Error
+// RelativeLabel converts a FQDN to a label relative to a zone, or @ if the name is the zone apex
+func RelativeLabel(name, zone string) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	zone = strings.ToLower(dns.Fqdn(zone))
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(name, zone), ".")
+}
+
+// AbsoluteName qualifies a name relative to a zone with the zone's name, the same as a zone file's origin
+func AbsoluteName(name, zone string) string {
+	zone = strings.ToLower(dns.Fqdn(zone))
+	if name == "@" {
+		return zone
+	}
+	if dns.IsFqdn(name) {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "." + zone
+}
+
+// RecordFindByID finds a record by ID and returns nil if no record exists
+func RecordFindByID(db *gorm.DB, recordID string) (*Record, error) {
+	var records []Record
+	if err := db.Limit(1).Find(&records, "id = ?", recordID).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
 }
 
 // RecordList returns a list of DNS records for a zone
@@ -44,33 +147,115 @@ func RecordListAll(db *gorm.DB) ([]Record, error) {
 	return records, err
 }
 
-// RecordDelete deletes a DNS record from a zone
-func RecordDelete(db *gorm.DB, recordID string) (bool, error) {
-	// Find the record to get the zone ID
-	var r Record
-	if err := db.Find(&r, "id = ?", recordID).Error; err != nil {
-		return false, err
-	}
-
-	// Bump the zone serial
-	if err := ZoneIncrementSerial(db, r.ZoneID); err != nil {
-		return false, err
-	}
+// RecordDelete deletes a DNS record from a zone, records of other zones aren't deleted
+func RecordDelete(db *gorm.DB, zoneID, recordID string) (bool, error) {
+	var deleted bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		req := tx.Where("id = ? AND zone_id = ?", recordID, zoneID).Delete(&Record{})
+		if req.Error != nil {
+			return req.Error
+		}
+		deleted = req.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		if err := tx.Delete(&DynDNSCredential{}, "record_id = ?", recordID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&RecordHealth{}, "record_id = ?", recordID).Error; err != nil {
+			return err
+		}
 
-	req := db.Where("id = ?", recordID).Delete(&Record{})
-	return req.RowsAffected > 0, req.Error
+		// Bump the zone serial
+		return ZoneIncrementSerial(tx, zoneID)
+	})
+	return deleted, err
 }
 
-// RecordUpdate updates a DNS record
+// RecordUpdate replaces the fields of a DNS record listed in recordColumns, the record must be in updates.ZoneID
 func RecordUpdate(db *gorm.DB, updates *Record) error {
 	var currentRecord Record
-	if err := db.Find(&currentRecord, "id = ?", updates.ID).Error; err != nil {
-		return err
+	res := db.Limit(1).Find(&currentRecord, "id = ? AND zone_id = ?", updates.ID, updates.ZoneID)
+	if res.Error != nil {
+		return res.Error
 	}
-
-	if err := ZoneIncrementSerial(db, currentRecord.ZoneID); err != nil {
-		return err
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
 	}
 
-	return db.Model(&currentRecord).Updates(updates).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&currentRecord).Select(recordColumns).Updates(updates).Error; err != nil {
+			return err
+		}
+		return ZoneIncrementSerial(tx, currentRecord.ZoneID)
+	})
+}
+
+// RecordApplyChanges applies a changeset to a zone in a single transaction and increments the zone serial once.
+// If dryRun is true, the changes are rolled back and only the resulting diff is returned.
+func RecordApplyChanges(db *gorm.DB, zoneID string, changes []RecordChange, dryRun bool) ([]RecordDiff, error) {
+	var diff []RecordDiff
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			record := change.Record
+			record.ZoneID = zoneID
+
+			if change.Op == "add" {
+				record.ID = ""
+				if err := tx.Create(&record).Error; err != nil {
+					return err
+				}
+				diff = append(diff, RecordDiff{Op: change.Op, After: &record})
+				continue
+			}
+
+			// Update and delete operations must reference an existing record in this zone
+			var before Record
+			res := tx.Where("id = ? AND zone_id = ?", record.ID, zoneID).Limit(1).Find(&before)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("%w: %s", ErrRecordNotFound, record.ID)
+			}
+
+			switch change.Op {
+			case "update":
+				if err := tx.Model(&Record{ID: before.ID}).Select(recordColumns).Updates(&record).Error; err != nil {
+					return err
+				}
+				var after Record
+				if err := tx.First(&after, "id = ?", before.ID).Error; err != nil {
+					return err
+				}
+				diff = append(diff, RecordDiff{Op: change.Op, Before: &before, After: &after})
+			case "delete":
+				if err := tx.Delete(&Record{}, "id = ?", before.ID).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&DynDNSCredential{}, "record_id = ?", before.ID).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&RecordHealth{}, "record_id = ?", before.ID).Error; err != nil {
+					return err
+				}
+				diff = append(diff, RecordDiff{Op: change.Op, Before: &before})
+			default:
+				return fmt.Errorf("invalid changeset operation %s", change.Op)
+			}
+		}
+
+		if err := ZoneIncrementSerial(tx, zoneID); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return diff, nil
+	}
+	return diff, err
 }
@@ -3,6 +3,7 @@ package db
 import (
 	"testing"
 
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +15,7 @@ func TestRecordAddListDelete(t *testing.T) {
 	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
@@ -33,7 +34,12 @@ func TestRecordAddListDelete(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(records))
 
-	deleted, err := RecordDelete(db, records[0].ID)
+	// Records can't be deleted through another zone
+	deleted, err := RecordDelete(db, "00000000-0000-0000-0000-000000000000", records[0].ID)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = RecordDelete(db, example1.ID, records[0].ID)
 	assert.Nil(t, err)
 	assert.True(t, deleted)
 }
@@ -47,18 +53,21 @@ func TestRecordUpdate(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Add example1.com
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
 	assert.NotNil(t, example1)
 
 	err = RecordAdd(db, &Record{
-		Type:   "A",
-		Label:  "@",
-		Value:  "192.168.2.1",
-		TTL:    86400,
-		ZoneID: example1.ID,
+		Type:        "A",
+		Label:       "@",
+		Value:       "192.168.2.1",
+		TTL:         86400,
+		ZoneID:      example1.ID,
+		Policy:      PolicyWeighted,
+		Weight:      10,
+		HealthCheck: HealthCheck{Type: HealthCheckTCP, Port: 443},
 	})
 	assert.Nil(t, err)
 
@@ -67,12 +76,21 @@ func TestRecordUpdate(t *testing.T) {
 	assert.Equal(t, 1, len(records))
 	assert.Equal(t, "192.168.2.1", records[0].Value)
 
-	err = RecordUpdate(db, &Record{Value: "203.0.113.1", ID: records[0].ID})
+	// Records can't be updated through another zone
+	err = RecordUpdate(db, &Record{ID: records[0].ID, Type: "A", Label: "@", Value: "203.0.113.1", TTL: 86400, ZoneID: "00000000-0000-0000-0000-000000000000"})
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	// Updates replace all fields, including clearing the policy and health check
+	err = RecordUpdate(db, &Record{ID: records[0].ID, Type: "A", Label: "@", Value: "203.0.113.1", TTL: 86400, ZoneID: example1.ID})
 	assert.Nil(t, err)
 
 	records, err = RecordList(db, example1.ID)
 	assert.Nil(t, err)
 	assert.Equal(t, "203.0.113.1", records[0].Value)
+	assert.Equal(t, "", records[0].Policy)
+	assert.Equal(t, uint32(0), records[0].Weight)
+	assert.False(t, records[0].HealthCheck.Configured())
+	assert.Equal(t, example1.ID, records[0].ZoneID)
 }
 
 func TestScriptRecordAddListDelete(t *testing.T) {
@@ -83,7 +101,7 @@ func TestScriptRecordAddListDelete(t *testing.T) {
 	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
@@ -102,7 +120,119 @@ func TestScriptRecordAddListDelete(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(records))
 
-	deleted, err := RecordDelete(db, records[0].ID)
+	deleted, err := RecordDelete(db, example1.ID, records[0].ID)
 	assert.Nil(t, err)
 	assert.True(t, deleted)
 }
+
+func TestRecordAddBatch(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Add user1
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.NotNil(t, example1)
+	oldSerial := example1.Serial
+
+	err = RecordAddBatch(db, example1.ID, []Record{
+		{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300},
+		{Type: "AAAA", Label: "@", Value: "2001:db8::1", TTL: 300},
+		{Type: "TXT", Label: "www", Value: `"hello"`, TTL: 300},
+	})
+	assert.Nil(t, err)
+
+	records, err := RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+
+	// The serial should only be incremented once
+	example1, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, oldSerial+1, example1.Serial)
+}
+
+func TestRecordFromRR(t *testing.T) {
+	rr, err := dns.NewRR("www.example.com. 600 IN MX 10 mail.example.com.")
+	assert.Nil(t, err)
+	record := RecordFromRR(rr, "example.com.")
+	assert.Equal(t, "MX", record.Type)
+	assert.Equal(t, "www", record.Label)
+	assert.Equal(t, "10 mail.example.com.", record.Value)
+	assert.Equal(t, uint32(600), record.TTL)
+
+	assert.Equal(t, "@", RelativeLabel("Example.com.", "example.com"))
+	assert.Equal(t, "a.b", RelativeLabel("a.b.example.com", "example.com."))
+
+	assert.Equal(t, "example.com.", AbsoluteName("@", "example.com"))
+	assert.Equal(t, "cdn.example.com.", AbsoluteName("CDN", "example.com."))
+	assert.Equal(t, "lb.example.net.", AbsoluteName("lb.example.net.", "example.com."))
+}
+
+func TestRecordApplyChanges(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Add user1
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.NotNil(t, example1)
+
+	err = RecordAdd(db, &Record{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, ZoneID: example1.ID})
+	assert.Nil(t, err)
+	err = RecordAdd(db, &Record{Type: "A", Label: "www", Value: "192.0.2.2", TTL: 300, ZoneID: example1.ID})
+	assert.Nil(t, err)
+	records, err := RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+
+	example1, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	oldSerial := example1.Serial
+
+	changes := []RecordChange{
+		{Op: "add", Record: Record{Type: "AAAA", Label: "@", Value: "2001:db8::1", TTL: 300}},
+		{Op: "update", Record: Record{ID: records[0].ID, Type: "A", Label: "@", Value: "192.0.2.3", TTL: 600}},
+		{Op: "delete", Record: Record{ID: records[1].ID}},
+	}
+
+	// Dry run shouldn't change anything
+	diff, err := RecordApplyChanges(db, example1.ID, changes, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(diff))
+	assert.Equal(t, "192.0.2.1", diff[1].Before.Value)
+	assert.Equal(t, "192.0.2.3", diff[1].After.Value)
+	unchanged, err := RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, records, unchanged)
+
+	// Apply the changeset
+	_, err = RecordApplyChanges(db, example1.ID, changes, false)
+	assert.Nil(t, err)
+	records, err = RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	example1, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, oldSerial+1, example1.Serial)
+
+	// A changeset referencing a missing record should be rolled back entirely
+	_, err = RecordApplyChanges(db, example1.ID, []RecordChange{
+		{Op: "add", Record: Record{Type: "TXT", Label: "@", Value: `"test"`, TTL: 300}},
+		{Op: "delete", Record: Record{ID: "00000000-0000-0000-0000-000000000000"}},
+	}, false)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	unchanged, err = RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, records, unchanged)
+}
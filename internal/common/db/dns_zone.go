@@ -12,27 +12,90 @@ import (
 	"github.com/lib/pq"
 	"github.com/miekg/dns"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/packetframe/api/internal/api/auth"
 	"github.com/packetframe/api/internal/common/util"
 )
 
+var (
+	// Nameservers stores the platform authoritative nameservers, used for zones without their own nameservers
+	Nameservers = []string{"ns1.packetframe.com.", "ns2.packetframe.com."}
+	// SOARName stores the default responsible party mailbox for zones without their own
+	SOARName = "info.packetframe.com."
+	// SOADefaults stores the default SOA timers for zones without their own
+	SOADefaults = SOATimers{
+		Refresh: 7200,    // Number of seconds after which secondary NSes should query the main to detect zone changes
+		Retry:   3600,    // Number of seconds after which secondary NSes should retry serial query from the main if it doesn't respond
+		Expire:  1209600, // Number of seconds after which secondary NSes should stop answering if main doesn't respond
+		MinTTL:  300,     // Negative cache TTL
+	}
+)
+
 var (
 	ErrUserExistingZoneMember = errors.New("user is already a member of this zone")
 	ErrUserNotFound           = errors.New("user not found")
 	ErrZoneNotFound           = errors.New("zone not found")
+	ErrZoneExists             = errors.New("zone already exists")
 	ErrLastZoneUser           = errors.New("unable to remove last user from zone")
+	ErrLastZoneOwner          = errors.New("unable to remove or demote the last owner of a zone")
+	ErrInvalidRole            = errors.New("invalid role, must be one of owner, editor or viewer")
 )
 
+// Zone member roles
+const (
+	RoleViewer = "viewer" // Can read records
+	RoleEditor = "editor" // Can read and modify records
+	RoleOwner  = "owner"  // Can modify records and members, and delete the zone
+)
+
+// roleLevels maps each role to its privilege level
+var roleLevels = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ZoneRole stores a zone member's role. Members without a role are owners, as all members had full control before roles existed.
+type ZoneRole struct {
+	ZoneID string `gorm:"primaryKey" json:"zone"`
+	UserID string `gorm:"primaryKey" json:"user"`
+	Role   string `json:"role"`
+}
+
+// ValidRole checks if a role is valid
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAtLeast checks if a role grants at least the privileges of another role
+func RoleAtLeast(role, required string) bool {
+	return roleLevels[role] >= roleLevels[required]
+}
+
 // Zone stores a DNS zone
 type Zone struct {
-	ID         string         `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
-	Zone       string         `gorm:"uniqueIndex" json:"zone" validate:"required,fqdn"`
-	Serial     uint64         `json:"-"`
-	DNSSEC     DNSSECKey      `gorm:"embedded" json:"-"`
-	Users      pq.StringArray `gorm:"type:text[]" json:"users"`
-	UserEmails pq.StringArray `gorm:"type:text[]" json:"user_emails"`
-	CreatedAt  time.Time      `json:"-"`
-	UpdatedAt  time.Time      `json:"-"`
+	ID                  string         `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	Zone                string         `gorm:"index;uniqueIndex:idx_zones_verified_zone,where:pending_verification = false" json:"zone" validate:"required,fqdn"` // Only unique among verified zones, so pending claims don't reserve the name
+	Serial              uint64         `json:"-"`
+	DNSSECAlgorithm     uint8          `gorm:"default:13" json:"dnssec_algorithm"`
+	DNSSEC              DNSSECKey      `gorm:"embedded" json:"-"`                             // Key signing key
+	DNSSECNext          DNSSECKey      `gorm:"embedded;embeddedPrefix:dnssec_next_" json:"-"` // Key introduced during a double-signature rollover, published alongside DNSSEC until confirmed
+	ZSK                 DNSSECKey      `gorm:"embedded;embeddedPrefix:zsk_" json:"-"`         // Zone signing key
+	ZSKNext             DNSSECKey      `gorm:"embedded;embeddedPrefix:zsk_next_" json:"-"`    // Zone signing key introduced during an automatic rollover
+	ZSKRotatedAt        time.Time      `json:"-"`
+	Users               pq.StringArray `gorm:"type:text[]" json:"users"`
+	UserEmails          pq.StringArray `gorm:"type:text[]" json:"user_emails"`
+	UserRoles           pq.StringArray `gorm:"type:text[]" json:"user_roles"`
+	OrganizationID      string         `gorm:"index" json:"organization"`                         // Organization that owns the zone, if any
+	SOATimers           SOATimers      `gorm:"embedded;embeddedPrefix:soa_" json:"soa"`           // Zero values use SOADefaults
+	SOAContact          string         `json:"soa_rname"`                                         // Empty uses SOARName
+	ZoneNameservers     pq.StringArray `gorm:"column:nameservers;type:text[]" json:"nameservers"` // Empty uses Nameservers
+	PendingVerification bool           `gorm:"default:false" json:"pending_verification"`         // Zone isn't served until ownership is verified, zones that existed before verification are verified
+	VerificationToken   string         `json:"verification_token,omitempty"`
+	Kind                string         `gorm:"default:primary" json:"kind"`               // ZoneKindPrimary or ZoneKindSecondary
+	Primaries           pq.StringArray `gorm:"type:text[]" json:"primaries"`              // Addresses secondary zones are transferred from
+	TSIG                TSIGKey        `gorm:"embedded;embeddedPrefix:tsig_" json:"tsig"` // Key used to authenticate transfers from the primaries
+	Dynamic             string         `gorm:"type:text" json:"-"`                        // JSON DynamicState, maintained by the API's dynamic refresher
+	CreatedAt           time.Time      `json:"-"`
+	UpdatedAt           time.Time      `json:"-"`
 }
 
 // DNSSECKey stores a DNSSEC signing key
@@ -47,6 +110,43 @@ type DNSSECKey struct {
 	DSRecordString string // Full DS record in zone file format
 }
 
+// SOA returns the zone's SOA record
+func (z *Zone) SOA() *dns.SOA {
+	timers := z.Timers()
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   z.Zone,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    3600,
+		},
+		Ns:      z.Nameservers()[0],
+		Mbox:    z.RName(),
+		Serial:  uint32(z.Serial),
+		Refresh: timers.Refresh,
+		Retry:   timers.Retry,
+		Expire:  timers.Expire,
+		Minttl:  timers.MinTTL,
+	}
+}
+
+// NS returns the zone's apex NS records
+func (z *Zone) NS() []dns.RR {
+	var rrs []dns.RR
+	for _, ns := range z.Nameservers() {
+		rrs = append(rrs, &dns.NS{
+			Hdr: dns.RR_Header{
+				Name:   z.Zone,
+				Rrtype: dns.TypeNS,
+				Class:  dns.ClassINET,
+				Ttl:    86400,
+			},
+			Ns: ns,
+		})
+	}
+	return rrs
+}
+
 // SuffixList gets the public suffix list
 func SuffixList() ([]string, error) {
 	resp, err := http.Get("https://publicsuffix.org/list/public_suffix_list.dat")
@@ -70,8 +170,18 @@ func SuffixList() ([]string, error) {
 	return strings.Split(suffixes, "\n")[1:], nil // [1:] to remove first (blank) element
 }
 
-// NewKey generates a new DNSSEC signing key for a zone
-func NewKey(zone string) (*DNSSECKey, error) {
+// NewKey generates a new DNSSEC key signing key or zone signing key for a zone
+func NewKey(zone string, algorithm uint8, ksk bool) (*DNSSECKey, error) {
+	bits, ok := DNSSECAlgorithms[algorithm]
+	if !ok {
+		return nil, ErrInvalidDNSSECAlgorithm
+	}
+
+	var flags uint16 = 256
+	if ksk {
+		flags = 257
+	}
+
 	key := &dns.DNSKEY{
 		Hdr: dns.RR_Header{
 			Name:   dns.Fqdn(zone),
@@ -79,10 +189,10 @@ func NewKey(zone string) (*DNSSECKey, error) {
 			Ttl:    3600,
 			Rrtype: dns.TypeDNSKEY,
 		},
-		Algorithm: dns.ECDSAP256SHA256, Flags: 257, Protocol: 3,
+		Algorithm: algorithm, Flags: flags, Protocol: 3,
 	}
 
-	private, err := key.Generate(256)
+	private, err := key.Generate(bits)
 	if err != nil {
 		return nil, err
 	}
@@ -101,40 +211,87 @@ func NewKey(zone string) (*DNSSECKey, error) {
 	}, nil // nil error
 }
 
-// ZoneIncrementSerial increments a zone's SOA serial by 1
+// ZoneIncrementSerial increments a zone's SOA serial by 1 and snapshots the zone's records as a new version
 func ZoneIncrementSerial(db *gorm.DB, uuid string) error {
+	// Increment in the database so concurrent changes never reuse a serial
+	res := db.Model(&Zone{}).Where("id = ?", uuid).Update("serial", gorm.Expr("serial + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	var zone Zone
-	if err := db.First(&zone, "id = ?", uuid).Error; err != nil {
+	if err := db.Select("id", "serial").First(&zone, "id = ?", uuid).Error; err != nil {
 		return err
 	}
-	zone.Serial++
-	return db.Save(&zone).Error
+	if err := zoneSnapshot(db, zone.ID, zone.Serial); err != nil {
+		return err
+	}
+	return zoneNotify(db, zone.ID)
 }
 
-// ZoneAdd adds a DNS zone by zone name and user email
-func ZoneAdd(db *gorm.DB, zone string, user string) error {
+// ZoneAdd adds a DNS zone by zone name and user email with keys of a DNSSEC algorithm, or DefaultDNSSECAlgorithm if algorithm is 0, and returns the new zone
+func ZoneAdd(db *gorm.DB, zone string, user string, algorithm uint8) (*Zone, error) {
+	if algorithm == 0 {
+		algorithm = DefaultDNSSECAlgorithm
+	}
+	if _, ok := DNSSECAlgorithms[algorithm]; !ok {
+		return nil, ErrInvalidDNSSECAlgorithm
+	}
+
 	u, err := UserFindByEmail(db, user)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if u == nil {
-		return ErrUserNotFound
+		return nil, ErrUserNotFound
 	}
 
+	// Pending claims don't reserve the name, so a zone can be claimed by several users until one of them verifies it
 	zone = dns.Fqdn(zone)
-	dnssecKey, err := NewKey(zone)
+	var claims int64
+	if err := db.Model(&Zone{}).Where("zone = ? AND (pending_verification = ? OR ? = ANY (users))", zone, false, u.ID).Count(&claims).Error; err != nil {
+		return nil, err
+	}
+	if claims > 0 {
+		return nil, ErrZoneExists
+	}
+
+	ksk, err := NewKey(zone, algorithm, true)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	zsk, err := NewKey(zone, algorithm, false)
+	if err != nil {
+		return nil, err
 	}
 
-	tx := db.Create(&Zone{
-		Zone:   zone,
-		DNSSEC: *dnssecKey,
-		Serial: uint64(time.Now().Unix()),
-		Users:  []string{u.ID},
-	})
+	token, err := auth.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
 
-	return tx.Error
+	z := Zone{
+		Zone:                zone,
+		PendingVerification: true,
+		VerificationToken:   token,
+		DNSSECAlgorithm:     algorithm,
+		DNSSEC:              *ksk,
+		ZSK:                 *zsk,
+		ZSKRotatedAt:        time.Now(),
+		Serial:              uint64(time.Now().Unix()),
+		Users:               []string{u.ID},
+	}
+	if err := db.Create(&z).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Create(&ZoneRole{ZoneID: z.ID, UserID: u.ID, Role: RoleOwner}).Error; err != nil {
+		return nil, err
+	}
+
+	// Snapshot the empty zone so it can be rolled back to
+	return &z, zoneSnapshot(db, z.ID, z.Serial)
 }
 
 // ZoneList gets a list of all zones
@@ -147,11 +304,11 @@ func ZoneList(db *gorm.DB) ([]Zone, error) {
 	return zones, nil
 }
 
-// ZoneFind finds a zone by FQDN and returns nil if no zone exists
+// ZoneFind finds a zone by FQDN, preferring the verified zone over the oldest pending claim, and returns nil if no zone exists
 func ZoneFind(db *gorm.DB, zone string) (*Zone, error) {
 	zone = dns.Fqdn(zone)
 	var z Zone
-	res := db.First(&z, "zone = ?", zone)
+	res := db.Order("pending_verification, created_at").First(&z, "zone = ?", zone)
 	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
 		return nil, nil
 	}
@@ -165,26 +322,49 @@ func ZoneFind(db *gorm.DB, zone string) (*Zone, error) {
 // ZoneDelete deletes a DNS zone
 func ZoneDelete(db *gorm.DB, zone string) (bool, error) {
 	db.Delete(&Record{}, "zone_id = ?", zone)
+	db.Delete(&ZoneVersion{}, "zone_id = ?", zone)
+	db.Delete(&ZoneRole{}, "zone_id = ?", zone)
+	db.Delete(&ZoneHealth{}, "zone_id = ?", zone)
+	db.Delete(&ZoneTransfer{}, "zone_id = ?", zone)
+	db.Delete(&TransferPeer{}, "zone_id = ?", zone)
+	db.Delete(&UpdateKey{}, "zone_id = ?", zone)
+	db.Delete(&DynDNSCredential{}, "zone_id = ?", zone)
+	db.Delete(&ACMECredential{}, "zone_id = ?", zone)
+	db.Delete(&ACMEChallenge{}, "zone_id = ?", zone)
+	db.Delete(&RecordHealth{}, "zone_id = ?", zone)
 	r := db.Delete(&Zone{}, "id = ?", zone)
 	return r.RowsAffected > 0, r.Error
 }
 
-// ZoneRotateDNSSECKey rotates a zone's DNSSEC key
+// ZoneRotateDNSSECKey immediately replaces a zone's DNSSEC keys, cancelling any rollover in progress
 func ZoneRotateDNSSECKey(db *gorm.DB, uuid string) error {
 	var zone Zone
 	if err := db.First(&zone, "id = ?", uuid).Error; err != nil {
 		return err
 	}
-	dnssecKey, err := NewKey(zone.Zone)
+	loaded := zone.dnssecKeyState()
+	ksk, err := NewKey(zone.Zone, zone.DNSSECAlgorithm, true)
 	if err != nil {
 		return err
 	}
-	zone.DNSSEC = *dnssecKey
-	return db.Save(&zone).Error
+	zsk, err := NewKey(zone.Zone, zone.DNSSECAlgorithm, false)
+	if err != nil {
+		return err
+	}
+	zone.DNSSEC = *ksk
+	zone.DNSSECNext = DNSSECKey{}
+	zone.ZSK = *zsk
+	zone.ZSKNext = DNSSECKey{}
+	zone.ZSKRotatedAt = time.Now()
+	return zoneSaveKeys(db, &zone, loaded)
 }
 
-// ZoneUserAdd adds a user to a zone
-func ZoneUserAdd(db *gorm.DB, zoneID string, userEmail string) error {
+// ZoneUserAdd adds a user to a zone with a role
+func ZoneUserAdd(db *gorm.DB, zoneID string, userEmail string, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
 	var z Zone
 	if err := db.First(&z, "id = ?", zoneID).Error; err != nil {
 		return err
@@ -207,7 +387,12 @@ func ZoneUserAdd(db *gorm.DB, zoneID string, userEmail string) error {
 	}
 
 	z.Users = append(z.Users, u.ID)
-	return db.Save(&z).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("serial", "dynamic").Save(&z).Error; err != nil {
+			return err
+		}
+		return tx.Save(&ZoneRole{ZoneID: z.ID, UserID: u.ID, Role: role}).Error
+	})
 }
 
 // ZoneUserDelete deletes a user from a zone
@@ -226,9 +411,21 @@ func ZoneUserDelete(db *gorm.DB, zoneUuid string, userEmail string) error {
 		return ErrUserNotFound
 	}
 
-	// Prevent the user from removing the last user of the zone
-	if len(z.Users) == 1 {
-		return ErrLastZoneUser
+	// Zones owned by an organization remain accessible through the organization, so they can have no direct members
+	if z.OrganizationID == "" {
+		// Prevent the user from removing the last user of the zone
+		if len(z.Users) == 1 {
+			return ErrLastZoneUser
+		}
+
+		// Prevent the user from removing the last owner of the zone
+		owners, err := zoneOwners(db, &z)
+		if err != nil {
+			return err
+		}
+		if len(owners) == 1 && owners[0] == u.ID {
+			return ErrLastZoneOwner
+		}
 	}
 
 	for i, existingUserId := range z.Users {
@@ -237,7 +434,104 @@ func ZoneUserDelete(db *gorm.DB, zoneUuid string, userEmail string) error {
 		}
 	}
 
-	return db.Save(&z).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("serial", "dynamic").Save(&z).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&ZoneRole{}, "zone_id = ? AND user_id = ?", z.ID, u.ID).Error
+	})
+}
+
+// ZoneUserSetRole changes the role of an existing zone member
+func ZoneUserSetRole(db *gorm.DB, zoneID string, userEmail string, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	var z Zone
+	if err := db.First(&z, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+
+	u, err := UserFindByEmail(db, userEmail)
+	if err != nil {
+		return err
+	}
+	if u == nil || !util.StrSliceContains(z.Users, u.ID) {
+		return ErrUserNotFound
+	}
+
+	// Prevent the last owner from being demoted
+	if role != RoleOwner && z.OrganizationID == "" {
+		owners, err := zoneOwners(db, &z)
+		if err != nil {
+			return err
+		}
+		if len(owners) == 1 && owners[0] == u.ID {
+			return ErrLastZoneOwner
+		}
+	}
+
+	return db.Save(&ZoneRole{ZoneID: z.ID, UserID: u.ID, Role: role}).Error
+}
+
+// ZoneUserRole gets a user's role on a zone, which is the highest of their direct role and their role in the organization that owns the zone
+func ZoneUserRole(db *gorm.DB, zoneUuid string, userUuid string) (string, error) {
+	var z Zone
+	if err := db.Where("id = ?", zoneUuid).Find(&z).Error; err != nil {
+		return "", err
+	}
+	if z.ID == "" {
+		return "", ErrZoneNotFound
+	}
+
+	role := ""
+	if util.StrSliceContains(z.Users, userUuid) {
+		var roles []ZoneRole
+		if err := db.Where("zone_id = ? AND user_id = ?", zoneUuid, userUuid).Find(&roles).Error; err != nil {
+			return "", err
+		}
+		if len(roles) == 0 {
+			role = RoleOwner
+		} else {
+			role = roles[0].Role
+		}
+	}
+
+	if z.OrganizationID != "" {
+		orgRole, err := OrganizationUserRole(db, z.OrganizationID, userUuid)
+		if err != nil && !errors.Is(err, ErrOrganizationNotFound) {
+			return "", err
+		}
+		if orgRole != "" && RoleAtLeast(orgRole, role) {
+			role = orgRole
+		}
+	}
+
+	if role == "" {
+		return "", ErrZoneNotFound
+	}
+	return role, nil
+}
+
+// zoneOwners gets the IDs of all owners of a zone
+func zoneOwners(db *gorm.DB, z *Zone) ([]string, error) {
+	var roles []ZoneRole
+	if err := db.Find(&roles, "zone_id = ?", z.ID).Error; err != nil {
+		return nil, err
+	}
+	memberRoles := map[string]string{}
+	for _, r := range roles {
+		memberRoles[r.UserID] = r.Role
+	}
+
+	var owners []string
+	for _, userID := range z.Users {
+		if role, found := memberRoles[userID]; !found || role == RoleOwner {
+			owners = append(owners, userID)
+		}
+	}
+	return owners, nil
 }
 
 // ZoneFindByID gets a zone by UUID
@@ -249,7 +543,16 @@ func ZoneFindByID(db *gorm.DB, zoneUuid string) (*Zone, error) {
 	return &z, nil
 }
 
-// ZoneUserGetZones gets all zones a user is a member of
+// ZoneLock finds a zone by ID and locks its row until the end of the transaction, so record changes checked against the zone's records can't be interleaved with other changes
+func ZoneLock(tx *gorm.DB, zoneID string) (*Zone, error) {
+	var z Zone
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&z, "id = ?", zoneID).Error; err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+// ZoneUserGetZones gets all zones a user is a member of, directly or through an organization
 func ZoneUserGetZones(db *gorm.DB, userUuid string) ([]Zone, error) {
 	user, err := UserFindById(db, userUuid)
 	if err != nil {
@@ -259,18 +562,31 @@ func ZoneUserGetZones(db *gorm.DB, userUuid string) ([]Zone, error) {
 	var zones []Zone
 	var tx *gorm.DB
 
-	if util.StrSliceContains(user.Groups, GroupAdmin) { // If admin
-		tx = db.Raw(`SELECT z.*,array_agg(u.email) user_emails
+	admin, err := UserAdminAuthorized(db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if admin {
+		tx = db.Raw(`SELECT z.*,
+			COALESCE(array_agg(u.email) FILTER (WHERE u.id IS NOT NULL), '{}') user_emails,
+			COALESCE(array_agg(COALESCE(r.role, 'owner')) FILTER (WHERE u.id IS NOT NULL), '{}') user_roles
 			FROM zones z
-			JOIN users u ON u.id = ANY (z.users)
+			LEFT JOIN users u ON u.id = ANY (z.users)
+			LEFT JOIN zone_roles r ON r.zone_id = z.id AND r.user_id = u.id
 			GROUP BY z.id;`).Scan(&zones)
 	} else {
-		// It seems that user_emails is not guaranteed to be in the same order as the as the original users array
-		tx = db.Raw(`SELECT z.*,array_agg(u.email) user_emails
+		// It seems that user_emails is not guaranteed to be in the same order as the as the original users array, but user_roles is in the same order as user_emails
+		// Organization zones may have no direct members, so users are left joined and filtered out of the aggregates when missing
+		tx = db.Raw(`SELECT z.*,
+			COALESCE(array_agg(u.email) FILTER (WHERE u.id IS NOT NULL), '{}') user_emails,
+			COALESCE(array_agg(COALESCE(r.role, 'owner')) FILTER (WHERE u.id IS NOT NULL), '{}') user_roles
 			FROM zones z
-			JOIN users u ON u.id = ANY (z.users)
-			AND ? = ANY(z.users)
-			GROUP BY z.id;`, userUuid).Scan(&zones)
+			LEFT JOIN users u ON u.id = ANY (z.users)
+			LEFT JOIN zone_roles r ON r.zone_id = z.id AND r.user_id = u.id
+			WHERE ? = ANY(z.users)
+			OR z.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = ?)
+			GROUP BY z.id;`, userUuid, userUuid).Scan(&zones)
 	}
 
 	if tx.Error != nil {
@@ -279,10 +595,12 @@ func ZoneUserGetZones(db *gorm.DB, userUuid string) ([]Zone, error) {
 	return zones, nil
 }
 
-// ZoneUserAuthorized checks if a user is authorized for a zone
+// ZoneUserAuthorized checks if a user is authorized for a zone, directly or through an organization
 func ZoneUserAuthorized(db *gorm.DB, zoneUuid string, userUuid string) error {
 	var z Zone
-	res := db.Model(&Zone{}).Where("id = ? AND ? = ANY(users)", zoneUuid, userUuid).Find(&z)
+	res := db.Model(&Zone{}).
+		Where("id = ? AND (? = ANY(users) OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = ?))", zoneUuid, userUuid, userUuid).
+		Find(&z)
 	if z.ID == "" {
 		return ErrZoneNotFound
 	}
@@ -1,8 +1,11 @@
 package db
 
 import (
-	"github.com/stretchr/testify/assert"
 	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestZoneAddListFindDelete(t *testing.T) {
@@ -13,11 +16,11 @@ func TestZoneAddListFindDelete(t *testing.T) {
 	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
-	err = ZoneAdd(db, "example2.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example2.com", "user1@example.com", 0)
 	assert.Nil(t, err)
-	err = ZoneAdd(db, "example3.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example3.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 
 	zones, err := ZoneList(db)
@@ -45,7 +48,7 @@ func TestZoneRotateDNSSECKey(t *testing.T) {
 	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 
 	example1, err := ZoneFind(db, "example1.com")
@@ -73,10 +76,10 @@ func TestZoneAddDuplicate(t *testing.T) {
 	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.NotNil(t, err)
 }
 
@@ -98,18 +101,18 @@ func TestZoneUserAddListDelete(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Add and find example1.com
-	err = ZoneAdd(db, "example1.com", user1.Email)
+	_, err = ZoneAdd(db, "example1.com", user1.Email, 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
 	assert.NotNil(t, example1)
 
 	// Add user1 again
-	err = ZoneUserAdd(db, example1.ID, user1.Email)
+	err = ZoneUserAdd(db, example1.ID, user1.Email, RoleOwner)
 	assert.NotNil(t, err)
 
 	// Add user2
-	err = ZoneUserAdd(db, example1.ID, user2.Email)
+	err = ZoneUserAdd(db, example1.ID, user2.Email, RoleOwner)
 	assert.Nil(t, err)
 
 	// List zone users
@@ -141,7 +144,7 @@ func TestZoneIncrementSerial(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Add and find example1.com
-	err = ZoneAdd(db, "example1.com", "user1@example.com")
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
@@ -159,6 +162,29 @@ func TestZoneIncrementSerial(t *testing.T) {
 	assert.Equal(t, oldSerial+1, example1.Serial)
 }
 
+func TestZoneLock(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+
+	// The locked zone is returned, unknown zones are an error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		zone, err := ZoneLock(tx, example1.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, example1.ID, zone.ID)
+		_, err = ZoneLock(tx, "00000000-0000-0000-0000-000000000000")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
 // TestZoneUserGetZones tests getting the zones of a user
 func TestZoneUserGetZones(t *testing.T) {
 	db, err := TestSetup()
@@ -171,7 +197,7 @@ func TestZoneUserGetZones(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Add and find example1.com
-	err = ZoneAdd(db, "example1.com", user1.Email)
+	_, err = ZoneAdd(db, "example1.com", user1.Email, 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
@@ -196,7 +222,7 @@ func TestZoneUserAuthorized(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Add and find example1.com
-	err = ZoneAdd(db, "example1.com", user1.Email)
+	_, err = ZoneAdd(db, "example1.com", user1.Email, 0)
 	assert.Nil(t, err)
 	example1, err := ZoneFind(db, "example1.com")
 	assert.Nil(t, err)
@@ -218,3 +244,91 @@ func TestSuffixList(t *testing.T) {
 	assert.Contains(t, suffixes, "com")
 	assert.Contains(t, suffixes, "workers.dev")
 }
+
+func TestZoneSOANS(t *testing.T) {
+	zone := Zone{Zone: "example.com.", Serial: 42}
+
+	soa := zone.SOA()
+	assert.Equal(t, uint32(42), soa.Serial)
+	assert.Equal(t, Nameservers[0], soa.Ns)
+	assert.Equal(t, SOARName, soa.Mbox)
+
+	ns := zone.NS()
+	assert.Equal(t, len(Nameservers), len(ns))
+	for i, rr := range ns {
+		assert.Equal(t, "example.com.", rr.Header().Name)
+		assert.Equal(t, Nameservers[i], rr.(*dns.NS).Ns)
+	}
+}
+
+// TestZoneUserRoles tests zone member roles
+func TestZoneUserRoles(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Create user1@example.com and user2@example.com
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	err = UserAdd(db, "user2@example.com", "password2", "example referrer")
+	assert.Nil(t, err)
+	user2, err := UserFindByEmail(db, "user2@example.com")
+	assert.Nil(t, err)
+
+	// Add and find example1.com
+	_, err = ZoneAdd(db, "example1.com", user1.Email, 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.NotNil(t, example1)
+
+	// Zone creator is an owner
+	role, err := ZoneUserRole(db, example1.ID, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, RoleOwner, role)
+
+	// Add user2 with an invalid role
+	err = ZoneUserAdd(db, example1.ID, user2.Email, "admin")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	// Add user2 as a viewer
+	err = ZoneUserAdd(db, example1.ID, user2.Email, RoleViewer)
+	assert.Nil(t, err)
+	role, err = ZoneUserRole(db, example1.ID, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, RoleViewer, role)
+	assert.True(t, RoleAtLeast(role, RoleViewer))
+	assert.False(t, RoleAtLeast(role, RoleEditor))
+
+	// Roles are returned alongside user emails
+	zones, err := ZoneUserGetZones(db, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+	for i, email := range zones[0].UserEmails {
+		if email == user2.Email {
+			assert.Equal(t, RoleViewer, zones[0].UserRoles[i])
+		} else {
+			assert.Equal(t, RoleOwner, zones[0].UserRoles[i])
+		}
+	}
+
+	// The last owner can't be demoted or removed
+	err = ZoneUserSetRole(db, example1.ID, user1.Email, RoleEditor)
+	assert.ErrorIs(t, err, ErrLastZoneOwner)
+	err = ZoneUserDelete(db, example1.ID, user1.Email)
+	assert.ErrorIs(t, err, ErrLastZoneOwner)
+
+	// Promote user2 and then demote user1
+	err = ZoneUserSetRole(db, example1.ID, user2.Email, RoleOwner)
+	assert.Nil(t, err)
+	err = ZoneUserSetRole(db, example1.ID, user1.Email, RoleEditor)
+	assert.Nil(t, err)
+	role, err = ZoneUserRole(db, example1.ID, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+
+	// Non-members don't have a role
+	_, err = ZoneUserRole(db, "not-a-real-zone", user1.ID)
+	assert.NotNil(t, err)
+}
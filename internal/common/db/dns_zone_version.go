@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrZoneVersionNotFound = errors.New("zone version not found")
+
+// ZoneVersionRetention is the number of versions kept for each zone, older versions are pruned when a new version is stored
+const ZoneVersionRetention = 100
+
+// actorKey is the context key used to attribute zone changes to a user
+type actorKey struct{}
+
+// ZoneVersion stores a snapshot of a zone's records at a serial
+type ZoneVersion struct {
+	ID        string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	Serial    uint64    `json:"serial"`
+	Actor     string    `json:"actor"` // Email of the user who made the change
+	Records   string    `json:"-"`     // JSON encoded list of records
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// versionRecord stores a record in a zone version with its creation time, which isn't part of a record's JSON
+type versionRecord struct {
+	Record
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordList decodes the records stored in a zone version
+func (v *ZoneVersion) RecordList() ([]Record, error) {
+	var stored []versionRecord
+	if err := json.Unmarshal([]byte(v.Records), &stored); err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(stored))
+	for i, r := range stored {
+		records[i] = r.Record
+		records[i].CreatedAt = r.CreatedAt
+	}
+	return records, nil
+}
+
+// WithActor returns a database session that attributes zone changes to the given actor
+func WithActor(db *gorm.DB, actor string) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, actorKey{}, actor))
+}
+
+// zoneSnapshot stores the current records of a zone as a new version
+func zoneSnapshot(db *gorm.DB, zoneID string, serial uint64) error {
+	records, err := RecordList(db, zoneID)
+	if err != nil {
+		return err
+	}
+	stored := make([]versionRecord, len(records))
+	for i, r := range records {
+		stored[i] = versionRecord{Record: r, CreatedAt: r.CreatedAt}
+	}
+	recordsJSON, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	actor, _ := db.Statement.Context.Value(actorKey{}).(string)
+	if err := db.Create(&ZoneVersion{
+		ZoneID:  zoneID,
+		Serial:  serial,
+		Actor:   actor,
+		Records: string(recordsJSON),
+	}).Error; err != nil {
+		return err
+	}
+
+	// Prune versions beyond the retention limit
+	newest := db.Model(&ZoneVersion{}).Select("serial").Where("zone_id = ?", zoneID).Order("serial DESC").Limit(ZoneVersionRetention)
+	return db.Where("zone_id = ? AND serial NOT IN (?)", zoneID, newest).Delete(&ZoneVersion{}).Error
+}
+
+// ZoneVersionList gets all versions of a zone, newest first
+func ZoneVersionList(db *gorm.DB, zoneID string) ([]ZoneVersion, error) {
+	var versions []ZoneVersion
+	err := db.Omit("records").Order("serial DESC").Where("zone_id = ?", zoneID).Find(&versions).Error
+	return versions, err
+}
+
+// ZoneVersionFind finds a zone version by serial
+func ZoneVersionFind(db *gorm.DB, zoneID string, serial uint64) (*ZoneVersion, error) {
+	var v ZoneVersion
+	res := db.First(&v, "zone_id = ? AND serial = ?", zoneID, serial)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrZoneVersionNotFound
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &v, nil
+}
+
+// ZoneVersionDiff computes the record changes between two versions of a zone
+func ZoneVersionDiff(db *gorm.DB, zoneID string, from, to uint64) ([]RecordDiff, error) {
+	var records [2][]Record
+	for i, serial := range []uint64{from, to} {
+		v, err := ZoneVersionFind(db, zoneID, serial)
+		if err != nil {
+			return nil, err
+		}
+		records[i], err = v.RecordList()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return diffRecords(records[0], records[1]), nil
+}
+
+// diffRecords computes the changes required to turn one list of records into another
+func diffRecords(before, after []Record) []RecordDiff {
+	beforeByID := map[string]Record{}
+	for _, r := range before {
+		beforeByID[r.ID] = r
+	}
+
+	diff := []RecordDiff{}
+	for i := range after {
+		a := after[i]
+		b, found := beforeByID[a.ID]
+		if !found {
+			diff = append(diff, RecordDiff{Op: "add", After: &a})
+			continue
+		}
+		delete(beforeByID, a.ID)
+		if !sameColumns(b, a) {
+			diff = append(diff, RecordDiff{Op: "update", Before: &b, After: &a})
+		}
+	}
+
+	// Anything left over was deleted, iterate over the original list to keep a stable order
+	for i := range before {
+		if b, found := beforeByID[before[i].ID]; found {
+			diff = append(diff, RecordDiff{Op: "delete", Before: &b})
+		}
+	}
+
+	return diff
+}
+
+// ZoneVersionRecords returns the records of a zone version
+func ZoneVersionRecords(db *gorm.DB, zoneID string, serial uint64) ([]Record, error) {
+	v, err := ZoneVersionFind(db, zoneID, serial)
+	if err != nil {
+		return nil, err
+	}
+	return v.RecordList()
+}
+
+// ZoneRollback restores the records of a previous zone version of a primary zone as a new serial.
+// The zone is locked so concurrent changes can't be interleaved, and the dynamic DNS credentials and health of records that aren't restored are deleted like RecordDelete does.
+func ZoneRollback(db *gorm.DB, zoneID string, serial uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		zone, err := ZoneLock(tx, zoneID)
+		if err != nil {
+			return err
+		}
+		if zone.IsSecondary() {
+			return ErrSecondaryZone
+		}
+		records, err := ZoneVersionRecords(tx, zoneID, serial)
+		if err != nil {
+			return err
+		}
+
+		// Keep the creation time of records in versions stored before it was, if they still exist
+		current, err := RecordList(tx, zoneID)
+		if err != nil {
+			return err
+		}
+		createdAt := map[string]time.Time{}
+		for _, r := range current {
+			createdAt[r.ID] = r.CreatedAt
+		}
+		restored := map[string]bool{}
+		for i := range records {
+			restored[records[i].ID] = true
+			if records[i].CreatedAt.IsZero() {
+				records[i].CreatedAt = createdAt[records[i].ID]
+			}
+		}
+
+		var removed []string
+		for _, r := range current {
+			if !restored[r.ID] {
+				removed = append(removed, r.ID)
+			}
+		}
+		if len(removed) > 0 {
+			if err := tx.Delete(&DynDNSCredential{}, "record_id IN ?", removed).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&RecordHealth{}, "record_id IN ?", removed).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&Record{}, "zone_id = ?", zoneID).Error; err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			if err := tx.Create(&records).Error; err != nil {
+				return err
+			}
+		}
+		return ZoneIncrementSerial(tx, zoneID)
+	})
+}
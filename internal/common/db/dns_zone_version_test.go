@@ -0,0 +1,161 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneVersionListDiffRollback(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Add user1
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.NotNil(t, example1)
+	initialSerial := example1.Serial
+
+	// Add a record as user1
+	err = RecordAdd(WithActor(db, "user1@example.com"), &Record{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, ZoneID: example1.ID})
+	assert.Nil(t, err)
+
+	added, err := RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(added))
+
+	versions, err := ZoneVersionList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(versions))
+	assert.Equal(t, initialSerial+1, versions[0].Serial)
+	assert.Equal(t, "user1@example.com", versions[0].Actor)
+	assert.Equal(t, initialSerial, versions[1].Serial)
+
+	diff, err := ZoneVersionDiff(db, example1.ID, initialSerial, initialSerial+1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(diff))
+	assert.Equal(t, "add", diff[0].Op)
+	assert.Equal(t, "192.0.2.1", diff[0].After.Value)
+
+	// Roll back to the empty zone
+	err = ZoneRollback(db, example1.ID, initialSerial)
+	assert.Nil(t, err)
+	records, err := RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+
+	// Rolling back creates a new version
+	example1, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, initialSerial+2, example1.Serial)
+	versions, err = ZoneVersionList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(versions))
+
+	// Rolling back restores the records' creation time
+	err = ZoneRollback(db, example1.ID, initialSerial+1)
+	assert.Nil(t, err)
+	records, err = RecordList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, added[0].ID, records[0].ID)
+	assert.True(t, added[0].CreatedAt.Equal(records[0].CreatedAt))
+
+	// Roll back to a version that doesn't exist
+	err = ZoneRollback(db, example1.ID, 1)
+	assert.ErrorIs(t, err, ErrZoneVersionNotFound)
+
+	// Rolling back deletes the dynamic DNS credentials of records that aren't restored
+	example1, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	beforeHome := example1.Serial
+	home := &Record{Type: "A", Label: "home", Value: "192.0.2.2", TTL: 300, ZoneID: example1.ID}
+	err = RecordAdd(db, home)
+	assert.Nil(t, err)
+	_, _, err = DynDNSCredentialAdd(db, home)
+	assert.Nil(t, err)
+	err = ZoneRollback(db, example1.ID, beforeHome)
+	assert.Nil(t, err)
+	credentials, err := DynDNSCredentialList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, credentials)
+
+	// Secondary zones can't be rolled back
+	err = ZoneSetSecondary(db, example1.ID, []string{"192.0.2.1"}, TSIGKey{})
+	assert.Nil(t, err)
+	err = ZoneRollback(db, example1.ID, initialSerial)
+	assert.ErrorIs(t, err, ErrSecondaryZone)
+}
+
+func TestZoneVersionRetention(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	zone, err := ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+
+	// Only the newest versions are kept
+	for i := 0; i < ZoneVersionRetention+5; i++ {
+		err = ZoneIncrementSerial(db, zone.ID)
+		assert.Nil(t, err)
+	}
+	versions, err := ZoneVersionList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, ZoneVersionRetention, len(versions))
+	zone, err = ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, zone.Serial, versions[0].Serial)
+	_, err = ZoneVersionFind(db, zone.ID, zone.Serial-ZoneVersionRetention)
+	assert.ErrorIs(t, err, ErrZoneVersionNotFound)
+}
+
+func TestZoneVersionRecordList(t *testing.T) {
+	// Versions stored before the creation time was added decode with a zero creation time
+	v := ZoneVersion{Records: `[{"id":"1","type":"A","label":"@","value":"192.0.2.1","ttl":300},{"id":"2","type":"A","label":"www","value":"192.0.2.2","ttl":300,"created_at":"2022-01-02T03:04:05Z"}]`}
+	records, err := v.RecordList()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.True(t, records[0].CreatedAt.IsZero())
+	assert.Equal(t, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), records[1].CreatedAt)
+	assert.Equal(t, "192.0.2.2", records[1].Value)
+}
+
+func TestDiffRecords(t *testing.T) {
+	before := []Record{
+		{ID: "1", Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300},
+		{ID: "2", Type: "A", Label: "www", Value: "192.0.2.2", TTL: 300},
+		{ID: "3", Type: "TXT", Label: "@", Value: `"unchanged"`, TTL: 300},
+		{ID: "5", Type: "A", Label: "lb", Value: "192.0.2.5", TTL: 300, Policy: PolicyWeighted, Weight: 10},
+		{ID: "6", Type: "A", Label: "lb", Value: "192.0.2.6", TTL: 300, HealthCheck: HealthCheck{Type: "tcp", Port: 80}},
+	}
+	after := []Record{
+		{ID: "1", Type: "A", Label: "@", Value: "192.0.2.3", TTL: 300},
+		{ID: "3", Type: "TXT", Label: "@", Value: `"unchanged"`, TTL: 300},
+		{ID: "4", Type: "AAAA", Label: "@", Value: "2001:db8::1", TTL: 300},
+		{ID: "5", Type: "A", Label: "lb", Value: "192.0.2.5", TTL: 300, Policy: PolicyWeighted, Weight: 20},
+		{ID: "6", Type: "A", Label: "lb", Value: "192.0.2.6", TTL: 300, HealthCheck: HealthCheck{Type: "tcp", Port: 443}},
+	}
+
+	// Policy and health check changes are updates too
+	diff := diffRecords(before, after)
+	assert.Equal(t, 5, len(diff))
+	assert.Equal(t, "update", diff[0].Op)
+	assert.Equal(t, "192.0.2.1", diff[0].Before.Value)
+	assert.Equal(t, "192.0.2.3", diff[0].After.Value)
+	assert.Equal(t, "add", diff[1].Op)
+	assert.Equal(t, "4", diff[1].After.ID)
+	assert.Equal(t, "update", diff[2].Op)
+	assert.Equal(t, uint32(20), diff[2].After.Weight)
+	assert.Equal(t, "update", diff[3].Op)
+	assert.Equal(t, uint16(443), diff[3].After.HealthCheck.Port)
+	assert.Equal(t, "delete", diff[4].Op)
+	assert.Equal(t, "2", diff[4].Before.ID)
+}
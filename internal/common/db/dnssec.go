@@ -0,0 +1,241 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultDNSSECAlgorithm is the DNSSEC algorithm used for new zones
+const DefaultDNSSECAlgorithm = dns.ECDSAP256SHA256
+
+// DNSSECAlgorithms maps supported DNSSEC algorithms to their key sizes
+var DNSSECAlgorithms = map[uint8]int{
+	dns.RSASHA256:       2048,
+	dns.ECDSAP256SHA256: 256,
+	dns.ECDSAP384SHA384: 384,
+	dns.ED25519:         256,
+}
+
+var (
+	ZSKRotateInterval = 30 * 24 * time.Hour // Time between automatic ZSK rollovers
+	ZSKRolloverPeriod = 2 * 24 * time.Hour  // Time both ZSKs sign the zone, longer than any TTL so cached signatures remain valid
+)
+
+var (
+	ErrInvalidDNSSECAlgorithm      = errors.New("unsupported DNSSEC algorithm")
+	ErrDNSSECRolloverInProgress    = errors.New("a DNSSEC key rollover is already in progress")
+	ErrDNSSECRolloverNotInProgress = errors.New("no DNSSEC key rollover is in progress")
+	ErrDNSSECKeysChanged           = errors.New("the zone's DNSSEC keys were changed by another request, try again")
+)
+
+// dnssecKeyColumns are the columns written by zoneSaveKeys, so other zone fields changed while keys are generated aren't overwritten
+var dnssecKeyColumns = func() []string {
+	columns := []string{"dnssec_algorithm", "zsk_rotated_at"}
+	for _, prefix := range []string{"", "dnssec_next_", "zsk_", "zsk_next_"} {
+		for _, column := range []string{"base", "key", "private", "ds_key_tag", "ds_algo", "ds_digest_type", "ds_digest", "ds_record_string"} {
+			columns = append(columns, prefix+column)
+		}
+	}
+	return columns
+}()
+
+// DNSSECRolloverInProgress checks if the zone has a new key published alongside its current key
+func (z *Zone) DNSSECRolloverInProgress() bool {
+	return z.DNSSECNext.Key != ""
+}
+
+// dnssecAlgorithmRollover checks if the KSK rollover in progress changes the zone's algorithm
+func (z *Zone) dnssecAlgorithmRollover() bool {
+	return z.DNSSECRolloverInProgress() && uint8(z.DNSSECNext.DSAlgo) != z.DNSSECAlgorithm
+}
+
+// DNSSECKeys returns the full DNSKEY set published in the zone, which includes the next keys during a rollover
+func (z *Zone) DNSSECKeys() []DNSSECKey {
+	var keys []DNSSECKey
+	for _, key := range []DNSSECKey{z.DNSSEC, z.DNSSECNext, z.ZSK, z.ZSKNext} {
+		if key.Key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// DNSKEYs returns the DNSKEY records published in the zone
+func (z *Zone) DNSKEYs() ([]dns.RR, error) {
+	var rrs []dns.RR
+	for _, key := range z.DNSSECKeys() {
+		rr, err := dns.NewRR(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// dnssecKeyState identifies a zone's algorithm and keys to detect concurrent key changes
+func (z *Zone) dnssecKeyState() string {
+	return fmt.Sprintf("%d %s %s %s %s", z.DNSSECAlgorithm, z.DNSSEC.Key, z.DNSSECNext.Key, z.ZSK.Key, z.ZSKNext.Key)
+}
+
+// zoneSaveKeys saves a zone's keys and increments the serial so the new DNSKEY set is published.
+// loaded is the dnssecKeyState of the zone when it was read, and ErrDNSSECKeysChanged is returned if the stored keys no longer match it.
+func zoneSaveKeys(db *gorm.DB, zone *Zone, loaded string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current Zone
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", zone.ID).Error; err != nil {
+			return err
+		}
+		if current.dnssecKeyState() != loaded {
+			return ErrDNSSECKeysChanged
+		}
+		if err := tx.Model(&Zone{ID: zone.ID}).Select(dnssecKeyColumns).Updates(zone).Error; err != nil {
+			return err
+		}
+		return ZoneIncrementSerial(tx, zone.ID)
+	})
+}
+
+// ZoneDNSSECRolloverStart generates a new KSK and publishes it alongside the current KSK. Both keys sign the zone until the rollover is confirmed.
+// If algorithm differs from the zone's current algorithm, a ZSK with the new algorithm is also introduced so the zone is fully signed with both algorithms.
+func ZoneDNSSECRolloverStart(db *gorm.DB, zoneID string, algorithm uint8) error {
+	var zone Zone
+	if err := db.First(&zone, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+	loaded := zone.dnssecKeyState()
+	if zone.DNSSECRolloverInProgress() {
+		return ErrDNSSECRolloverInProgress
+	}
+	if algorithm == 0 {
+		algorithm = zone.DNSSECAlgorithm
+	}
+	if _, ok := DNSSECAlgorithms[algorithm]; !ok {
+		return ErrInvalidDNSSECAlgorithm
+	}
+
+	if algorithm != zone.DNSSECAlgorithm {
+		// The ZSK slot is needed for the new algorithm
+		if zone.ZSKNext.Key != "" {
+			return ErrDNSSECRolloverInProgress
+		}
+		zsk, err := NewKey(zone.Zone, algorithm, false)
+		if err != nil {
+			return err
+		}
+		zone.ZSKNext = *zsk
+	}
+
+	ksk, err := NewKey(zone.Zone, algorithm, true)
+	if err != nil {
+		return err
+	}
+	zone.DNSSECNext = *ksk
+	return zoneSaveKeys(db, &zone, loaded)
+}
+
+// ZoneDNSSECRolloverConfirm replaces the current KSK with the next KSK once the new DS record is live at the parent
+func ZoneDNSSECRolloverConfirm(db *gorm.DB, zoneID string) error {
+	var zone Zone
+	if err := db.First(&zone, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+	loaded := zone.dnssecKeyState()
+	if !zone.DNSSECRolloverInProgress() {
+		return ErrDNSSECRolloverNotInProgress
+	}
+
+	if zone.dnssecAlgorithmRollover() {
+		zone.DNSSECAlgorithm = uint8(zone.DNSSECNext.DSAlgo)
+		zone.ZSK = zone.ZSKNext
+		zone.ZSKNext = DNSSECKey{}
+		zone.ZSKRotatedAt = time.Now()
+	}
+	zone.DNSSEC = zone.DNSSECNext
+	zone.DNSSECNext = DNSSECKey{}
+	return zoneSaveKeys(db, &zone, loaded)
+}
+
+// ZoneDNSSECRolloverCancel stops publishing the next KSK and keeps the current KSK
+func ZoneDNSSECRolloverCancel(db *gorm.DB, zoneID string) error {
+	var zone Zone
+	if err := db.First(&zone, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+	loaded := zone.dnssecKeyState()
+	if !zone.DNSSECRolloverInProgress() {
+		return ErrDNSSECRolloverNotInProgress
+	}
+
+	if zone.dnssecAlgorithmRollover() {
+		zone.ZSKNext = DNSSECKey{}
+	}
+	zone.DNSSECNext = DNSSECKey{}
+	return zoneSaveKeys(db, &zone, loaded)
+}
+
+// zoneRotateZSK advances a zone's automatic double-signature ZSK rollover and returns true if the keys changed
+func zoneRotateZSK(zone *Zone, now time.Time) (bool, error) {
+	// Leave the ZSKs alone while the user is rolling the KSK
+	if zone.DNSSECRolloverInProgress() {
+		return false, nil
+	}
+
+	switch {
+	case zone.ZSK.Key == "":
+		// Zones created before the KSK/ZSK split sign with the KSK only
+		zsk, err := NewKey(zone.Zone, zone.DNSSECAlgorithm, false)
+		if err != nil {
+			return false, err
+		}
+		zone.ZSK = *zsk
+	case zone.ZSKNext.Key == "" && now.Sub(zone.ZSKRotatedAt) >= ZSKRotateInterval:
+		zsk, err := NewKey(zone.Zone, zone.DNSSECAlgorithm, false)
+		if err != nil {
+			return false, err
+		}
+		zone.ZSKNext = *zsk
+	case zone.ZSKNext.Key != "" && now.Sub(zone.ZSKRotatedAt) >= ZSKRolloverPeriod:
+		zone.ZSK = zone.ZSKNext
+		zone.ZSKNext = DNSSECKey{}
+	default:
+		return false, nil
+	}
+
+	zone.ZSKRotatedAt = now
+	return true, nil
+}
+
+// ZoneRotateZSKs advances the automatic ZSK rollover of all served primary zones
+func ZoneRotateZSKs(db *gorm.DB) error {
+	zones, err := ZoneList(db)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range zones {
+		// Secondary zones are signed by their primaries and pending zones aren't served
+		if zones[i].IsSecondary() || zones[i].PendingVerification {
+			continue
+		}
+
+		loaded := zones[i].dnssecKeyState()
+		changed, err := zoneRotateZSK(&zones[i], now)
+		if err != nil {
+			return err
+		}
+		if changed {
+			// Keys changed by a user since the zones were listed are rotated on the next run
+			if err := zoneSaveKeys(db, &zones[i], loaded); err != nil && !errors.Is(err, ErrDNSSECKeysChanged) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneDNSSECRollover(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	oldKey := zone.DNSSEC
+	assert.Equal(t, 2, len(zone.DNSSECKeys()))
+
+	// Confirming or cancelling without a rollover fails
+	assert.ErrorIs(t, ZoneDNSSECRolloverConfirm(db, zone.ID), ErrDNSSECRolloverNotInProgress)
+	assert.ErrorIs(t, ZoneDNSSECRolloverCancel(db, zone.ID), ErrDNSSECRolloverNotInProgress)
+
+	// Start a rollover, both keys are published
+	err = ZoneDNSSECRolloverStart(db, zone.ID, 0)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ZoneDNSSECRolloverStart(db, zone.ID, 0), ErrDNSSECRolloverInProgress)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.True(t, zone.DNSSECRolloverInProgress())
+	assert.Equal(t, oldKey.Key, zone.DNSSEC.Key)
+	dnskeys, err := zone.DNSKEYs()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(dnskeys))
+	newKey := zone.DNSSECNext
+
+	// Cancel and restart the rollover
+	err = ZoneDNSSECRolloverCancel(db, zone.ID)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.False(t, zone.DNSSECRolloverInProgress())
+	assert.Equal(t, oldKey.Key, zone.DNSSEC.Key)
+	err = ZoneDNSSECRolloverStart(db, zone.ID, 0)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.NotEqual(t, newKey.Key, zone.DNSSECNext.Key)
+	newKey = zone.DNSSECNext
+
+	// Confirm the rollover, only the new key remains
+	serial := zone.Serial
+	err = ZoneDNSSECRolloverConfirm(db, zone.ID)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.False(t, zone.DNSSECRolloverInProgress())
+	assert.Equal(t, newKey.Key, zone.DNSSEC.Key)
+	assert.Equal(t, newKey.DSRecordString, zone.DNSSEC.DSRecordString)
+	assert.Equal(t, 2, len(zone.DNSSECKeys()))
+	assert.Greater(t, zone.Serial, serial)
+}
+
+func TestNewKey(t *testing.T) {
+	for algorithm := range DNSSECAlgorithms {
+		ksk, err := NewKey("example.com", algorithm, true)
+		assert.Nil(t, err)
+		rr, err := dns.NewRR(ksk.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(257), rr.(*dns.DNSKEY).Flags)
+		assert.Equal(t, algorithm, rr.(*dns.DNSKEY).Algorithm)
+		assert.Equal(t, int(algorithm), ksk.DSAlgo)
+
+		zsk, err := NewKey("example.com", algorithm, false)
+		assert.Nil(t, err)
+		rr, err = dns.NewRR(zsk.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(256), rr.(*dns.DNSKEY).Flags)
+	}
+
+	_, err := NewKey("example.com", dns.RSASHA1, true)
+	assert.ErrorIs(t, err, ErrInvalidDNSSECAlgorithm)
+}
+
+func TestZoneDNSSECAlgorithmRollover(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(DefaultDNSSECAlgorithm), zone.DNSSECAlgorithm)
+
+	assert.ErrorIs(t, ZoneDNSSECRolloverStart(db, zone.ID, dns.RSASHA1), ErrInvalidDNSSECAlgorithm)
+
+	// Both a KSK and ZSK with the new algorithm are published
+	err = ZoneDNSSECRolloverStart(db, zone.ID, dns.ED25519)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(zone.DNSSECKeys()))
+	assert.Equal(t, uint8(DefaultDNSSECAlgorithm), zone.DNSSECAlgorithm)
+	nextZSK := zone.ZSKNext
+
+	err = ZoneDNSSECRolloverConfirm(db, zone.ID)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(dns.ED25519), zone.DNSSECAlgorithm)
+	assert.Equal(t, int(dns.ED25519), zone.DNSSEC.DSAlgo)
+	assert.Equal(t, nextZSK.Key, zone.ZSK.Key)
+	assert.Equal(t, 2, len(zone.DNSSECKeys()))
+
+	// New zones are created with keys of the requested algorithm
+	zone, err = ZoneAdd(db, "example2.com", "user1@example.com", dns.RSASHA256)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(dns.RSASHA256), zone.DNSSECAlgorithm)
+	assert.Equal(t, int(dns.RSASHA256), zone.DNSSEC.DSAlgo)
+	assert.Equal(t, int(dns.RSASHA256), zone.ZSK.DSAlgo)
+	_, err = ZoneAdd(db, "example3.com", "user1@example.com", dns.RSASHA1)
+	assert.ErrorIs(t, err, ErrInvalidDNSSECAlgorithm)
+}
+
+func TestZoneRotateZSKs(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Nil(t, ZoneSetVerified(db, zone.ID))
+	oldZSK := zone.ZSK
+
+	// Pending zones aren't served, so their keys aren't rotated
+	_, err = ZoneAdd(db, "example2.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	pending, err := ZoneFind(db, "example2.com")
+	assert.Nil(t, err)
+	err = db.Model(&Zone{}).Where("id = ?", pending.ID).Update("zsk_rotated_at", time.Now().Add(-ZSKRotateInterval)).Error
+	assert.Nil(t, err)
+
+	// Nothing to do for a new zone
+	err = ZoneRotateZSKs(db)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "", zone.ZSKNext.Key)
+
+	// Introduce the next ZSK once the rotation interval has passed
+	err = db.Model(&Zone{}).Where("id = ?", zone.ID).Update("zsk_rotated_at", time.Now().Add(-ZSKRotateInterval)).Error
+	assert.Nil(t, err)
+	err = ZoneRotateZSKs(db)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, oldZSK.Key, zone.ZSK.Key)
+	assert.NotEqual(t, "", zone.ZSKNext.Key)
+	newZSK := zone.ZSKNext
+	pending, err = ZoneFind(db, "example2.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "", pending.ZSKNext.Key)
+
+	// Retire the old ZSK after the rollover period
+	err = db.Model(&Zone{}).Where("id = ?", zone.ID).Update("zsk_rotated_at", time.Now().Add(-ZSKRolloverPeriod)).Error
+	assert.Nil(t, err)
+	err = ZoneRotateZSKs(db)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, newZSK.Key, zone.ZSK.Key)
+	assert.Equal(t, "", zone.ZSKNext.Key)
+}
+
+func TestZoneSaveKeysConcurrent(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	loaded := zone.dnssecKeyState()
+
+	// A record change while keys are generated increments the serial, which must not be overwritten
+	assert.Nil(t, RecordAdd(db, &Record{Type: "A", Label: "@", Value: "192.0.2.1", TTL: 300, ZoneID: zone.ID}))
+	ksk, err := NewKey(zone.Zone, zone.DNSSECAlgorithm, true)
+	assert.Nil(t, err)
+	zone.DNSSECNext = *ksk
+	assert.Nil(t, zoneSaveKeys(db, zone, loaded))
+	saved, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, zone.Serial+2, saved.Serial)
+	assert.Equal(t, ksk.Key, saved.DNSSECNext.Key)
+
+	// Saving keys derived from the old keys fails
+	zone.DNSSECNext = DNSSECKey{}
+	assert.Equal(t, ErrDNSSECKeysChanged, zoneSaveKeys(db, zone, loaded))
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// DynamicState stores the answers of a zone that change without its records changing, which is the health of its records.
+// The API aggregates the health reported by edge nodes and increments the serial when it changes, so every edge node renders the same zone under the same serial.
+type DynamicState struct {
+	Unhealthy []string `json:"unhealthy,omitempty"` // Sorted IDs of unhealthy records
+}
+
+// SameAnswers checks if two states render the same zone
+func (s DynamicState) SameAnswers(other DynamicState) bool {
+	a, _ := json.Marshal(s.Unhealthy)
+	b, _ := json.Marshal(other.Unhealthy)
+	return string(a) == string(b)
+}
+
+// DynamicState returns the zone's current dynamic state
+func (z *Zone) DynamicState() (DynamicState, error) {
+	var state DynamicState
+	if z.Dynamic == "" {
+		return state, nil
+	}
+	err := json.Unmarshal([]byte(z.Dynamic), &state)
+	return state, err
+}
+
+// ZoneListDynamic returns verified primary zones with health policy records, or with a dynamic state left from them
+func ZoneListDynamic(db *gorm.DB) ([]Zone, error) {
+	var zones []Zone
+	err := db.Where("pending_verification = ? AND kind != ?", false, ZoneKindSecondary).
+		Where("COALESCE(dynamic, '') != '' OR id IN (SELECT zone_id FROM records WHERE COALESCE(policy, '') NOT IN ('', ?))", PolicyGeo).
+		Find(&zones).Error
+	return zones, err
+}
+
+// ZoneSetDynamicState stores a zone's dynamic state and increments its serial if the answers changed. An empty state clears it.
+// Record versions aren't snapshotted as the zone's records didn't change.
+func ZoneSetDynamicState(db *gorm.DB, zoneID string, state *DynamicState, changed bool) error {
+	value := ""
+	if state != nil {
+		b, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		value = string(b)
+	}
+
+	updates := map[string]interface{}{"dynamic": value}
+	if changed {
+		updates["serial"] = gorm.Expr("serial + 1")
+	}
+	if err := db.Model(&Zone{}).Where("id = ?", zoneID).Updates(updates).Error; err != nil {
+		return err
+	}
+	if changed {
+		return zoneNotify(db, zoneID)
+	}
+	return nil
+}
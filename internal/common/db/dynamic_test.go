@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneDynamicState(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	zone, err := ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, ZoneSetVerified(db, zone.ID))
+
+	// Zones without health policy records have no dynamic state
+	dynamic, err := ZoneListDynamic(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(dynamic))
+
+	// ALIAS targets are resolved by the edge nodes
+	err = RecordAdd(db, &Record{Type: "ALIAS", Label: "@", Value: "lb.example.net.", TTL: 300, ZoneID: zone.ID})
+	assert.Nil(t, err)
+	dynamic, err = ZoneListDynamic(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(dynamic))
+
+	record := &Record{Type: "A", Label: "www", Value: "192.0.2.1", TTL: 300, ZoneID: zone.ID, Policy: PolicyFailover, HealthCheck: HealthCheck{Type: "tcp", Port: 80}}
+	err = RecordAdd(db, record)
+	assert.Nil(t, err)
+	dynamic, err = ZoneListDynamic(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(dynamic))
+
+	// Changed answers increment the serial
+	before, err := ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	state := &DynamicState{Unhealthy: []string{record.ID}}
+	assert.Nil(t, ZoneSetDynamicState(db, zone.ID, state, true))
+	after, err := ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, before.Serial+1, after.Serial)
+	stored, err := after.DynamicState()
+	assert.Nil(t, err)
+	assert.True(t, stored.SameAnswers(*state))
+
+	// Unchanged answers don't
+	assert.Nil(t, ZoneSetDynamicState(db, zone.ID, state, false))
+	unchanged, err := ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, after.Serial, unchanged.Serial)
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var ErrDynDNSRecordType = errors.New("dynamic DNS credentials can only be added to A and AAAA records")
+
+// DynDNSCredential stores a username and password allowed to update the value of a single A or AAAA record with the dyndns2 protocol.
+// Only the SHA256 hash of the password is stored.
+type DynDNSCredential struct {
+	ID        string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	RecordID  string    `gorm:"index" json:"record"`
+	Username  string    `gorm:"uniqueIndex" json:"username"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newBasicAuthCredential generates a random username and password for HTTP basic authentication and returns them with the password's SHA256 hash
+func newBasicAuthCredential() (string, string, string, error) {
+	username, err := auth.RandomString(16)
+	if err != nil {
+		return "", "", "", err
+	}
+	password, err := auth.RandomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	hash, err := util.SHA256(password)
+	if err != nil {
+		return "", "", "", err
+	}
+	return username, password, hash, nil
+}
+
+// checkCredentialHash checks if a password matches a stored SHA256 hash in constant time
+func checkCredentialHash(hash, password string) bool {
+	passwordHash, err := util.SHA256(password)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(passwordHash)) == 1
+}
+
+// DynDNSCredentialAdd creates a credential for an A or AAAA record and returns the plaintext password, which can't be retrieved again
+func DynDNSCredentialAdd(db *gorm.DB, record *Record) (*DynDNSCredential, string, error) {
+	if record.Type != "A" && record.Type != "AAAA" {
+		return nil, "", ErrDynDNSRecordType
+	}
+
+	username, password, hash, err := newBasicAuthCredential()
+	if err != nil {
+		return nil, "", err
+	}
+
+	credential := &DynDNSCredential{ZoneID: record.ZoneID, RecordID: record.ID, Username: username, Hash: hash}
+	if err := db.Create(credential).Error; err != nil {
+		return nil, "", err
+	}
+	return credential, password, nil
+}
+
+// DynDNSCredentialFind finds a credential by username and password and returns nil if the credentials are invalid
+func DynDNSCredentialFind(db *gorm.DB, username, password string) (*DynDNSCredential, error) {
+	var credentials []DynDNSCredential
+	if err := db.Limit(1).Find(&credentials, "username = ?", username).Error; err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 || !checkCredentialHash(credentials[0].Hash, password) {
+		return nil, nil
+	}
+	return &credentials[0], nil
+}
+
+// DynDNSCredentialList returns a zone's dynamic DNS credentials
+func DynDNSCredentialList(db *gorm.DB, zoneID string) ([]DynDNSCredential, error) {
+	var credentials []DynDNSCredential
+	err := db.Order("created_at").Find(&credentials, "zone_id = ?", zoneID).Error
+	return credentials, err
+}
+
+// DynDNSCredentialDelete revokes a dynamic DNS credential
+func DynDNSCredentialDelete(db *gorm.DB, zoneID, credentialID string) (bool, error) {
+	res := db.Where("id = ? AND zone_id = ?", credentialID, zoneID).Delete(&DynDNSCredential{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynDNSCredentials(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+
+	record := &Record{Type: "A", Label: "home", Value: "192.0.2.1", TTL: 300, ZoneID: zone.ID}
+	err = RecordAdd(db, record)
+	assert.Nil(t, err)
+	txt := &Record{Type: "TXT", Label: "home", Value: `"example"`, TTL: 300, ZoneID: zone.ID}
+	err = RecordAdd(db, txt)
+	assert.Nil(t, err)
+
+	_, _, err = DynDNSCredentialAdd(db, txt)
+	assert.Equal(t, ErrDynDNSRecordType, err)
+
+	credential, password, err := DynDNSCredentialAdd(db, record)
+	assert.Nil(t, err)
+	assert.Equal(t, record.ID, credential.RecordID)
+	assert.NotEmpty(t, credential.Username)
+	assert.NotEmpty(t, password)
+
+	found, err := DynDNSCredentialFind(db, credential.Username, password)
+	assert.Nil(t, err)
+	assert.Equal(t, credential.ID, found.ID)
+	found, err = DynDNSCredentialFind(db, credential.Username, "wrong password")
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	credentials, err := DynDNSCredentialList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(credentials))
+
+	// Credentials are revoked with their record
+	deleted, err := RecordDelete(db, record.ZoneID, record.ID)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	credentials, err = DynDNSCredentialList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, credentials)
+
+	deleted, err = DynDNSCredentialDelete(db, zone.ID, credential.ID)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// Geo match kinds, from most to least specific
+const (
+	GeoASN       = "asn"       // Autonomous system number, such as asn:13335
+	GeoCountry   = "country"   // ISO 3166-1 country code, such as country:US
+	GeoContinent = "continent" // MaxMind continent code, such as continent:EU
+	GeoDefault   = "default"   // Clients that don't match any other record
+)
+
+// GeoContinents lists the MaxMind continent codes
+var GeoContinents = []string{"AF", "AN", "AS", "EU", "NA", "OC", "SA"}
+
+var ErrInvalidGeo = errors.New("invalid geo match, must be default, continent:<code>, country:<ISO code> or asn:<number>")
+
+// GeoMatch stores the clients a geo record answers
+type GeoMatch struct {
+	Kind  string
+	Value string
+}
+
+// ParseGeo parses a geo match such as country:US
+func ParseGeo(s string) (GeoMatch, error) {
+	if strings.EqualFold(s, GeoDefault) {
+		return GeoMatch{Kind: GeoDefault}, nil
+	}
+
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return GeoMatch{}, ErrInvalidGeo
+	}
+	match := GeoMatch{Kind: strings.ToLower(parts[0]), Value: strings.ToUpper(parts[1])}
+	switch match.Kind {
+	case GeoContinent:
+		if !util.StrSliceContains(GeoContinents, match.Value) {
+			return GeoMatch{}, ErrInvalidGeo
+		}
+	case GeoCountry:
+		if len(match.Value) != 2 || strings.Trim(match.Value, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return GeoMatch{}, ErrInvalidGeo
+		}
+	case GeoASN:
+		if _, err := strconv.ParseUint(match.Value, 10, 32); err != nil {
+			return GeoMatch{}, ErrInvalidGeo
+		}
+	default:
+		return GeoMatch{}, ErrInvalidGeo
+	}
+	return match, nil
+}
+
+// RecordListPerQuery returns all geo and weighted records in verified zones with their zones populated
+func RecordListPerQuery(db *gorm.DB) ([]Record, error) {
+	var records []Record
+	if err := db.Order("created_at").Where("policy IN ?", []string{PolicyGeo, PolicyWeighted}).Joins("Zone").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	var verified []Record
+	for _, record := range records {
+		if !record.Zone.PendingVerification {
+			verified = append(verified, record)
+		}
+	}
+	return verified, nil
+}
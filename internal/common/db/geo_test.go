@@ -0,0 +1,26 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeo(t *testing.T) {
+	for s, expected := range map[string]GeoMatch{
+		"default":      {Kind: GeoDefault},
+		"DEFAULT":      {Kind: GeoDefault},
+		"continent:eu": {Kind: GeoContinent, Value: "EU"},
+		"Country:us":   {Kind: GeoCountry, Value: "US"},
+		"asn:13335":    {Kind: GeoASN, Value: "13335"},
+	} {
+		match, err := ParseGeo(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, match, s)
+	}
+
+	for _, s := range []string{"", "continent:XX", "country:USA", "country:1A", "asn:AS13335", "city:London"} {
+		_, err := ParseGeo(s)
+		assert.Equal(t, ErrInvalidGeo, err, s)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Zone health statuses
+const (
+	HealthDelegated  = "delegated"   // Delegated to the zone's nameservers with a matching DS record
+	HealthLame       = "lame"        // Not delegated to any of the zone's nameservers
+	HealthNSMismatch = "ns_mismatch" // Delegated to some of the zone's nameservers, but not exactly the zone's nameservers
+	HealthDSMismatch = "ds_mismatch" // DS records at the parent don't match any of the zone's keys
+	HealthDSMissing  = "ds_missing"  // Delegated to the zone's nameservers but no DS record at the parent
+	HealthError      = "error"       // Parent zone couldn't be queried
+	HealthUnknown    = "unknown"     // Not checked yet
+)
+
+// HealthStatuses lists all zone health statuses
+var HealthStatuses = []string{HealthDelegated, HealthLame, HealthNSMismatch, HealthDSMismatch, HealthDSMissing, HealthError, HealthUnknown}
+
+// ZoneHealth stores the result of the last delegation and DNSSEC check of a zone
+type ZoneHealth struct {
+	ZoneID      string         `gorm:"primaryKey;type:uuid" json:"zone"`
+	Status      string         `json:"status"`
+	Nameservers pq.StringArray `gorm:"type:text[]" json:"nameservers"` // NS records at the parent
+	DS          pq.StringArray `gorm:"type:text[]" json:"ds"`          // DS records at the parent
+	Error       string         `json:"error,omitempty"`
+	CheckedAt   time.Time      `json:"checked_at"`
+}
+
+// ZoneHealthSet stores the result of a zone health check
+func ZoneHealthSet(db *gorm.DB, health *ZoneHealth) error {
+	return db.Save(health).Error
+}
+
+// ZoneHealthGet gets a zone's health and returns HealthUnknown if the zone hasn't been checked
+func ZoneHealthGet(db *gorm.DB, zoneID string) (*ZoneHealth, error) {
+	var health []ZoneHealth
+	if err := db.Limit(1).Find(&health, "zone_id = ?", zoneID).Error; err != nil {
+		return nil, err
+	}
+	if len(health) == 0 {
+		return &ZoneHealth{ZoneID: zoneID, Status: HealthUnknown}, nil
+	}
+	return &health[0], nil
+}
+
+// ZoneHealthCounts counts the number of verified zones in each health status
+func ZoneHealthCounts(db *gorm.DB) (map[string]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := db.Table("zones").
+		Select("COALESCE(zone_healths.status, ?) AS status, COUNT(*) AS count", HealthUnknown).
+		Joins("LEFT JOIN zone_healths ON zone_healths.zone_id = zones.id").
+		Where("zones.pending_verification = ?", false).
+		Group("1").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, status := range HealthStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneHealth(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	for _, zone := range []string{"example1.com", "example2.com", "example3.com"} {
+		_, err = ZoneAdd(db, zone, "user1@example.com", 0)
+		assert.Nil(t, err)
+	}
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	example2, err := ZoneFind(db, "example2.com")
+	assert.Nil(t, err)
+	assert.Nil(t, ZoneSetVerified(db, example1.ID))
+	assert.Nil(t, ZoneSetVerified(db, example2.ID))
+
+	// Unchecked zones are unknown
+	health, err := ZoneHealthGet(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, HealthUnknown, health.Status)
+
+	err = ZoneHealthSet(db, &ZoneHealth{ZoneID: example1.ID, Status: HealthLame, Nameservers: []string{"ns1.example.net."}, CheckedAt: time.Now()})
+	assert.Nil(t, err)
+	health, err = ZoneHealthGet(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, HealthLame, health.Status)
+	assert.Equal(t, []string{"ns1.example.net."}, []string(health.Nameservers))
+
+	// Pending zones aren't counted
+	counts, err := ZoneHealthCounts(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, counts[HealthLame])
+	assert.Equal(t, 1, counts[HealthUnknown])
+	assert.Equal(t, 0, counts[HealthDelegated])
+
+	// Deleting a zone deletes its health
+	_, err = ZoneDelete(db, example1.ID)
+	assert.Nil(t, err)
+	counts, err = ZoneHealthCounts(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, counts[HealthLame])
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ZoneChangesChannel is the Postgres notification channel edge nodes listen on to render changed zones without waiting for the next zone refresh
+const ZoneChangesChannel = "zone_changes"
+
+// zoneNotify notifies listening edge nodes that a zone changed. Notifications sent in a transaction are delivered when it commits.
+func zoneNotify(db *gorm.DB, zoneID string) error {
+	return db.Exec("SELECT pg_notify(?, ?)", ZoneChangesChannel, zoneID).Error
+}
+
+// ListenZoneChanges calls changed for each zone change notification, and after reconnecting to the database as notifications may have been missed while disconnected
+func ListenZoneChanges(dsn string, changed func()) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Warnf("zone change listener: %s", err)
+		}
+	})
+	if err := listener.Listen(ZoneChangesChannel); err != nil {
+		return err
+	}
+	for range listener.Notify {
+		changed()
+	}
+	return nil
+}
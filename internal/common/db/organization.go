@@ -0,0 +1,225 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound           = errors.New("organization not found")
+	ErrUserExistingOrganizationMember = errors.New("user is already a member of this organization")
+	ErrLastOrganizationOwner          = errors.New("unable to remove or demote the last owner of an organization")
+	ErrOrganizationOwnsZones          = errors.New("organization owns zones, remove the zones from this organization before deleting it")
+	ErrUserOwnsOrganizations          = errors.New("user is the last owner of organizations with other members or zones, add another owner to these organizations before deleting this user account")
+)
+
+// Organization stores a group of users that can own zones together
+type Organization struct {
+	ID         string         `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	Name       string         `json:"name" validate:"required,min=1,max=64"`
+	UserEmails pq.StringArray `gorm:"type:text[]" json:"user_emails"`
+	UserRoles  pq.StringArray `gorm:"type:text[]" json:"user_roles"`
+	CreatedAt  time.Time      `json:"-"`
+	UpdatedAt  time.Time      `json:"-"`
+}
+
+// OrganizationMember stores a user's membership and role in an organization
+type OrganizationMember struct {
+	OrganizationID string `gorm:"primaryKey" json:"organization"`
+	UserID         string `gorm:"primaryKey" json:"user"`
+	Role           string `json:"role"`
+}
+
+// OrganizationAdd creates a new organization with the given user as its owner
+func OrganizationAdd(db *gorm.DB, name string, ownerEmail string) (*Organization, error) {
+	u, err := UserFindByEmail(db, ownerEmail)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+
+	org := Organization{Name: name}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{OrganizationID: org.ID, UserID: u.ID, Role: RoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// OrganizationDelete deletes an organization that doesn't own any zones
+func OrganizationDelete(db *gorm.DB, orgID string) error {
+	var zones int64
+	if err := db.Model(&Zone{}).Where("organization_id = ?", orgID).Count(&zones).Error; err != nil {
+		return err
+	}
+	if zones > 0 {
+		return ErrOrganizationOwnsZones
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&OrganizationMember{}, "organization_id = ?", orgID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, "id = ?", orgID).Error
+	})
+}
+
+// OrganizationUserGetOrganizations gets all organizations a user is a member of along with their members
+func OrganizationUserGetOrganizations(db *gorm.DB, userUuid string) ([]Organization, error) {
+	var orgs []Organization
+	tx := db.Raw(`SELECT o.*,array_agg(u.email) user_emails,array_agg(m.role) user_roles
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		JOIN users u ON u.id = m.user_id
+		WHERE o.id IN (SELECT organization_id FROM organization_members WHERE user_id = ?)
+		GROUP BY o.id;`, userUuid).Scan(&orgs)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return orgs, nil
+}
+
+// OrganizationUserRole gets an organization member's role
+func OrganizationUserRole(db *gorm.DB, orgID string, userUuid string) (string, error) {
+	var members []OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userUuid).Find(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", ErrOrganizationNotFound
+	}
+	return members[0].Role, nil
+}
+
+// OrganizationUserAdd adds a user to an organization with a role
+func OrganizationUserAdd(db *gorm.DB, orgID string, userEmail string, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	u, err := UserFindByEmail(db, userEmail)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+
+	if _, err := OrganizationUserRole(db, orgID, u.ID); err == nil {
+		return ErrUserExistingOrganizationMember
+	} else if !errors.Is(err, ErrOrganizationNotFound) {
+		return err
+	}
+
+	return db.Create(&OrganizationMember{OrganizationID: orgID, UserID: u.ID, Role: role}).Error
+}
+
+// OrganizationUserSetRole changes the role of an existing organization member
+func OrganizationUserSetRole(db *gorm.DB, orgID string, userEmail string, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	u, err := UserFindByEmail(db, userEmail)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+
+	currentRole, err := OrganizationUserRole(db, orgID, u.ID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	// Prevent the last owner from being demoted
+	if currentRole == RoleOwner && role != RoleOwner {
+		owners, err := organizationOwnerCount(db, orgID)
+		if err != nil {
+			return err
+		}
+		if owners == 1 {
+			return ErrLastOrganizationOwner
+		}
+	}
+
+	return db.Model(&OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, u.ID).
+		Update("role", role).Error
+}
+
+// OrganizationUserDelete removes a user from an organization, revoking access to all of the organization's zones
+func OrganizationUserDelete(db *gorm.DB, orgID string, userEmail string) error {
+	u, err := UserFindByEmail(db, userEmail)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+
+	role, err := OrganizationUserRole(db, orgID, u.ID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	// Prevent the last owner from being removed
+	if role == RoleOwner {
+		owners, err := organizationOwnerCount(db, orgID)
+		if err != nil {
+			return err
+		}
+		if owners == 1 {
+			return ErrLastOrganizationOwner
+		}
+	}
+
+	return db.Delete(&OrganizationMember{}, "organization_id = ? AND user_id = ?", orgID, u.ID).Error
+}
+
+// organizationOwnerCount counts the owners of an organization
+func organizationOwnerCount(db *gorm.DB, orgID string) (int64, error) {
+	var owners int64
+	err := db.Model(&OrganizationMember{}).Where("organization_id = ? AND role = ?", orgID, RoleOwner).Count(&owners).Error
+	return owners, err
+}
+
+// ZoneSetOrganization sets the organization that owns a zone, or removes the zone from its organization if orgID is empty
+func ZoneSetOrganization(db *gorm.DB, zoneID string, orgID string) error {
+	var z Zone
+	if err := db.First(&z, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+
+	if orgID == "" {
+		// Make sure the zone still has an owner once the organization is removed
+		owners, err := zoneOwners(db, &z)
+		if err != nil {
+			return err
+		}
+		if len(owners) == 0 {
+			return ErrLastZoneOwner
+		}
+	} else {
+		var org Organization
+		if err := db.First(&org, "id = ?", orgID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return err
+		}
+	}
+
+	return db.Model(&Zone{}).Where("id = ?", zoneID).Update("organization_id", orgID).Error
+}
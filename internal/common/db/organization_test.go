@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOrganizationZones tests accessing zones through organization membership
+func TestOrganizationZones(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	// Create user1@example.com and user2@example.com
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	err = UserAdd(db, "user2@example.com", "password2", "example referrer")
+	assert.Nil(t, err)
+	user2, err := UserFindByEmail(db, "user2@example.com")
+	assert.Nil(t, err)
+
+	// Create an organization and add user2 as an editor
+	org, err := OrganizationAdd(db, "Example", user1.Email)
+	assert.Nil(t, err)
+	err = OrganizationUserAdd(db, org.ID, user2.Email, RoleEditor)
+	assert.Nil(t, err)
+	err = OrganizationUserAdd(db, org.ID, user2.Email, RoleEditor)
+	assert.ErrorIs(t, err, ErrUserExistingOrganizationMember)
+
+	orgs, err := OrganizationUserGetOrganizations(db, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(orgs))
+	assert.Equal(t, 2, len(orgs[0].UserEmails))
+
+	// Add example1.com and transfer it to the organization
+	_, err = ZoneAdd(db, "example1.com", user1.Email, 0)
+	assert.Nil(t, err)
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ZoneUserAuthorized(db, example1.ID, user2.ID), ErrZoneNotFound)
+	err = ZoneSetOrganization(db, example1.ID, org.ID)
+	assert.Nil(t, err)
+
+	// user2 can access the zone through the organization
+	err = ZoneUserAuthorized(db, example1.ID, user2.ID)
+	assert.Nil(t, err)
+	role, err := ZoneUserRole(db, example1.ID, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+	zones, err := ZoneUserGetZones(db, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+	assert.Equal(t, org.ID, zones[0].OrganizationID)
+
+	// The organization can't be deleted while it owns zones
+	err = OrganizationDelete(db, org.ID)
+	assert.ErrorIs(t, err, ErrOrganizationOwnsZones)
+
+	// The last organization owner can't be removed or demoted
+	err = OrganizationUserDelete(db, org.ID, user1.Email)
+	assert.ErrorIs(t, err, ErrLastOrganizationOwner)
+	err = OrganizationUserSetRole(db, org.ID, user1.Email, RoleViewer)
+	assert.ErrorIs(t, err, ErrLastOrganizationOwner)
+
+	// user1 can't be deleted while they are the last owner of the organization
+	err = UserDelete(db, user1.Email)
+	assert.ErrorIs(t, err, ErrUserOwnsOrganizations)
+
+	// Removing user2 from the organization revokes access to its zones
+	err = OrganizationUserDelete(db, org.ID, user2.Email)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ZoneUserAuthorized(db, example1.ID, user2.ID), ErrZoneNotFound)
+	zones, err = ZoneUserGetZones(db, user2.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(zones))
+
+	// Zones owned by an organization can have no direct members
+	err = ZoneUserAdd(db, example1.ID, user2.Email, RoleOwner)
+	assert.Nil(t, err)
+	err = ZoneUserDelete(db, example1.ID, user1.Email)
+	assert.Nil(t, err)
+	role, err = ZoneUserRole(db, example1.ID, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, RoleOwner, role)
+
+	// Remove the zone from the organization
+	err = ZoneSetOrganization(db, example1.ID, "")
+	assert.Nil(t, err)
+	err = OrganizationDelete(db, org.ID)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, ZoneUserAuthorized(db, example1.ID, user1.ID), ErrZoneNotFound)
+}
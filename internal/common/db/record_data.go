@@ -0,0 +1,292 @@
+package db
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// recordData is the structured form of a record value for a single RR type
+type recordData interface {
+	toRR() (dns.RR, error)
+	fromRR(rr dns.RR)
+}
+
+// recordDataTypes maps RR types with structured fields to a constructor of their fields
+var recordDataTypes = map[string]func() recordData{
+	"CAA":   func() recordData { return &caaData{} },
+	"TLSA":  func() recordData { return &tlsaData{} },
+	"SSHFP": func() recordData { return &sshfpData{} },
+	"DS":    func() recordData { return &dsData{} },
+	"NAPTR": func() recordData { return &naptrData{} },
+	"LOC":   func() recordData { return &locData{HorizontalPrecision: 10000, VerticalPrecision: 10, Size: 1} },
+	"HTTPS": func() recordData { return &svcbData{https: true} },
+	"SVCB":  func() recordData { return &svcbData{} },
+}
+
+// validHex checks that a field is a non-empty hex string
+func validHex(field, value string) error {
+	if _, err := hex.DecodeString(value); err != nil || value == "" {
+		return fmt.Errorf("%s must be a hex string", field)
+	}
+	return nil
+}
+
+type caaData struct {
+	Flag  uint8  `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+func (d *caaData) toRR() (dns.RR, error) {
+	return &dns.CAA{Flag: d.Flag, Tag: d.Tag, Value: d.Value}, nil
+}
+
+func (d *caaData) fromRR(rr dns.RR) {
+	caa := rr.(*dns.CAA)
+	*d = caaData{Flag: caa.Flag, Tag: caa.Tag, Value: caa.Value}
+}
+
+type tlsaData struct {
+	Usage        uint8  `json:"usage"`
+	Selector     uint8  `json:"selector"`
+	MatchingType uint8  `json:"matching_type"`
+	Certificate  string `json:"certificate"`
+}
+
+func (d *tlsaData) toRR() (dns.RR, error) {
+	if err := validHex("certificate", d.Certificate); err != nil {
+		return nil, err
+	}
+	return &dns.TLSA{Usage: d.Usage, Selector: d.Selector, MatchingType: d.MatchingType, Certificate: d.Certificate}, nil
+}
+
+func (d *tlsaData) fromRR(rr dns.RR) {
+	tlsa := rr.(*dns.TLSA)
+	*d = tlsaData{Usage: tlsa.Usage, Selector: tlsa.Selector, MatchingType: tlsa.MatchingType, Certificate: tlsa.Certificate}
+}
+
+type sshfpData struct {
+	Algorithm   uint8  `json:"algorithm"`
+	Type        uint8  `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (d *sshfpData) toRR() (dns.RR, error) {
+	if err := validHex("fingerprint", d.Fingerprint); err != nil {
+		return nil, err
+	}
+	return &dns.SSHFP{Algorithm: d.Algorithm, Type: d.Type, FingerPrint: d.Fingerprint}, nil
+}
+
+func (d *sshfpData) fromRR(rr dns.RR) {
+	sshfp := rr.(*dns.SSHFP)
+	*d = sshfpData{Algorithm: sshfp.Algorithm, Type: sshfp.Type, Fingerprint: sshfp.FingerPrint}
+}
+
+type dsData struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  uint8  `json:"algorithm"`
+	DigestType uint8  `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+func (d *dsData) toRR() (dns.RR, error) {
+	if err := validHex("digest", d.Digest); err != nil {
+		return nil, err
+	}
+	return &dns.DS{KeyTag: d.KeyTag, Algorithm: d.Algorithm, DigestType: d.DigestType, Digest: d.Digest}, nil
+}
+
+func (d *dsData) fromRR(rr dns.RR) {
+	ds := rr.(*dns.DS)
+	*d = dsData{KeyTag: ds.KeyTag, Algorithm: ds.Algorithm, DigestType: ds.DigestType, Digest: ds.Digest}
+}
+
+type naptrData struct {
+	Order       uint16 `json:"order"`
+	Preference  uint16 `json:"preference"`
+	Flags       string `json:"flags"`
+	Service     string `json:"service"`
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+func (d *naptrData) toRR() (dns.RR, error) {
+	replacement := d.Replacement
+	if replacement == "" {
+		replacement = "."
+	}
+	return &dns.NAPTR{Order: d.Order, Preference: d.Preference, Flags: d.Flags, Service: d.Service, Regexp: d.Regexp, Replacement: dns.Fqdn(replacement)}, nil
+}
+
+func (d *naptrData) fromRR(rr dns.RR) {
+	naptr := rr.(*dns.NAPTR)
+	*d = naptrData{Order: naptr.Order, Preference: naptr.Preference, Flags: naptr.Flags, Service: naptr.Service, Regexp: naptr.Regexp, Replacement: naptr.Replacement}
+}
+
+// locData stores a location in decimal degrees and sizes in meters
+type locData struct {
+	Latitude            float64 `json:"latitude"`
+	Longitude           float64 `json:"longitude"`
+	Altitude            float64 `json:"altitude"`
+	Size                float64 `json:"size"`
+	HorizontalPrecision float64 `json:"horizontal_precision"`
+	VerticalPrecision   float64 `json:"vertical_precision"`
+}
+
+// locDegrees formats decimal degrees as LOC degrees, minutes and seconds
+func locDegrees(degrees float64, positive, negative string) string {
+	hemisphere := positive
+	if degrees < 0 {
+		hemisphere = negative
+	}
+	thousandths := int64(math.Round(math.Abs(degrees) * 3600000))
+	return fmt.Sprintf("%d %d %.3f %s", thousandths/3600000, thousandths%3600000/60000, float64(thousandths%60000)/1000, hemisphere)
+}
+
+// locSize decodes an RFC 1876 size to meters
+func locSize(size uint8) float64 {
+	return float64(size>>4) * math.Pow10(int(size&0x0f)) / 100
+}
+
+func (d *locData) toRR() (dns.RR, error) {
+	if math.Abs(d.Latitude) > 90 || math.Abs(d.Longitude) > 180 {
+		return nil, fmt.Errorf("latitude must be within ±90 and longitude within ±180")
+	}
+	return dns.NewRR(fmt.Sprintf(". 0 IN LOC %s %s %.2fm %.2fm %.2fm %.2fm",
+		locDegrees(d.Latitude, "N", "S"), locDegrees(d.Longitude, "E", "W"),
+		d.Altitude, d.Size, d.HorizontalPrecision, d.VerticalPrecision))
+}
+
+func (d *locData) fromRR(rr dns.RR) {
+	loc := rr.(*dns.LOC)
+	*d = locData{
+		Latitude:            (float64(loc.Latitude) - dns.LOC_EQUATOR) / 3600000,
+		Longitude:           (float64(loc.Longitude) - dns.LOC_PRIMEMERIDIAN) / 3600000,
+		Altitude:            float64(loc.Altitude)/100 - dns.LOC_ALTITUDEBASE,
+		Size:                locSize(loc.Size),
+		HorizontalPrecision: locSize(loc.HorizPre),
+		VerticalPrecision:   locSize(loc.VertPre),
+	}
+}
+
+// svcbData stores a SVCB or HTTPS record with its parameters as key=value strings
+type svcbData struct {
+	https bool
+
+	Priority uint16            `json:"priority"`
+	Target   string            `json:"target"`
+	Params   map[string]string `json:"params"`
+}
+
+func (d *svcbData) toRR() (dns.RR, error) {
+	rrtype := "SVCB"
+	if d.https {
+		rrtype = "HTTPS"
+	}
+	target := d.Target
+	if target == "" {
+		target = "."
+	}
+
+	// Sort parameters so the value is stable
+	var keys []string
+	for key := range d.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rdata := fmt.Sprintf("%d %s", d.Priority, dns.Fqdn(target))
+	for _, key := range keys {
+		rdata += fmt.Sprintf(" %s=%q", key, d.Params[key])
+	}
+	return dns.NewRR(fmt.Sprintf(". 0 IN %s %s", rrtype, rdata))
+}
+
+func (d *svcbData) fromRR(rr dns.RR) {
+	var svcb *dns.SVCB
+	switch rr := rr.(type) {
+	case *dns.HTTPS:
+		svcb = &rr.SVCB
+	case *dns.SVCB:
+		svcb = rr
+	}
+	d.Priority = svcb.Priority
+	d.Target = svcb.Target
+	d.Params = map[string]string{}
+	for _, kv := range svcb.Value {
+		d.Params[kv.Key().String()] = kv.String()
+	}
+}
+
+// rdata returns the presentation format RDATA of an RR
+func rdata(rr dns.RR, rrtype string) string {
+	hdr := rr.Header()
+	hdr.Name = "."
+	hdr.Rrtype = dns.StringToType[rrtype]
+	hdr.Class = dns.ClassINET
+	return strings.TrimPrefix(rr.String(), hdr.String())
+}
+
+// RecordValueFromData builds a record value from structured fields
+func RecordValueFromData(rrtype string, data map[string]interface{}) (string, error) {
+	newData, ok := recordDataTypes[rrtype]
+	if !ok {
+		return "", fmt.Errorf("%s records don't support structured data", rrtype)
+	}
+
+	// Decode the fields through JSON to reuse the field names and types of the API
+	d := newData()
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(d); err != nil {
+		return "", fmt.Errorf("invalid %s data: %s", rrtype, err)
+	}
+
+	rr, err := d.toRR()
+	if err != nil {
+		return "", fmt.Errorf("invalid %s data: %s", rrtype, err)
+	}
+	value := rdata(rr, rrtype)
+
+	// Parse the value to catch invalid fields such as malformed hex
+	if _, err := dns.NewRR(fmt.Sprintf(". 300 IN %s %s", rrtype, value)); err != nil {
+		return "", fmt.Errorf("invalid %s data: %s", rrtype, err)
+	}
+	return value, nil
+}
+
+// RecordDataFromValue parses a record value into structured fields, or returns nil if the type has no structured fields or the value doesn't parse
+func RecordDataFromValue(rrtype, value string) map[string]interface{} {
+	newData, ok := recordDataTypes[rrtype]
+	if !ok {
+		return nil
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf(". 300 IN %s %s", rrtype, value))
+	if err != nil || rr == nil {
+		return nil
+	}
+	d := newData()
+	d.fromRR(rr)
+
+	encoded, err := json.Marshal(d)
+	if err != nil {
+		return nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Record set policies
+const (
+	PolicyFailover   = "failover"   // Answer with the healthy records of the lowest priority
+	PolicyWeighted   = "weighted"   // Answer with one healthy record chosen by weight, answered by edge nodes for each query
+	PolicyMultivalue = "multivalue" // Answer with all healthy records
+	PolicyGeo        = "geo"        // Answer with the records matching the client's location, answered by edge nodes for each query
+)
+
+// Policies lists all record set policies
+var Policies = []string{PolicyFailover, PolicyWeighted, PolicyMultivalue, PolicyGeo}
+
+// PerQueryPolicy checks if the answers of a record set policy are chosen by edge nodes for each query, so the set's name is delegated to them
+func PerQueryPolicy(policy string) bool {
+	return policy == PolicyGeo || policy == PolicyWeighted
+}
+
+// Health check types
+const (
+	HealthCheckHTTP  = "http"
+	HealthCheckHTTPS = "https"
+	HealthCheckTCP   = "tcp"
+)
+
+// RecordHealthStale is how long a node's health report is used for after it was checked
+const RecordHealthStale = 5 * time.Minute
+
+// HealthCheck stores how edge nodes check the health of a record's address
+type HealthCheck struct {
+	Type string `json:"type,omitempty"` // http, https or tcp, or empty for no health check
+	Port uint16 `json:"port,omitempty"` // Defaults to 80 for http and 443 for https
+	Path string `json:"path,omitempty"` // HTTP request path
+	Host string `json:"host,omitempty"` // HTTP Host header and TLS server name
+}
+
+// RecordHealth stores the last health check result of a record on an edge node
+type RecordHealth struct {
+	RecordID  string    `gorm:"primaryKey;type:uuid" json:"record"`
+	Node      string    `gorm:"primaryKey" json:"node"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"-"` // Only logged by the node, as errors describe the node's network
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Configured checks if the health check is set
+func (h HealthCheck) Configured() bool {
+	return h.Type != ""
+}
+
+// SetKey returns the name and type identifying the record set a record belongs to
+func (r *Record) SetKey() string {
+	return strings.ToLower(r.Label) + "/" + r.Type
+}
+
+// RecordListHealthChecked returns all records with a health check in verified zones
+func RecordListHealthChecked(db *gorm.DB) ([]Record, error) {
+	var records []Record
+	err := db.Joins("JOIN zones ON zones.id = records.zone_id").
+		Where("records.health_type != '' AND zones.pending_verification = ?", false).
+		Order("records.created_at").
+		Find(&records).Error
+	return records, err
+}
+
+// RecordHealthSet stores the result of a record health check on a node
+func RecordHealthSet(db *gorm.DB, health *RecordHealth) error {
+	return db.Save(health).Error
+}
+
+// RecordHealthList returns the health of a zone's records on each node
+func RecordHealthList(db *gorm.DB, zoneID string) ([]RecordHealth, error) {
+	var health []RecordHealth
+	err := db.Order("record_id, node").Find(&health, "zone_id = ?", zoneID).Error
+	return health, err
+}
+
+// RecordHealthStatus returns the health of a zone's health checked records by ID, agreed by the nodes that checked them recently.
+// Records are healthy unless most recent reports are unhealthy, so a record isn't withdrawn because a minority of nodes can't reach it.
+func RecordHealthStatus(db *gorm.DB, zoneID string, now time.Time) (map[string]bool, error) {
+	health, err := RecordHealthList(db, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := map[string]int{}
+	for _, h := range health {
+		if now.Sub(h.CheckedAt) > RecordHealthStale {
+			continue
+		}
+		if h.Healthy {
+			votes[h.RecordID]++
+		} else {
+			votes[h.RecordID]--
+		}
+	}
+
+	status := map[string]bool{}
+	for recordID, vote := range votes {
+		status[recordID] = vote >= 0
+	}
+	return status, nil
+}
+
+// UnhealthyRecords returns the sorted IDs of unhealthy records in a health status map
+func UnhealthyRecords(status map[string]bool) []string {
+	var unhealthy []string
+	for recordID, healthy := range status {
+		if !healthy {
+			unhealthy = append(unhealthy, recordID)
+		}
+	}
+	sort.Strings(unhealthy)
+	return unhealthy
+}
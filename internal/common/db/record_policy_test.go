@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnhealthyRecords(t *testing.T) {
+	assert.Equal(t, []string{"a", "c"}, UnhealthyRecords(map[string]bool{"c": false, "b": true, "a": false}))
+	assert.Empty(t, UnhealthyRecords(map[string]bool{"a": true}))
+}
+
+func TestRecordHealth(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+	err = ZoneSetVerified(db, zone.ID)
+	assert.Nil(t, err)
+
+	checked := &Record{Type: "A", Label: "www", Value: "192.0.2.1", TTL: 300, ZoneID: zone.ID, Policy: PolicyFailover, HealthCheck: HealthCheck{Type: HealthCheckTCP, Port: 443}}
+	err = RecordAdd(db, checked)
+	assert.Nil(t, err)
+	err = RecordAdd(db, &Record{Type: "A", Label: "www", Value: "192.0.2.2", TTL: 300, ZoneID: zone.ID, Policy: PolicyFailover, Priority: 1})
+	assert.Nil(t, err)
+
+	records, err := RecordListHealthChecked(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, checked.ID, records[0].ID)
+
+	// Most recent reports decide the health, stale reports are ignored
+	now := time.Now()
+	for node, healthy := range map[string]bool{"node1": false, "node2": false, "node3": true} {
+		err = RecordHealthSet(db, &RecordHealth{RecordID: checked.ID, ZoneID: zone.ID, Node: node, Healthy: healthy, CheckedAt: now})
+		assert.Nil(t, err)
+	}
+	status, err := RecordHealthStatus(db, zone.ID, now)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{checked.ID: false}, status)
+
+	status, err = RecordHealthStatus(db, zone.ID, now.Add(RecordHealthStale+time.Second))
+	assert.Nil(t, err)
+	assert.Empty(t, status)
+
+	// Health is removed with the record
+	_, err = RecordDelete(db, zone.ID, checked.ID)
+	assert.Nil(t, err)
+	health, err := RecordHealthList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, health)
+}
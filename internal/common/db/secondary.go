@@ -0,0 +1,218 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// Zone kinds
+const (
+	ZoneKindPrimary   = "primary"   // Records are managed by Packetframe
+	ZoneKindSecondary = "secondary" // Records are transferred from external primaries
+)
+
+// TSIGAlgorithms lists the supported TSIG algorithms in knot's naming
+var TSIGAlgorithms = []string{"hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384", "hmac-sha512"}
+
+var (
+	ErrInvalidPrimaries = errors.New("invalid primaries, must be 1 to 8 IP addresses with an optional port")
+	ErrInvalidAddress   = errors.New("invalid address, must be a public IP address with an optional port")
+	ErrInvalidTSIGKey   = errors.New("invalid TSIG key, name must be a domain name, algorithm one of " + strings.Join(TSIGAlgorithms, ", ") + " and secret base64 encoded")
+	ErrTSIGKeyNameInUse = errors.New("TSIG key name is already in use with a different secret")
+	ErrSecondaryZone    = errors.New("records of secondary zones are managed by their primaries")
+)
+
+// Zone transfer statuses
+const (
+	TransferSynced  = "synced"  // Serving the primary's current serial
+	TransferBehind  = "behind"  // Serving an older serial than the primary
+	TransferPending = "pending" // Not loaded yet
+	TransferError   = "error"   // Primaries couldn't be queried
+)
+
+// TSIGKey stores a TSIG key used to authenticate zone transfers
+type TSIGKey struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"-"`
+}
+
+// ZoneTransfer stores the last transfer status of a secondary zone on an edge node
+type ZoneTransfer struct {
+	ZoneID        string    `gorm:"primaryKey;type:uuid" json:"zone"`
+	Node          string    `gorm:"primaryKey" json:"node"`
+	Status        string    `json:"status"`
+	Serial        uint32    `json:"serial"`         // Serial served by the node
+	PrimarySerial uint32    `json:"primary_serial"` // Serial of the primary
+	Error         string    `json:"error,omitempty"`
+	TransferredAt time.Time `json:"transferred_at"` // Time the node's serial last changed
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// IsSecondary checks if the zone is transferred from external primaries
+func (z *Zone) IsSecondary() bool {
+	return z.Kind == ZoneKindSecondary
+}
+
+// Configured checks if the TSIG key is set
+func (k TSIGKey) Configured() bool {
+	return k.Name != ""
+}
+
+// Validate checks if a TSIG key is well formed
+func (k TSIGKey) Validate() error {
+	if _, ok := dns.IsDomainName(k.Name); !ok || k.Name == "" || k.Name == "." {
+		return ErrInvalidTSIGKey
+	}
+	found := false
+	for _, algorithm := range TSIGAlgorithms {
+		if k.Algorithm == algorithm {
+			found = true
+		}
+	}
+	if !found {
+		return ErrInvalidTSIGKey
+	}
+	if _, err := base64.StdEncoding.DecodeString(k.Secret); err != nil || k.Secret == "" {
+		return ErrInvalidTSIGKey
+	}
+	return nil
+}
+
+// NormalizeTSIGKey validates a TSIG key and canonicalizes its name, an empty name means no key
+func NormalizeTSIGKey(key TSIGKey) (TSIGKey, error) {
+	if key.Name == "" && key.Algorithm == "" && key.Secret == "" {
+		return TSIGKey{}, nil
+	}
+	key.Name = strings.ToLower(dns.Fqdn(key.Name))
+	key.Algorithm = strings.ToLower(strings.TrimSuffix(key.Algorithm, "."))
+	if err := key.Validate(); err != nil {
+		return TSIGKey{}, err
+	}
+	return key, nil
+}
+
+// NormalizeAddresses validates public IP addresses with optional ports and returns them as host:port, defaulting to port 53.
+// Edge nodes transfer from and notify these addresses, so internal addresses are refused.
+func NormalizeAddresses(addresses []string) ([]string, error) {
+	var normalized []string
+	for _, address := range addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			host, port = strings.Trim(address, "[]"), "53"
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !util.PublicIP(ip) {
+			return nil, ErrInvalidAddress
+		}
+		if p, err := net.LookupPort("udp", port); err != nil || p == 0 {
+			return nil, ErrInvalidAddress
+		}
+		normalized = append(normalized, net.JoinHostPort(ip.String(), port))
+	}
+	return normalized, nil
+}
+
+// checkTSIGKeyName checks that a TSIG key name isn't used with a different algorithm or secret by another zone's primaries or by any transfer peer, as knot identifies keys by name
+func checkTSIGKeyName(db *gorm.DB, zoneID string, key TSIGKey) error {
+	conflicting := "tsig_name = ? AND (tsig_algorithm != ? OR tsig_secret != ?)"
+
+	zones := db.Model(&Zone{}).Where(conflicting, key.Name, key.Algorithm, key.Secret)
+	if zoneID != "" {
+		zones = zones.Where("id != ?", zoneID)
+	}
+	var zoneCount int64
+	if err := zones.Count(&zoneCount).Error; err != nil {
+		return err
+	}
+
+	var peerCount int64
+	if err := db.Model(&TransferPeer{}).Where(conflicting, key.Name, key.Algorithm, key.Secret).Count(&peerCount).Error; err != nil {
+		return err
+	}
+
+	if zoneCount+peerCount > 0 {
+		return ErrTSIGKeyNameInUse
+	}
+	return nil
+}
+
+// ZoneSetSecondary makes a zone a secondary of the given primaries, or a primary zone again if primaries is empty
+func ZoneSetSecondary(db *gorm.DB, zoneID string, primaries []string, key TSIGKey) error {
+	var zone Zone
+	if err := db.First(&zone, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+
+	if len(primaries) == 0 {
+		zone.Kind = ZoneKindPrimary
+		zone.Primaries = []string{}
+		zone.TSIG = TSIGKey{}
+	} else {
+		if len(primaries) > 8 {
+			return ErrInvalidPrimaries
+		}
+		normalized, err := NormalizeAddresses(primaries)
+		if err != nil {
+			return ErrInvalidPrimaries
+		}
+		key, err = NormalizeTSIGKey(key)
+		if err != nil {
+			return err
+		}
+		if key.Configured() {
+			if err := checkTSIGKeyName(db, zoneID, key); err != nil {
+				return err
+			}
+		}
+		zone.Kind = ZoneKindSecondary
+		zone.Primaries = normalized
+		zone.TSIG = key
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("serial", "dynamic").Save(&zone).Error; err != nil {
+			return err
+		}
+		if zone.Kind == ZoneKindPrimary {
+			tx.Delete(&ZoneTransfer{}, "zone_id = ?", zoneID)
+		}
+		return ZoneIncrementSerial(tx, zone.ID)
+	})
+}
+
+// ZoneListSecondary returns all verified secondary zones
+func ZoneListSecondary(db *gorm.DB) ([]Zone, error) {
+	var zones []Zone
+	err := db.Where("kind = ? AND pending_verification = ?", ZoneKindSecondary, false).Find(&zones).Error
+	return zones, err
+}
+
+// ZoneTransferSet stores the transfer status of a zone on a node, keeping the last transfer time if the serial hasn't changed
+func ZoneTransferSet(db *gorm.DB, transfer *ZoneTransfer) error {
+	var existing []ZoneTransfer
+	if err := db.Limit(1).Find(&existing, "zone_id = ? AND node = ?", transfer.ZoneID, transfer.Node).Error; err != nil {
+		return err
+	}
+	if len(existing) > 0 && existing[0].Serial == transfer.Serial {
+		transfer.TransferredAt = existing[0].TransferredAt
+	} else if transfer.Serial != 0 {
+		transfer.TransferredAt = transfer.CheckedAt
+	}
+	return db.Save(transfer).Error
+}
+
+// ZoneTransferList returns the transfer status of a zone on each node
+func ZoneTransferList(db *gorm.DB, zoneID string) ([]ZoneTransfer, error) {
+	var transfers []ZoneTransfer
+	err := db.Order("node").Find(&transfers, "zone_id = ?", zoneID).Error
+	return transfers, err
+}
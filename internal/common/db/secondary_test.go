@@ -0,0 +1,98 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAddresses(t *testing.T) {
+	addresses, err := NormalizeAddresses([]string{"192.0.2.1", "192.0.2.2:5353", "2001:db8::1", "[2001:db8::2]:53"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"192.0.2.1:53", "192.0.2.2:5353", "[2001:db8::1]:53", "[2001:db8::2]:53"}, addresses)
+
+	for _, invalid := range []string{"ns1.example.com", "127.0.0.1", "0.0.0.0", "10.0.0.1", "169.254.169.254", "100.64.0.1", "224.0.0.1", "fe80::1", "fd00::1", "192.0.2.1:0", "192.0.2.1:99999"} {
+		_, err := NormalizeAddresses([]string{invalid})
+		assert.Equalf(t, ErrInvalidAddress, err, invalid)
+	}
+}
+
+func TestNormalizeTSIGKey(t *testing.T) {
+	key, err := NormalizeTSIGKey(TSIGKey{Name: "Transfer.Example.com", Algorithm: "HMAC-SHA256", Secret: "c2VjcmV0"})
+	assert.Nil(t, err)
+	assert.Equal(t, TSIGKey{Name: "transfer.example.com.", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"}, key)
+
+	key, err = NormalizeTSIGKey(TSIGKey{})
+	assert.Nil(t, err)
+	assert.False(t, key.Configured())
+
+	_, err = NormalizeTSIGKey(TSIGKey{Name: "transfer.example.com", Algorithm: "hmac-md4", Secret: "c2VjcmV0"})
+	assert.Equal(t, ErrInvalidTSIGKey, err)
+	_, err = NormalizeTSIGKey(TSIGKey{Name: "transfer.example.com", Algorithm: "hmac-sha256", Secret: "not base64!"})
+	assert.Equal(t, ErrInvalidTSIGKey, err)
+}
+
+func TestZoneSetSecondary(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	for _, zone := range []string{"example1.com", "example2.com"} {
+		_, err = ZoneAdd(db, zone, "user1@example.com", 0)
+		assert.Nil(t, err)
+	}
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	example2, err := ZoneFind(db, "example2.com")
+	assert.Nil(t, err)
+	assert.Equal(t, ZoneKindPrimary, example1.Kind)
+
+	key := TSIGKey{Name: "transfer.example.com", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"}
+	err = ZoneSetSecondary(db, example1.ID, []string{"192.0.2.1"}, key)
+	assert.Nil(t, err)
+	zone, err := ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	assert.True(t, zone.IsSecondary())
+	assert.Equal(t, []string{"192.0.2.1:53"}, []string(zone.Primaries))
+	assert.Equal(t, "transfer.example.com.", zone.TSIG.Name)
+	assert.Equal(t, example1.Serial+1, zone.Serial)
+
+	// Key names can only be shared with the same secret
+	err = ZoneSetSecondary(db, example2.ID, []string{"192.0.2.1"}, TSIGKey{Name: "transfer.example.com", Algorithm: "hmac-sha256", Secret: "b3RoZXI="})
+	assert.Equal(t, ErrTSIGKeyNameInUse, err)
+	err = ZoneSetSecondary(db, example2.ID, []string{"192.0.2.1"}, key)
+	assert.Nil(t, err)
+
+	// Secondary zones are only listed once verified
+	zones, err := ZoneListSecondary(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(zones))
+	assert.Nil(t, ZoneSetVerified(db, example1.ID))
+	zones, err = ZoneListSecondary(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+
+	// The transfer time only changes when the serial changes
+	checked := time.Now().Add(-time.Hour).Round(time.Second)
+	err = ZoneTransferSet(db, &ZoneTransfer{ZoneID: example1.ID, Node: "node1", Status: TransferSynced, Serial: 10, PrimarySerial: 10, CheckedAt: checked})
+	assert.Nil(t, err)
+	err = ZoneTransferSet(db, &ZoneTransfer{ZoneID: example1.ID, Node: "node1", Status: TransferSynced, Serial: 10, PrimarySerial: 10, CheckedAt: time.Now()})
+	assert.Nil(t, err)
+	transfers, err := ZoneTransferList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(transfers))
+	assert.True(t, checked.Equal(transfers[0].TransferredAt))
+
+	// Making the zone primary again removes its transfer status
+	err = ZoneSetSecondary(db, example1.ID, nil, TSIGKey{})
+	assert.Nil(t, err)
+	zone, err = ZoneFindByID(db, example1.ID)
+	assert.Nil(t, err)
+	assert.False(t, zone.IsSecondary())
+	assert.False(t, zone.TSIG.Configured())
+	transfers, err = ZoneTransferList(db, example1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(transfers))
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+var (
+	// SessionIdleTimeout is how long a session can go unused before it expires
+	SessionIdleTimeout = 7 * 24 * time.Hour
+	// SessionAbsoluteTimeout is how long a session can exist before it expires
+	SessionAbsoluteTimeout = 30 * 24 * time.Hour
+	// SessionRotateInterval is how often a session's token is rotated
+	SessionRotateInterval = 24 * time.Hour
+)
+
+// sessionRotationGrace is how long the previous token of a rotated session is still accepted, to allow for concurrent requests
+const sessionRotationGrace = time.Minute
+
+// sessionTouchInterval limits how often a session's last seen time is written
+const sessionTouchInterval = time.Minute
+
+// Session stores a login session. Only the SHA256 hash of the session token is stored.
+type Session struct {
+	ID             string    `gorm:"primaryKey,type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID         string    `gorm:"index" json:"-"`
+	Hash           string    `gorm:"uniqueIndex" json:"-"`
+	PreviousHash   string    `gorm:"index" json:"-"` // Hash of the token before the last rotation
+	RotatedAt      time.Time `json:"-"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent"`
+	ImpersonatorID string    `json:"impersonator,omitempty"` // ID of the admin who created this session by impersonating the user
+	LastSeen       time.Time `json:"last_seen"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Expired checks if the session has passed its idle or absolute expiry
+func (s *Session) Expired() bool {
+	return time.Since(s.LastSeen) > SessionIdleTimeout || time.Since(s.CreatedAt) > SessionAbsoluteTimeout
+}
+
+// ExpiresAt returns the time the session expires if it stays active
+func (s *Session) ExpiresAt() time.Time {
+	return s.CreatedAt.Add(SessionAbsoluteTimeout)
+}
+
+// SessionAdd creates a new session for a user and returns the plaintext session token
+func SessionAdd(db *gorm.DB, userID string, ip string, userAgent string, impersonatorID string) (string, *Session, error) {
+	token, err := auth.RandomString(64)
+	if err != nil {
+		return "", nil, err
+	}
+	hash, err := util.SHA256(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	s := Session{
+		UserID:         userID,
+		Hash:           hash,
+		RotatedAt:      now,
+		IP:             ip,
+		UserAgent:      userAgent,
+		ImpersonatorID: impersonatorID,
+		LastSeen:       now,
+		CreatedAt:      now,
+	}
+	if err := db.Create(&s).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &s, nil
+}
+
+// sessionFind finds an unexpired session by its current or recently rotated token and returns nil if no session exists
+func sessionFind(db *gorm.DB, token string) (*Session, error) {
+	hash, err := util.SHA256(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	if err := db.Limit(1).Find(&sessions, "hash = ? OR previous_hash = ?", hash, hash).Error; err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	s := sessions[0]
+
+	if s.Expired() {
+		return nil, db.Delete(&Session{}, "id = ?", s.ID).Error
+	}
+	if s.Hash != hash && time.Since(s.RotatedAt) > sessionRotationGrace {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// SessionTouch updates a session's last seen time and client details, and rotates its token if it's due. The new token is returned if the session was rotated.
+func SessionTouch(db *gorm.DB, s *Session, ip string, userAgent string, rotate bool) (string, error) {
+	updates := map[string]interface{}{}
+	if time.Since(s.LastSeen) > sessionTouchInterval {
+		updates["last_seen"] = time.Now()
+	}
+	if ip != "" && ip != s.IP {
+		updates["ip"] = ip
+	}
+	if userAgent != "" && userAgent != s.UserAgent {
+		updates["user_agent"] = userAgent
+	}
+
+	var token string
+	if rotate && time.Since(s.RotatedAt) > SessionRotateInterval {
+		var err error
+		token, err = auth.RandomString(64)
+		if err != nil {
+			return "", err
+		}
+		hash, err := util.SHA256(token)
+		if err != nil {
+			return "", err
+		}
+		updates["previous_hash"] = s.Hash
+		updates["hash"] = hash
+		updates["rotated_at"] = time.Now()
+	}
+
+	if len(updates) == 0 {
+		return "", nil
+	}
+	// Only rotate if no concurrent request already has
+	res := db.Model(&Session{}).Where("id = ? AND hash = ?", s.ID, s.Hash).Updates(updates)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", nil
+	}
+	return token, nil
+}
+
+// SessionList gets all active sessions for a user
+func SessionList(db *gorm.DB, userID string) ([]Session, error) {
+	var sessions []Session
+	if err := db.Order("last_seen DESC").Find(&sessions, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	var active []Session
+	for _, s := range sessions {
+		if !s.Expired() {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// SessionDelete revokes one of a user's sessions
+func SessionDelete(db *gorm.DB, userID string, sessionID string) error {
+	res := db.Delete(&Session{}, "id = ? AND user_id = ?", sessionID, userID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// SessionDeleteAll revokes all of a user's sessions except for an optional session to keep
+func SessionDeleteAll(db *gorm.DB, userID string, exceptSessionID string) error {
+	tx := db.Where("user_id = ?", userID)
+	if exceptSessionID != "" {
+		tx = tx.Where("id != ?", exceptSessionID)
+	}
+	return tx.Delete(&Session{}).Error
+}
+
+// SessionDeleteExpired deletes all expired sessions
+func SessionDeleteExpired(db *gorm.DB) error {
+	return db.Where("last_seen < ? OR created_at < ?", time.Now().Add(-SessionIdleTimeout), time.Now().Add(-SessionAbsoluteTimeout)).
+		Delete(&Session{}).Error
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSessionAddRotateDelete tests session lookup, rotation, expiry and revocation
+func TestSessionAddRotateDelete(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+
+	token, session, err := SessionAdd(db, user1.ID, "192.0.2.1", "example user agent", "")
+	assert.Nil(t, err)
+	_, _, err = SessionAdd(db, user1.ID, "192.0.2.2", "example user agent", "")
+	assert.Nil(t, err)
+
+	sessions, err := SessionList(db, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sessions))
+
+	// Rotation isn't due yet
+	newToken, err := SessionTouch(db, session, "192.0.2.1", "example user agent", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "", newToken)
+
+	// Rotate the session by backdating its last rotation
+	err = db.Model(&Session{}).Where("id = ?", session.ID).Update("rotated_at", time.Now().Add(-2*SessionRotateInterval)).Error
+	assert.Nil(t, err)
+	session, err = sessionFind(db, token)
+	assert.Nil(t, err)
+	newToken, err = SessionTouch(db, session, "192.0.2.1", "example user agent", true)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", newToken)
+
+	// Both tokens are accepted during the grace period
+	user, err := UserFindByAuth(db, newToken)
+	assert.Nil(t, err)
+	assert.Equal(t, session.ID, user.Session.ID)
+	user, err = UserFindByAuth(db, token)
+	assert.Nil(t, err)
+	assert.Equal(t, session.ID, user.Session.ID)
+
+	// Idle sessions expire
+	err = db.Model(&Session{}).Where("id = ?", session.ID).Update("last_seen", time.Now().Add(-2*SessionIdleTimeout)).Error
+	assert.Nil(t, err)
+	user, err = UserFindByAuth(db, newToken)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+
+	// Revoke the remaining session
+	sessions, err = SessionList(db, user1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sessions))
+	err = SessionDelete(db, user1.ID, sessions[0].ID)
+	assert.Nil(t, err)
+	err = SessionDelete(db, user1.ID, sessions[0].ID)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// Setting keys
+const (
+	SettingRequireAdminTOTP = "require_admin_totp" // "true" if admins must have TOTP enabled to use admin privileges
+)
+
+// Setting stores a global setting that can be changed at runtime
+type Setting struct {
+	Key   string `gorm:"primaryKey" json:"key"`
+	Value string `json:"value"`
+}
+
+// SettingGet gets a setting and returns an empty string if it isn't set
+func SettingGet(db *gorm.DB, key string) (string, error) {
+	var settings []Setting
+	if err := db.Limit(1).Find(&settings, "key = ?", key).Error; err != nil {
+		return "", err
+	}
+	if len(settings) == 0 {
+		return "", nil
+	}
+	return settings[0].Value, nil
+}
+
+// SettingSet sets a setting
+func SettingSet(db *gorm.DB, key string, value string) error {
+	return db.Save(&Setting{Key: key, Value: value}).Error
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSOATimers   = errors.New("invalid SOA timers, refresh must be 300-86400, retry 60-refresh, expire at least refresh+retry and at most 2419200, and minimum TTL at most 86400")
+	ErrInvalidSOARName    = errors.New("invalid SOA RNAME, must be a fully qualified domain name")
+	ErrInvalidNameservers = errors.New("invalid nameservers, must be 2 to 13 unique fully qualified domain names")
+)
+
+// SOATimers stores a zone's SOA timers in seconds
+type SOATimers struct {
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	MinTTL  uint32 `json:"min_ttl"`
+}
+
+// Validate checks if SOA timers are within sensible bounds
+func (t SOATimers) Validate() error {
+	if t.Refresh < 300 || t.Refresh > 86400 ||
+		t.Retry < 60 || t.Retry > t.Refresh ||
+		t.Expire < t.Refresh+t.Retry || t.Expire > 2419200 ||
+		t.MinTTL > 86400 {
+		return ErrInvalidSOATimers
+	}
+	return nil
+}
+
+// Timers returns the zone's SOA timers, falling back to SOADefaults for any unset timer
+func (z *Zone) Timers() SOATimers {
+	timers := z.SOATimers
+	if timers.Refresh == 0 {
+		timers.Refresh = SOADefaults.Refresh
+	}
+	if timers.Retry == 0 {
+		timers.Retry = SOADefaults.Retry
+	}
+	if timers.Expire == 0 {
+		timers.Expire = SOADefaults.Expire
+	}
+	if timers.MinTTL == 0 {
+		timers.MinTTL = SOADefaults.MinTTL
+	}
+	return timers
+}
+
+// RName returns the zone's SOA responsible party mailbox
+func (z *Zone) RName() string {
+	if z.SOAContact != "" {
+		return z.SOAContact
+	}
+	return SOARName
+}
+
+// Nameservers returns the zone's authoritative nameservers
+func (z *Zone) Nameservers() []string {
+	if len(z.ZoneNameservers) > 0 {
+		return z.ZoneNameservers
+	}
+	return Nameservers
+}
+
+// VanityNameservers returns the zone's nameservers that aren't platform nameservers
+func (z *Zone) VanityNameservers() []string {
+	var vanity []string
+	for _, ns := range z.ZoneNameservers {
+		if !containsFold(Nameservers, ns) {
+			vanity = append(vanity, ns)
+		}
+	}
+	return vanity
+}
+
+// containsFold checks if a slice contains a domain name, ignoring case
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeNameservers validates and canonicalizes a nameserver set, an empty set resets to the platform nameservers
+func NormalizeNameservers(nameservers []string) ([]string, error) {
+	if len(nameservers) == 0 {
+		return []string{}, nil
+	}
+	if len(nameservers) < 2 || len(nameservers) > 13 {
+		return nil, ErrInvalidNameservers
+	}
+
+	var normalized []string
+	for _, ns := range nameservers {
+		ns = strings.ToLower(dns.Fqdn(ns))
+		if _, ok := dns.IsDomainName(ns); !ok || dns.CountLabel(ns) < 2 || containsFold(normalized, ns) {
+			return nil, ErrInvalidNameservers
+		}
+		normalized = append(normalized, ns)
+	}
+	return normalized, nil
+}
+
+// ZoneSetSOA sets a zone's SOA timers, RNAME and nameservers. Zero timers, an empty RNAME and empty nameservers reset to the platform defaults.
+func ZoneSetSOA(db *gorm.DB, zoneID string, timers SOATimers, rname string, nameservers []string) error {
+	var zone Zone
+	if err := db.First(&zone, "id = ?", zoneID).Error; err != nil {
+		return err
+	}
+
+	// Validate the timers as they'll be served, including defaults
+	zone.SOATimers = timers
+	if err := zone.Timers().Validate(); err != nil {
+		return err
+	}
+
+	if rname != "" {
+		rname = strings.ToLower(dns.Fqdn(rname))
+		if _, ok := dns.IsDomainName(rname); !ok || dns.CountLabel(rname) < 2 {
+			return ErrInvalidSOARName
+		}
+	}
+
+	nameservers, err := NormalizeNameservers(nameservers)
+	if err != nil {
+		return err
+	}
+
+	zone.SOAContact = rname
+	zone.ZoneNameservers = nameservers
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("serial", "dynamic").Save(&zone).Error; err != nil {
+			return err
+		}
+		return ZoneIncrementSerial(tx, zone.ID)
+	})
+}
+
+// SetDefaults overrides the platform nameservers, SOA RNAME and SOA timers from configuration, keeping the current value of anything empty or zero
+func SetDefaults(nameservers []string, rname string, timers SOATimers) error {
+	var nonEmpty []string
+	for _, ns := range nameservers {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			nonEmpty = append(nonEmpty, ns)
+		}
+	}
+	normalized, err := NormalizeNameservers(nonEmpty)
+	if err != nil {
+		return err
+	}
+
+	zone := Zone{SOATimers: timers}
+	if err := zone.Timers().Validate(); err != nil {
+		return err
+	}
+
+	if len(normalized) > 0 {
+		Nameservers = normalized
+	}
+	if rname != "" {
+		SOARName = strings.ToLower(dns.Fqdn(rname))
+	}
+	SOADefaults = zone.Timers()
+	return nil
+}
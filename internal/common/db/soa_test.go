@@ -0,0 +1,106 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneTimers(t *testing.T) {
+	zone := Zone{Zone: "example.com."}
+	assert.Equal(t, SOADefaults, zone.Timers())
+	assert.Equal(t, SOARName, zone.RName())
+	assert.Equal(t, Nameservers, zone.Nameservers())
+
+	// Unset timers fall back to the defaults
+	zone.SOATimers = SOATimers{MinTTL: 60}
+	assert.Equal(t, uint32(60), zone.Timers().MinTTL)
+	assert.Equal(t, SOADefaults.Refresh, zone.Timers().Refresh)
+	assert.Equal(t, uint32(60), zone.SOA().Minttl)
+
+	zone.ZoneNameservers = []string{"ns1.example.com.", "ns2.example.com."}
+	zone.SOAContact = "hostmaster.example.com."
+	assert.Equal(t, "ns1.example.com.", zone.SOA().Ns)
+	assert.Equal(t, "hostmaster.example.com.", zone.SOA().Mbox)
+	assert.Equal(t, 2, len(zone.NS()))
+	assert.Equal(t, []string{"ns1.example.com.", "ns2.example.com."}, zone.VanityNameservers())
+
+	assert.Nil(t, SOADefaults.Validate())
+	assert.ErrorIs(t, SOATimers{Refresh: 7200, Retry: 9000, Expire: 1209600}.Validate(), ErrInvalidSOATimers)
+	assert.ErrorIs(t, SOATimers{Refresh: 7200, Retry: 3600, Expire: 3600}.Validate(), ErrInvalidSOATimers)
+}
+
+func TestNormalizeNameservers(t *testing.T) {
+	nameservers, err := NormalizeNameservers([]string{"NS1.example.com", "ns2.example.com."})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ns1.example.com.", "ns2.example.com."}, nameservers)
+
+	nameservers, err = NormalizeNameservers(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(nameservers))
+
+	for _, invalid := range [][]string{
+		{"ns1.example.com."},
+		{"ns1.example.com.", "ns1.example.com."},
+		{"ns1.example.com.", "com."},
+		{"ns1.example.com.", "bad..example.com."},
+	} {
+		_, err = NormalizeNameservers(invalid)
+		assert.ErrorIs(t, err, ErrInvalidNameservers)
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	nameservers, rname, timers := Nameservers, SOARName, SOADefaults
+	defer func() {
+		Nameservers, SOARName, SOADefaults = nameservers, rname, timers
+	}()
+
+	err := SetDefaults([]string{""}, "", SOATimers{})
+	assert.Nil(t, err)
+	assert.Equal(t, nameservers, Nameservers)
+	assert.Equal(t, timers, SOADefaults)
+
+	err = SetDefaults([]string{"ns1.example.net", " ns2.example.net"}, "dns.example.net", SOATimers{MinTTL: 900})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ns1.example.net.", "ns2.example.net."}, Nameservers)
+	assert.Equal(t, "dns.example.net.", SOARName)
+	assert.Equal(t, uint32(900), SOADefaults.MinTTL)
+	assert.Equal(t, timers.Refresh, SOADefaults.Refresh)
+
+	err = SetDefaults(nil, "", SOATimers{Refresh: 10})
+	assert.ErrorIs(t, err, ErrInvalidSOATimers)
+}
+
+func TestZoneSetSOA(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	serial := zone.Serial
+
+	err = ZoneSetSOA(db, zone.ID, SOATimers{MinTTL: 60}, "Hostmaster.example1.com", []string{"ns1.example1.com", "ns2.example1.com"})
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(60), zone.SOA().Minttl)
+	assert.Equal(t, "hostmaster.example1.com.", zone.SOA().Mbox)
+	assert.Equal(t, []string{"ns1.example1.com.", "ns2.example1.com."}, zone.Nameservers())
+	assert.Greater(t, zone.Serial, serial)
+
+	assert.ErrorIs(t, ZoneSetSOA(db, zone.ID, SOATimers{Retry: 10}, "", nil), ErrInvalidSOATimers)
+	assert.ErrorIs(t, ZoneSetSOA(db, zone.ID, SOATimers{}, "invalid", nil), ErrInvalidSOARName)
+
+	// Reset to the platform defaults
+	err = ZoneSetSOA(db, zone.ID, SOATimers{}, "", nil)
+	assert.Nil(t, err)
+	zone, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, SOADefaults, zone.Timers())
+	assert.Equal(t, Nameservers, zone.Nameservers())
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var (
+	ErrTOTPAlreadyEnabled             = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled                = errors.New("two-factor authentication enrollment has not been started")
+	ErrInvalidTOTPCode                = errors.New("invalid two-factor authentication code")
+	ErrInvalidOrExpiredLoginChallenge = errors.New("login challenge is invalid or expired")
+)
+
+// recoveryCodeCount is the number of recovery codes generated when TOTP is enabled
+const recoveryCodeCount = 10
+
+// loginChallengeTTL is how long a user has to provide a TOTP code after a valid password
+const loginChallengeTTL = 5 * time.Minute
+
+// UserTOTPEnroll generates a new TOTP secret for a user. TOTP isn't enforced until UserTOTPConfirm is called with a valid code.
+func UserTOTPEnroll(db *gorm.DB, userID string) (string, error) {
+	var user User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+	if user.TOTPEnabled {
+		return "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := auth.TOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	return secret, db.Model(&User{}).Where("id = ?", userID).Update("totp_secret", secret).Error
+}
+
+// UserTOTPConfirm enables TOTP for a user if the code is valid and returns a new set of recovery codes
+func UserTOTPConfirm(db *gorm.DB, userID string, code string) ([]string, error) {
+	var user User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+	valid, err := useTOTPCode(db, &user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	var codes, hashes []string
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := auth.RandomString(16)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := util.SHA256(code)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+
+	user.TOTPEnabled = true
+	user.RecoveryCodes = hashes
+	return codes, db.Save(&user).Error
+}
+
+// UserTOTPDisable disables TOTP for a user if the code is a valid TOTP or recovery code
+func UserTOTPDisable(db *gorm.DB, userID string, code string) error {
+	var user User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	valid, err := UserTOTPValidate(db, &user, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidTOTPCode
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.RecoveryCodes = []string{}
+	return db.Save(&user).Error
+}
+
+// useTOTPCode checks a TOTP code for a user and records its time step, so a code can't be used again
+func useTOTPCode(db *gorm.DB, user *User, code string) (bool, error) {
+	step, ok := auth.TOTPStep(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Only record the step if it's newer, so concurrent requests with the same code can't both succeed
+	res := db.Model(&User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// UserTOTPValidate checks a TOTP code or recovery code for a user. TOTP codes and recovery codes can only be used once.
+func UserTOTPValidate(db *gorm.DB, user *User, code string) (bool, error) {
+	if !user.TOTPEnabled {
+		return false, nil
+	}
+	valid, err := useTOTPCode(db, user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+
+	hash, err := util.SHA256(code)
+	if err != nil {
+		return false, err
+	}
+	for i, recoveryCode := range user.RecoveryCodes {
+		if recoveryCode == hash {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i], user.RecoveryCodes[i+1:]...)
+			return true, db.Model(&User{}).Where("id = ?", user.ID).Update("recovery_codes", user.RecoveryCodes).Error
+		}
+	}
+	return false, nil
+}
+
+// UserCreateLoginChallenge creates a login challenge for a user who has provided a valid password
+func UserCreateLoginChallenge(db *gorm.DB, userID string) (string, error) {
+	token, err := auth.RandomString(64)
+	if err != nil {
+		return "", err
+	}
+	challenge := fmt.Sprintf("%s:%d", token, time.Now().UTC().Unix())
+	return token, db.Model(&User{}).Where("id = ?", userID).Update("login_challenge", challenge).Error
+}
+
+// UserValidateLoginChallenge checks and consumes a login challenge
+func UserValidateLoginChallenge(db *gorm.DB, email string, token string) (*User, error) {
+	user, err := UserFindByEmail(db, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || token == "" {
+		return nil, ErrInvalidOrExpiredLoginChallenge
+	}
+
+	tokenParts := strings.Split(user.LoginChallenge, ":")
+	if len(tokenParts) != 2 || tokenParts[0] != token {
+		return nil, ErrInvalidOrExpiredLoginChallenge
+	}
+	createdAtUnix, err := strconv.ParseInt(tokenParts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(time.Unix(createdAtUnix, 0)) > loginChallengeTTL {
+		return nil, ErrInvalidOrExpiredLoginChallenge
+	}
+
+	// Challenges can only be used once
+	if err := db.Model(&User{}).Where("id = ?", user.ID).Update("login_challenge", "").Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
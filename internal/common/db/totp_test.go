@@ -0,0 +1,96 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/api/auth"
+)
+
+// TestUserTOTP tests TOTP enrollment, recovery codes and login challenges
+func TestUserTOTP(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	user1, err := UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+
+	// Confirming before enrolling fails
+	_, err = UserTOTPConfirm(db, user1.ID, "000000")
+	assert.ErrorIs(t, err, ErrTOTPNotEnrolled)
+
+	secret, err := UserTOTPEnroll(db, user1.ID)
+	assert.Nil(t, err)
+
+	// Confirm with an invalid and then a valid code
+	_, err = UserTOTPConfirm(db, user1.ID, "invalid")
+	assert.ErrorIs(t, err, ErrInvalidTOTPCode)
+	code, err := auth.TOTPCode(secret, time.Now())
+	assert.Nil(t, err)
+	recoveryCodes, err := UserTOTPConfirm(db, user1.ID, code)
+	assert.Nil(t, err)
+	assert.Equal(t, recoveryCodeCount, len(recoveryCodes))
+
+	// A TOTP code can't be used twice
+	user1, err = UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	valid, err := UserTOTPValidate(db, user1, code)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	_, err = UserTOTPEnroll(db, user1.ID)
+	assert.ErrorIs(t, err, ErrTOTPAlreadyEnabled)
+
+	// Recovery codes can only be used once
+	user1, err = UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	valid, err = UserTOTPValidate(db, user1, recoveryCodes[0])
+	assert.Nil(t, err)
+	assert.True(t, valid)
+	valid, err = UserTOTPValidate(db, user1, recoveryCodes[0])
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// Login challenges can only be used once
+	challenge, err := UserCreateLoginChallenge(db, user1.ID)
+	assert.Nil(t, err)
+	_, err = UserValidateLoginChallenge(db, user1.Email, "invalid")
+	assert.ErrorIs(t, err, ErrInvalidOrExpiredLoginChallenge)
+	user, err := UserValidateLoginChallenge(db, user1.Email, challenge)
+	assert.Nil(t, err)
+	assert.Equal(t, user1.ID, user.ID)
+	_, err = UserValidateLoginChallenge(db, user1.Email, challenge)
+	assert.ErrorIs(t, err, ErrInvalidOrExpiredLoginChallenge)
+
+	// Admins can be required to use TOTP
+	err = UserGroupAdd(db, user1.ID, GroupAdmin)
+	assert.Nil(t, err)
+	err = SettingSet(db, SettingRequireAdminTOTP, "true")
+	assert.Nil(t, err)
+	user1, err = UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	admin, err := UserAdminAuthorized(db, user1)
+	assert.Nil(t, err)
+	assert.True(t, admin)
+
+	// API keys skip TOTP, so they can't use admin privileges while it's required
+	user1.Key = &APIKey{}
+	admin, err = UserAdminAuthorized(db, user1)
+	assert.Nil(t, err)
+	assert.False(t, admin)
+	user1.Key = nil
+
+	// Disable TOTP with a recovery code
+	err = UserTOTPDisable(db, user1.ID, recoveryCodes[1])
+	assert.Nil(t, err)
+	user1, err = UserFindByEmail(db, "user1@example.com")
+	assert.Nil(t, err)
+	assert.False(t, user1.TOTPEnabled)
+	admin, err = UserAdminAuthorized(db, user1)
+	assert.Nil(t, err)
+	assert.False(t, admin)
+}
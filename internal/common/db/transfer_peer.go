@@ -0,0 +1,128 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxTransferPeers is the maximum number of transfer peers per zone
+const MaxTransferPeers = 16
+
+var (
+	ErrTooManyTransferPeers = errors.New("zones can have at most 16 transfer peers")
+	ErrTransferPeerExists   = errors.New("a transfer peer with this address already exists")
+)
+
+// TransferPeer stores an external secondary allowed to transfer a zone
+type TransferPeer struct {
+	ID        string    `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string    `gorm:"index" json:"zone"`
+	Address   string    `json:"address"`                                   // host:port the peer transfers from and is notified at
+	Notify    bool      `json:"notify"`                                    // Send NOTIFY to the peer when the zone changes
+	TSIG      TSIGKey   `gorm:"embedded;embeddedPrefix:tsig_" json:"tsig"` // Key the peer must sign transfer requests with
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newTSIGSecret generates a random base64 encoded TSIG secret
+func newTSIGSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// TransferPeerAdd allows a peer to transfer a zone. If the key has a name but no secret, a secret is generated and an empty algorithm defaults to hmac-sha256.
+func TransferPeerAdd(db *gorm.DB, zoneID, address string, notify bool, key TSIGKey) (*TransferPeer, error) {
+	addresses, err := NormalizeAddresses([]string{address})
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Name != "" {
+		if key.Algorithm == "" {
+			key.Algorithm = "hmac-sha256"
+		}
+		if key.Secret == "" {
+			if key.Secret, err = newTSIGSecret(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	key, err = NormalizeTSIGKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if key.Configured() {
+		if err := checkTSIGKeyName(db, "", key); err != nil {
+			return nil, err
+		}
+	}
+
+	peers, err := TransferPeerList(db, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if len(peers) >= MaxTransferPeers {
+		return nil, ErrTooManyTransferPeers
+	}
+	for _, peer := range peers {
+		if peer.Address == addresses[0] {
+			return nil, ErrTransferPeerExists
+		}
+	}
+
+	peer := &TransferPeer{ZoneID: zoneID, Address: addresses[0], Notify: notify, TSIG: key}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(peer).Error; err != nil {
+			return err
+		}
+		// Bump the serial so edge nodes reload the transfer configuration
+		return ZoneIncrementSerial(tx, zoneID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return peer, nil
+}
+
+// TransferPeerList returns a zone's transfer peers
+func TransferPeerList(db *gorm.DB, zoneID string) ([]TransferPeer, error) {
+	var peers []TransferPeer
+	err := db.Order("created_at").Find(&peers, "zone_id = ?", zoneID).Error
+	return peers, err
+}
+
+// TransferPeerListAll returns the transfer peers of all zones by zone ID
+func TransferPeerListAll(db *gorm.DB) (map[string][]TransferPeer, error) {
+	var peers []TransferPeer
+	if err := db.Order("created_at").Find(&peers).Error; err != nil {
+		return nil, err
+	}
+	byZone := map[string][]TransferPeer{}
+	for _, peer := range peers {
+		byZone[peer.ZoneID] = append(byZone[peer.ZoneID], peer)
+	}
+	return byZone, nil
+}
+
+// TransferPeerDelete removes a transfer peer from a zone
+func TransferPeerDelete(db *gorm.DB, zoneID, peerID string) (bool, error) {
+	var deleted bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND zone_id = ?", peerID, zoneID).Delete(&TransferPeer{})
+		if res.Error != nil {
+			return res.Error
+		}
+		deleted = res.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		return ZoneIncrementSerial(tx, zoneID)
+	})
+	return deleted, err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferPeers(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+
+	// A secret is generated for keys without one
+	peer, err := TransferPeerAdd(db, zone.ID, "198.51.100.1", true, TSIGKey{Name: "peer.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.1:53", peer.Address)
+	assert.Equal(t, "peer.example.com.", peer.TSIG.Name)
+	assert.Equal(t, "hmac-sha256", peer.TSIG.Algorithm)
+	assert.NotEmpty(t, peer.TSIG.Secret)
+
+	updated, err := ZoneFindByID(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, zone.Serial+1, updated.Serial)
+
+	// Peers without a key are allowed by address only
+	_, err = TransferPeerAdd(db, zone.ID, "[2001:db8::1]:5353", false, TSIGKey{})
+	assert.Nil(t, err)
+
+	_, err = TransferPeerAdd(db, zone.ID, "198.51.100.1:53", false, TSIGKey{})
+	assert.Equal(t, ErrTransferPeerExists, err)
+	_, err = TransferPeerAdd(db, zone.ID, "peer.example.com", false, TSIGKey{})
+	assert.Equal(t, ErrInvalidAddress, err)
+	_, err = TransferPeerAdd(db, zone.ID, "198.51.100.2", false, TSIGKey{Name: "peer.example.com", Algorithm: "hmac-sha256", Secret: "b3RoZXI="})
+	assert.Equal(t, ErrTSIGKeyNameInUse, err)
+
+	peers, err := TransferPeerListAll(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(peers[zone.ID]))
+
+	deleted, err := TransferPeerDelete(db, zone.ID, peer.ID)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	deleted, err = TransferPeerDelete(db, zone.ID, peer.ID)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+
+	list, err := TransferPeerList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list))
+}
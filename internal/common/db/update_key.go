@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUpdateKeyOutsideZone = errors.New("DNS UPDATE key name must be the zone's name or a name within the zone")
+	ErrUpdateKeyNameInUse   = errors.New("DNS UPDATE key name is already in use")
+)
+
+// UpdateKey stores a TSIG key allowed to change a zone's records with RFC 2136 DNS UPDATE
+type UpdateKey struct {
+	ID        string        `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" json:"id"`
+	ZoneID    string        `gorm:"index" json:"zone"`
+	TSIG      UpdateTSIGKey `gorm:"embedded;embeddedPrefix:tsig_" json:"tsig"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// UpdateTSIGKey is a TSIGKey with a unique name, as DNS UPDATE keys are found by name
+type UpdateTSIGKey struct {
+	Name      string `gorm:"uniqueIndex" json:"name"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"-"`
+}
+
+// UpdateKeyAdd adds a DNS UPDATE key to a zone, generating a secret if the key has none and defaulting to hmac-sha256.
+// Key names identify the zone an UPDATE is authorized for, so they must be within the zone and are unique across all zones.
+func UpdateKeyAdd(db *gorm.DB, zoneID string, key TSIGKey) (*UpdateKey, error) {
+	if key.Name == "" {
+		return nil, ErrInvalidTSIGKey
+	}
+	if key.Algorithm == "" {
+		key.Algorithm = "hmac-sha256"
+	}
+	if key.Secret == "" {
+		secret, err := newTSIGSecret()
+		if err != nil {
+			return nil, err
+		}
+		key.Secret = secret
+	}
+	key, err := NormalizeTSIGKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := ZoneFindByID(db, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if !dns.IsSubDomain(zone.Zone, key.Name) {
+		return nil, ErrUpdateKeyOutsideZone
+	}
+
+	// The unique index on the name rejects keys added concurrently
+	updateKey := &UpdateKey{ZoneID: zoneID, TSIG: UpdateTSIGKey(key)}
+	if err := db.Create(updateKey).Error; err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return nil, ErrUpdateKeyNameInUse
+		}
+		return nil, err
+	}
+	return updateKey, nil
+}
+
+// UpdateKeyFind finds a DNS UPDATE key by name and returns nil if no key exists
+func UpdateKeyFind(db *gorm.DB, name string) (*UpdateKey, error) {
+	var keys []UpdateKey
+	if err := db.Limit(1).Find(&keys, "tsig_name = ?", name).Error; err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return &keys[0], nil
+}
+
+// UpdateKeyList returns a zone's DNS UPDATE keys
+func UpdateKeyList(db *gorm.DB, zoneID string) ([]UpdateKey, error) {
+	var keys []UpdateKey
+	err := db.Order("created_at").Find(&keys, "zone_id = ?", zoneID).Error
+	return keys, err
+}
+
+// UpdateKeyDelete removes a DNS UPDATE key from a zone
+func UpdateKeyDelete(db *gorm.DB, zoneID, keyID string) (bool, error) {
+	res := db.Where("id = ? AND zone_id = ?", keyID, zoneID).Delete(&UpdateKey{})
+	return res.RowsAffected > 0, res.Error
+}
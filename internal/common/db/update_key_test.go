@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateKeys(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	zone, err := ZoneFind(db, "example.com")
+	assert.Nil(t, err)
+
+	// A secret is generated for keys without one
+	key, err := UpdateKeyAdd(db, zone.ID, TSIGKey{Name: "Update.Example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "update.example.com.", key.TSIG.Name)
+	assert.Equal(t, "hmac-sha256", key.TSIG.Algorithm)
+	assert.NotEmpty(t, key.TSIG.Secret)
+
+	_, err = UpdateKeyAdd(db, zone.ID, TSIGKey{})
+	assert.Equal(t, ErrInvalidTSIGKey, err)
+	_, err = UpdateKeyAdd(db, zone.ID, TSIGKey{Name: "update.example.com."})
+	assert.Equal(t, ErrUpdateKeyNameInUse, err)
+	assert.True(t, db.Migrator().HasIndex(&UpdateKey{}, "idx_update_keys_tsig_name"))
+
+	// Names outside the zone can't be claimed, so other tenants' names can't be taken or probed
+	_, err = UpdateKeyAdd(db, zone.ID, TSIGKey{Name: "update.example.net."})
+	assert.Equal(t, ErrUpdateKeyOutsideZone, err)
+	apex, err := UpdateKeyAdd(db, zone.ID, TSIGKey{Name: "example.com."})
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com.", apex.TSIG.Name)
+
+	found, err := UpdateKeyFind(db, "update.example.com.")
+	assert.Nil(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, zone.ID, found.ZoneID)
+	found, err = UpdateKeyFind(db, "missing.example.com.")
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	keys, err := UpdateKeyList(db, zone.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(keys))
+
+	deleted, err := UpdateKeyDelete(db, zone.ID, key.ID)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	deleted, err = UpdateKeyDelete(db, zone.ID, key.ID)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+}
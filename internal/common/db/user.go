@@ -12,6 +12,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/packetframe/api/internal/api/auth"
+	"github.com/packetframe/api/internal/common/util"
 )
 
 var (
@@ -19,7 +20,7 @@ var (
 	GroupAdmin   = "core.ADMIN"   // User is permitted to modify all resources
 
 	ErrInvalidOrExpiredPasswordResetToken = errors.New("password reset token is invalid or expired")
-	ErrUserOwnsZones                      = errors.New("user has zones without other users, delete or add another user to these zones before deleting this user account")
+	ErrUserOwnsZones                      = errors.New("user has zones without other users or owners, delete or add another owner to these zones before deleting this user account")
 )
 
 type User struct {
@@ -29,9 +30,15 @@ type User struct {
 	Refer              string         `json:"refer"` // Where did you hear about Packetframe?
 	Groups             pq.StringArray `gorm:"type:text[]" json:"groups"`
 	PasswordHash       []byte         `json:"-"`
-	APIKey             string         `json:"-"` // Rotated manually by user if needed
-	Token              string         `json:"-"` // Rotated every n minutes (TODO: autorotate this)
+	APIKey             string         `json:"-"` // Legacy plaintext API key, migrated to a hashed APIKey by Connect
 	PasswordResetToken string         `json:"-"` // <token>:<unix timestamp when it was created>
+	TOTPSecret         string         `json:"-"`
+	TOTPEnabled        bool           `json:"totp_enabled"`
+	TOTPLastStep       int64          `gorm:"default:0" json:"-"`   // Time step of the last accepted TOTP code, so codes can't be used twice
+	RecoveryCodes      pq.StringArray `gorm:"type:text[]" json:"-"` // SHA256 hashes of unused recovery codes
+	LoginChallenge     string         `json:"-"`                    // <token>:<unix timestamp when it was created>, issued after a valid password when TOTP is enabled
+	Key                *APIKey        `gorm:"-" json:"-"`           // API key used to authenticate the current request, if any
+	Session            *Session       `gorm:"-" json:"-"`           // Session used to authenticate the current request, if any
 	CreatedAt          time.Time      `json:"-"`
 	UpdatedAt          time.Time      `json:"-"`
 }
@@ -42,19 +49,9 @@ func UserAdd(db *gorm.DB, email string, password string, refer string) error {
 	if err != nil {
 		return err
 	}
-	apiKey, err := auth.RandomString(48)
-	if err != nil {
-		return err
-	}
-	token, err := auth.RandomString(64)
-	if err != nil {
-		return err
-	}
 	return db.Create(&User{
 		Email:        email,
 		PasswordHash: passwordHash,
-		APIKey:       apiKey,
-		Token:        token,
 		Groups:       []string{},
 		Refer:        refer,
 	}).Error
@@ -88,18 +85,35 @@ func UserFindById(db *gorm.DB, userId string) (*User, error) {
 	return &user, nil
 }
 
-// UserFindByAuth finds a user by API key and returns nil if no user exists
+// UserFindByAuth finds a user by API key or session token and returns nil if no user exists
 func UserFindByAuth(db *gorm.DB, id string) (*User, error) {
-	var user User
-	res := db.Where("api_key = ?", id).Or("token = ?", id).First(&user)
-	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-		return nil, nil
+	key, err := apiKeyFind(db, id)
+	if err != nil {
+		return nil, err
 	}
-	if res.Error != nil {
-		return nil, res.Error
+	if key != nil {
+		user, err := UserFindById(db, key.UserID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		user.Key = key
+		return user, nil
 	}
 
-	return &user, nil
+	session, err := sessionFind(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		user, err := UserFindById(db, session.UserID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		user.Session = session
+		return user, nil
+	}
+
+	return nil, nil
 }
 
 // UserDelete deletes a user
@@ -110,9 +124,9 @@ func UserDelete(db *gorm.DB, email string) error {
 		return err
 	}
 
-	// Check if user is the only user in any zones
+	// Check if user is the only user in any zones that aren't owned by an organization
 	var zones []Zone
-	tx := db.Find(&zones, "? = ANY (users) AND array_length(users, 1) = 1", user.ID)
+	tx := db.Find(&zones, "? = ANY (users) AND array_length(users, 1) = 1 AND COALESCE(organization_id, '') = ''", user.ID)
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -126,12 +140,75 @@ func UserDelete(db *gorm.DB, email string) error {
 	if tx.Error != nil {
 		return tx.Error
 	}
+
+	// Check if user is the only owner of any zones before removing them from any zone
+	for _, zone := range zones {
+		if zone.OrganizationID != "" {
+			continue
+		}
+		owners, err := zoneOwners(db, &zone)
+		if err != nil {
+			return err
+		}
+		if len(owners) == 1 && owners[0] == user.ID {
+			return ErrUserOwnsZones
+		}
+	}
+
+	// Check if user is the last owner of any organizations that would be left behind
+	var memberships []OrganizationMember
+	if err := db.Find(&memberships, "user_id = ? AND role = ?", user.ID, RoleOwner).Error; err != nil {
+		return err
+	}
+	var soloOrganizations []string
+	for _, m := range memberships {
+		owners, err := organizationOwnerCount(db, m.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if owners > 1 {
+			continue
+		}
+
+		var members, orgZones int64
+		if err := db.Model(&OrganizationMember{}).Where("organization_id = ?", m.OrganizationID).Count(&members).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&Zone{}).Where("organization_id = ?", m.OrganizationID).Count(&orgZones).Error; err != nil {
+			return err
+		}
+		if members > 1 || orgZones > 0 {
+			return ErrUserOwnsOrganizations
+		}
+		soloOrganizations = append(soloOrganizations, m.OrganizationID)
+	}
+
 	for _, zone := range zones {
 		if err := ZoneUserDelete(db, zone.ID, user.Email); err != nil {
 			return err
 		}
 	}
 
+	// Delete empty organizations that only had this user as a member
+	for _, orgID := range soloOrganizations {
+		if err := OrganizationDelete(db, orgID); err != nil {
+			return err
+		}
+	}
+
+	// Remove user from organizations
+	if err := db.Delete(&OrganizationMember{}, "user_id = ?", user.ID).Error; err != nil {
+		return err
+	}
+
+	// Revoke API keys and sessions
+	if err := db.Delete(&APIKey{}, "user_id = ?", user.ID).Error; err != nil {
+		return err
+	}
+	if err := SessionDeleteAll(db, user.ID, ""); err != nil {
+		return err
+	}
+
 	return db.Where("id = ?", user.ID).Delete(&User{}).Error
 }
 
@@ -182,8 +259,24 @@ func UserGroupDelete(db *gorm.DB, uuid string, group string) error {
 	return db.Save(&user).Error
 }
 
-// UserResetPassword resets a User's password
-func UserResetPassword(db *gorm.DB, email string, password string) error {
+// UserAdminAuthorized checks if a user can use admin privileges, which requires TOTP when SettingRequireAdminTOTP is enabled.
+// API keys skip TOTP, so they can't use admin privileges when it's required.
+func UserAdminAuthorized(db *gorm.DB, user *User) (bool, error) {
+	if !util.StrSliceContains(user.Groups, GroupAdmin) {
+		return false, nil
+	}
+	requireTOTP, err := SettingGet(db, SettingRequireAdminTOTP)
+	if err != nil {
+		return false, err
+	}
+	if requireTOTP != "true" {
+		return true, nil
+	}
+	return user.TOTPEnabled && user.Key == nil, nil
+}
+
+// UserResetPassword resets a User's password and revokes their sessions, except for an optional session to keep
+func UserResetPassword(db *gorm.DB, email string, password string, exceptSessionID string) error {
 	var user User
 	if err := db.First(&user, "email = ?", email).Error; err != nil {
 		return err
@@ -196,14 +289,12 @@ func UserResetPassword(db *gorm.DB, email string, password string) error {
 	}
 	user.PasswordHash = passwordHash
 
-	// Generate new token to invalidate all new API requests from old logins
-	token, err := auth.RandomString(64)
-	if err != nil {
+	if err := db.Save(&user).Error; err != nil {
 		return err
 	}
-	user.Token = token
 
-	return db.Save(&user).Error
+	// Revoke all other sessions to log out old logins
+	return SessionDeleteAll(db, user.ID, exceptSessionID)
 }
 
 // UserCreatePasswordResetToken creates a User's password reset token
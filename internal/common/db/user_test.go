@@ -90,7 +90,7 @@ func TestDbUserResetPassword(t *testing.T) {
 
 	oldPassword := user1.PasswordHash
 
-	err = UserResetPassword(db, user1.Email, "new-password")
+	err = UserResetPassword(db, user1.Email, "new-password", "")
 	assert.Nil(t, err)
 
 	// Find user1
@@ -113,13 +113,25 @@ func TestDbUserFindByAuth(t *testing.T) {
 	user1, err := UserFindByEmail(db, "user1@example.com")
 	assert.Nil(t, err)
 
+	// New users don't have a plaintext API key
+	assert.Equal(t, "", user1.APIKey)
+	user1ByKey, err := UserFindByAuth(db, "")
+	assert.Nil(t, err)
+	assert.Nil(t, user1ByKey)
+
 	// Find user1 by API key
-	user1ByKey, err := UserFindByAuth(db, user1.APIKey)
+	key, err := APIKeyAdd(db, user1.ID, &APIKey{Name: "ci"})
 	assert.Nil(t, err)
-	assert.Equal(t, user1, user1ByKey)
+	user1ByKey, err = UserFindByAuth(db, key)
+	assert.Nil(t, err)
+	assert.Equal(t, user1.ID, user1ByKey.ID)
+	assert.NotNil(t, user1ByKey.Key)
 
-	// Find user1 by token
-	user1ByToken, err := UserFindByAuth(db, user1.Token)
+	// Find user1 by session token
+	token, session, err := SessionAdd(db, user1.ID, "192.0.2.1", "example user agent", "")
+	assert.Nil(t, err)
+	user1ByToken, err := UserFindByAuth(db, token)
 	assert.Nil(t, err)
-	assert.Equal(t, user1, user1ByToken)
+	assert.Equal(t, user1.ID, user1ByToken.ID)
+	assert.Equal(t, session.ID, user1ByToken.Session.ID)
 }
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VerificationLabel is the label under a zone that holds the ownership verification TXT record
+const VerificationLabel = "_packetframe-verification"
+
+// ZoneVerificationExpiry is how long a zone can stay pending verification before it's deleted so the name can be claimed again
+var ZoneVerificationExpiry = 7 * 24 * time.Hour
+
+// VerificationTXT returns the name and value of the TXT record that verifies ownership of the zone
+func (z *Zone) VerificationTXT() (string, string) {
+	return VerificationLabel + "." + z.Zone, fmt.Sprintf("packetframe-verification=%s", z.VerificationToken)
+}
+
+// ZoneListPendingVerification gets a list of all zones pending ownership verification
+func ZoneListPendingVerification(db *gorm.DB) ([]Zone, error) {
+	var zones []Zone
+	if err := db.Where("pending_verification = ?", true).Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// ZoneSetVerified marks a zone as verified so it's served and deletes the other pending claims of the zone's name
+func ZoneSetVerified(db *gorm.DB, zoneID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var zone Zone
+		if err := tx.First(&zone, "id = ?", zoneID).Error; err != nil {
+			return err
+		}
+
+		var verified int64
+		if err := tx.Model(&Zone{}).Where("zone = ? AND pending_verification = ? AND id != ?", zone.Zone, false, zoneID).Count(&verified).Error; err != nil {
+			return err
+		}
+		if verified > 0 {
+			return ErrZoneExists
+		}
+
+		if err := tx.Model(&Zone{}).Where("id = ?", zoneID).Updates(map[string]interface{}{
+			"pending_verification": false,
+			"verification_token":   "",
+		}).Error; err != nil {
+			return err
+		}
+
+		var claims []Zone
+		if err := tx.Find(&claims, "zone = ? AND pending_verification = ?", zone.Zone, true).Error; err != nil {
+			return err
+		}
+		for _, claim := range claims {
+			if _, err := ZoneDelete(tx, claim.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ZoneDeleteExpiredPending deletes zones that weren't verified within ZoneVerificationExpiry
+func ZoneDeleteExpiredPending(db *gorm.DB) error {
+	var zones []Zone
+	if err := db.Where("pending_verification = ? AND created_at < ?", true, time.Now().Add(-ZoneVerificationExpiry)).Find(&zones).Error; err != nil {
+		return err
+	}
+	for _, zone := range zones {
+		if _, err := ZoneDelete(db, zone.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
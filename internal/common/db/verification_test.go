@@ -0,0 +1,82 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneVerification(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	err = UserAdd(db, "user1@example.com", "password1", "example referrer")
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	_, err = ZoneAdd(db, "example2.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+
+	// New zones are pending with a verification token
+	example1, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.True(t, example1.PendingVerification)
+	name, value := example1.VerificationTXT()
+	assert.Equal(t, "_packetframe-verification.example1.com.", name)
+	assert.Contains(t, value, example1.VerificationToken)
+	pending, err := ZoneListPendingVerification(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pending))
+
+	// Verify example1.com
+	err = ZoneSetVerified(db, example1.ID)
+	assert.Nil(t, err)
+	example1, err = ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.False(t, example1.PendingVerification)
+	pending, err = ZoneListPendingVerification(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pending))
+
+	// Expired pending zones are deleted, verified zones are kept
+	err = db.Model(&Zone{}).Where("1 = 1").Update("created_at", time.Now().Add(-ZoneVerificationExpiry)).Error
+	assert.Nil(t, err)
+	err = ZoneDeleteExpiredPending(db)
+	assert.Nil(t, err)
+	zones, err := ZoneList(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+	assert.Equal(t, "example1.com.", zones[0].Zone)
+}
+
+func TestZoneVerificationParallelClaims(t *testing.T) {
+	db, err := TestSetup()
+	assert.Nil(t, err)
+
+	for _, email := range []string{"user1@example.com", "user2@example.com"} {
+		err = UserAdd(db, email, "password1", "example referrer")
+		assert.Nil(t, err)
+	}
+
+	// Pending claims don't reserve the name, but a user can only claim it once
+	claim1, err := ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Nil(t, err)
+	claim2, err := ZoneAdd(db, "example1.com", "user2@example.com", 0)
+	assert.Nil(t, err)
+	assert.NotEqual(t, claim1.ID, claim2.ID)
+	_, err = ZoneAdd(db, "example1.com", "user2@example.com", 0)
+	assert.Equal(t, ErrZoneExists, err)
+
+	// Verifying a claim deletes the other claims and reserves the name
+	assert.Nil(t, ZoneSetVerified(db, claim2.ID))
+	zones, err := ZoneList(db)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(zones))
+	assert.Equal(t, claim2.ID, zones[0].ID)
+	_, err = ZoneAdd(db, "example1.com", "user1@example.com", 0)
+	assert.Equal(t, ErrZoneExists, err)
+	zone, err := ZoneFind(db, "example1.com")
+	assert.Nil(t, err)
+	assert.Equal(t, claim2.ID, zone.ID)
+}
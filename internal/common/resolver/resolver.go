@@ -0,0 +1,209 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver queries a recursive resolver and authoritative nameservers
+type Resolver struct {
+	Addr    string        // Address of the recursive resolver used for lookups
+	Port    string        // Port used to query authoritative nameservers directly
+	Timeout time.Duration // Timeout of a single DNS query
+}
+
+// Default is the resolver used by the package level functions
+var Default = &Resolver{Addr: "1.1.1.1:53", Port: "53", Timeout: 5 * time.Second}
+
+var (
+	ErrNoParentZone = errors.New("no parent zone found")
+	ErrNoServers    = errors.New("no parent nameservers responded")
+)
+
+// exchange sends a single query to a server
+func (res *Resolver) exchange(server, name string, qtype uint16, recursive bool) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = recursive
+	m.SetEdns0(4096, false)
+
+	c := &dns.Client{Timeout: res.Timeout}
+	r, _, err := c.Exchange(m, server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s %s from %s: %s", name, dns.TypeToString[qtype], server, dns.RcodeToString[r.Rcode])
+	}
+	return r, nil
+}
+
+// filter returns the records matching a name and type
+func filter(rrs []dns.RR, name string, qtype uint16) []dns.RR {
+	var out []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == qtype && strings.EqualFold(rr.Header().Name, dns.Fqdn(name)) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// Lookup queries the recursive resolver and returns the answers matching name and type
+func (res *Resolver) Lookup(name string, qtype uint16) ([]dns.RR, error) {
+	r, err := res.exchange(res.Addr, name, qtype, true)
+	if err != nil {
+		return nil, err
+	}
+	return filter(r.Answer, name, qtype), nil
+}
+
+// Resolve queries the recursive resolver and returns all answers of a type, including those at the end of a CNAME chain
+func (res *Resolver) Resolve(name string, qtype uint16) ([]dns.RR, error) {
+	r, err := res.exchange(res.Addr, name, qtype, true)
+	if err != nil {
+		return nil, err
+	}
+	var rrs []dns.RR
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// TXT returns the TXT strings of a name, joining multi-string records
+func (res *Resolver) TXT(name string) ([]string, error) {
+	rrs, err := res.Lookup(name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txts []string
+	for _, rr := range rrs {
+		txts = append(txts, strings.Join(rr.(*dns.TXT).Txt, ""))
+	}
+	return txts, nil
+}
+
+// Parent finds the closest enclosing zone of a zone and returns its name and nameservers
+func (res *Resolver) Parent(zone string) (string, []string, error) {
+	labels := dns.SplitDomainName(zone)
+	for i := 1; i < len(labels); i++ {
+		parent := dns.Fqdn(strings.Join(labels[i:], "."))
+		rrs, err := res.Lookup(parent, dns.TypeNS)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(rrs) > 0 {
+			var servers []string
+			for _, rr := range rrs {
+				servers = append(servers, rr.(*dns.NS).Ns)
+			}
+			return parent, servers, nil
+		}
+	}
+	return "", nil, ErrNoParentZone
+}
+
+// Addresses resolves a nameserver hostname to its IPv4 and IPv6 addresses
+func (res *Resolver) Addresses(host string) ([]string, error) {
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		rrs, err := res.Lookup(host, qtype)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// Delegation asks the parent zone's nameservers directly for the records of a zone's delegation, such as NS or DS.
+// This doesn't depend on the zone's own nameservers answering, so it works for zones that aren't served yet.
+func (res *Resolver) Delegation(zone string, qtype uint16) ([]dns.RR, error) {
+	_, servers, err := res.Parent(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error = ErrNoServers
+	for _, server := range servers {
+		addrs, err := res.Addresses(server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, addr := range addrs {
+			r, err := res.exchange(net.JoinHostPort(addr, res.Port), zone, qtype, false)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			// NS records are returned as a referral in the authority section, DS records as an answer
+			return append(filter(r.Answer, zone, qtype), filter(r.Ns, zone, qtype)...), nil
+		}
+	}
+	return nil, lastErr
+}
+
+// DelegatedNS returns the nameserver hostnames a zone is delegated to by its parent
+func (res *Resolver) DelegatedNS(zone string) ([]string, error) {
+	rrs, err := res.Delegation(zone, dns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	var nameservers []string
+	for _, rr := range rrs {
+		nameservers = append(nameservers, strings.ToLower(rr.(*dns.NS).Ns))
+	}
+	return nameservers, nil
+}
+
+// Lookup queries the default resolver, see Resolver.Lookup
+func Lookup(name string, qtype uint16) ([]dns.RR, error) {
+	return Default.Lookup(name, qtype)
+}
+
+// Resolve queries the default resolver, see Resolver.Resolve
+func Resolve(name string, qtype uint16) ([]dns.RR, error) {
+	return Default.Resolve(name, qtype)
+}
+
+// TXT queries the default resolver, see Resolver.TXT
+func TXT(name string) ([]string, error) {
+	return Default.TXT(name)
+}
+
+// Parent queries the default resolver, see Resolver.Parent
+func Parent(zone string) (string, []string, error) {
+	return Default.Parent(zone)
+}
+
+// Addresses queries the default resolver, see Resolver.Addresses
+func Addresses(host string) ([]string, error) {
+	return Default.Addresses(host)
+}
+
+// Delegation queries the default resolver, see Resolver.Delegation
+func Delegation(zone string, qtype uint16) ([]dns.RR, error) {
+	return Default.Delegation(zone, qtype)
+}
+
+// DelegatedNS queries the default resolver, see Resolver.DelegatedNS
+func DelegatedNS(zone string) ([]string, error) {
+	return Default.DelegatedNS(zone)
+}
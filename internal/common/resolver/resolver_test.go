@@ -0,0 +1,47 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolver(t *testing.T) {
+	shutdown, err := TestServer([]string{
+		"com. 3600 IN NS ns.parent.test.",
+		"ns.parent.test. 3600 IN A 127.0.0.1",
+		"example.com. 3600 IN NS ns1.packetframe.com.",
+		"example.com. 3600 IN NS ns2.packetframe.com.",
+		"example.com. 3600 IN DS 12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF",
+		`_verify.example.com. 300 IN TXT "part1" "part2"`,
+	})
+	assert.Nil(t, err)
+	defer shutdown()
+
+	txts, err := TXT("_verify.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"part1part2"}, txts)
+
+	parent, servers, err := Parent("www.example.com.")
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com.", parent)
+	assert.Equal(t, 2, len(servers))
+
+	parent, servers, err = Parent("example.com.")
+	assert.Nil(t, err)
+	assert.Equal(t, "com.", parent)
+	assert.Equal(t, []string{"ns.parent.test."}, servers)
+
+	nameservers, err := DelegatedNS("example.com.")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ns1.packetframe.com.", "ns2.packetframe.com."}, nameservers)
+
+	ds, err := Delegation("example.com.", dns.TypeDS)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ds))
+	assert.Equal(t, uint16(12345), ds[0].(*dns.DS).KeyTag)
+
+	_, _, err = Parent("example.")
+	assert.ErrorIs(t, err, ErrNoParentZone)
+}
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// NewTestServer starts a local DNS server answering from records in zone file format and returns a resolver that queries it.
+// NS queries without recursion desired are answered as referrals, like a parent zone would. The returned function stops the server.
+func NewTestServer(records []string) (*Resolver, func() error, error) {
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		for _, rr := range filter(rrs, q.Name, q.Qtype) {
+			if q.Qtype == dns.TypeNS && !r.RecursionDesired {
+				m.Ns = append(m.Ns, rr)
+			} else {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	return &Resolver{Addr: pc.LocalAddr().String(), Port: port, Timeout: Default.Timeout}, server.Shutdown, nil
+}
+
+// TestServer starts a test server like NewTestServer and makes it the default resolver until the returned function stops it
+func TestServer(records []string) (func() error, error) {
+	res, shutdown, err := NewTestServer(records)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := Default
+	Default = res
+	return func() error {
+		Default = previous
+		return shutdown()
+	}, nil
+}
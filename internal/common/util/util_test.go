@@ -1,6 +1,7 @@
 package util
 
 import (
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,3 +12,12 @@ func TestStrSliceContains(t *testing.T) {
 	assert.True(t, StrSliceContains(slice, "a"))
 	assert.False(t, StrSliceContains(slice, "d"))
 }
+
+func TestPublicIP(t *testing.T) {
+	for _, ip := range []string{"192.0.2.1", "203.0.113.1", "1.1.1.1", "2001:db8::1", "2606:4700::1111"} {
+		assert.True(t, PublicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"0.0.0.0", "127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "100.64.0.1", "169.254.169.254", "224.0.0.1", "255.255.255.255", "::", "::1", "fe80::1", "fd00::1", "ff02::1", "::ffff:127.0.0.1", "::ffff:10.0.0.1"} {
+		assert.False(t, PublicIP(net.ParseIP(ip)), ip)
+	}
+}
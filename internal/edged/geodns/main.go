@@ -0,0 +1,315 @@
+package geodns
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/api/validation"
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// Location stores the fields of a MaxMind country, city or ASN database entry used to choose geo records
+type Location struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN uint32 `maxminddb:"autonomous_system_number"`
+}
+
+var (
+	// Locate looks up the location of an address and the prefix length of the network it was found in, it can be replaced in tests
+	Locate = locate
+
+	// readers stores the open MaxMind databases
+	readers []*maxminddb.Reader
+
+	// Choose returns a number in [0, n) used to choose a weighted record for each query, it can be replaced in tests
+	Choose = rand.Intn
+
+	// sets stores geo and weighted records by lowercase FQDN
+	sets   = map[string][]db.Record{}
+	setsMu sync.RWMutex
+)
+
+// Open loads MaxMind format databases, such as a country and an ASN database, used to locate clients
+func Open(paths []string) error {
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening %s: %s", path, err)
+		}
+		readers = append(readers, reader)
+	}
+	return nil
+}
+
+// locate looks up an address in each database and merges the results
+func locate(ip net.IP) (Location, int) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	var location Location
+	prefix := 0
+	for _, reader := range readers {
+		var l Location
+		network, ok, err := reader.LookupNetwork(ip, &l)
+		if err != nil {
+			continue
+		}
+		// The answer is only valid for the most specific network the address was found in, or the network it wasn't found in
+		if ones, _ := network.Mask.Size(); ones > prefix {
+			prefix = ones
+		}
+		if !ok {
+			continue
+		}
+		if l.Continent.Code != "" {
+			location.Continent = l.Continent
+		}
+		if l.Country.ISOCode != "" {
+			location.Country = l.Country
+		}
+		if l.ASN != 0 {
+			location.ASN = l.ASN
+		}
+	}
+	return location, prefix
+}
+
+// matches checks if a location matches a geo match
+func (l Location) matches(m db.GeoMatch) bool {
+	switch m.Kind {
+	case db.GeoASN:
+		return fmt.Sprint(l.ASN) == m.Value
+	case db.GeoCountry:
+		return l.Country.ISOCode == m.Value
+	case db.GeoContinent:
+		return l.Continent.Code == m.Value
+	default:
+		return true
+	}
+}
+
+// answers returns the records matching a location, preferring ASN over country over continent matches and falling back to default records
+func answers(records []db.Record, location Location) []db.Record {
+	for _, kind := range []string{db.GeoASN, db.GeoCountry, db.GeoContinent, db.GeoDefault} {
+		var matched []db.Record
+		for _, record := range records {
+			m, err := db.ParseGeo(record.Geo)
+			if err == nil && m.Kind == kind && location.matches(m) {
+				matched = append(matched, record)
+			}
+		}
+		if len(matched) > 0 {
+			return matched
+		}
+	}
+	return nil
+}
+
+// weighted chooses one record of a weighted set by weight, or chooses evenly if no record has a weight
+func weighted(set []db.Record) []db.Record {
+	var total uint32
+	for _, record := range set {
+		total += record.Weight
+	}
+	if total == 0 {
+		return []db.Record{set[Choose(len(set))]}
+	}
+	n := uint32(Choose(int(total)))
+	for _, record := range set {
+		if n < record.Weight {
+			return []db.Record{record}
+		}
+		n -= record.Weight
+	}
+	return set[len(set)-1:]
+}
+
+// healthy returns the records whose health checks pass, keeping every record of a weighted set of a type if all of them are unhealthy as it's more likely the checks are failing than every address
+func healthy(records []db.Record, unhealthy map[string]bool) []db.Record {
+	types := map[string]bool{}
+	for _, record := range records {
+		if !unhealthy[record.ID] {
+			types[record.Type] = true
+		}
+	}
+
+	var filtered []db.Record
+	for _, record := range records {
+		if !unhealthy[record.ID] || !types[record.Type] {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// clientSubnet returns a message's EDNS Client Subnet option, or nil if it has none
+func clientSubnet(r *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return subnet
+		}
+	}
+	return nil
+}
+
+// answer builds the response to a query for a name's geo or weighted records from a client address
+func answer(r *dns.Msg, remote net.IP, records []db.Record) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	if len(r.Question) == 0 {
+		return m
+	}
+	if records == nil {
+		m.Rcode = dns.RcodeNameError
+		return m
+	}
+	q := r.Question[0]
+
+	// Records of the queried type and whether their answers depend on the client's location
+	var set []db.Record
+	dependent := false
+	for _, record := range records {
+		if record.Type == dns.TypeToString[q.Qtype] {
+			set = append(set, record)
+			if record.Policy == db.PolicyGeo && !strings.EqualFold(record.Geo, db.GeoDefault) {
+				dependent = true
+			}
+		}
+	}
+
+	subnet := clientSubnet(r)
+	var chosen []db.Record
+	prefix := 0
+	if len(set) > 0 && set[0].Policy == db.PolicyWeighted {
+		// Weighted records are chosen for each query, independent of the client's location
+		chosen = weighted(set)
+	} else {
+		// Locate the client by the resolver's EDNS Client Subnet if it has one
+		ip := remote
+		if subnet != nil && subnet.SourceNetmask > 0 {
+			ip = subnet.Address
+		}
+		var location Location
+		location, prefix = Locate(ip)
+		chosen = answers(set, location)
+	}
+
+	for _, record := range chosen {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", q.Name, record.TTL, record.Type, record.Value))
+		if err != nil {
+			log.Warnf("%s record %s %s: %s", record.Policy, q.Name, record.Value, err)
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+
+	if r.IsEdns0() != nil {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		if subnet != nil {
+			// The scope tells resolvers which clients they can cache the answer for
+			scope := uint8(0)
+			if dependent && subnet.SourceNetmask > 0 {
+				// Without a network from the databases, the answer is only valid for the client's own subnet
+				scope = uint8(prefix)
+				if scope == 0 {
+					scope = subnet.SourceNetmask
+				}
+			}
+			m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        subnet.Family,
+				SourceNetmask: subnet.SourceNetmask,
+				SourceScope:   scope,
+				Address:       subnet.Address,
+			})
+		}
+	}
+	return m
+}
+
+// handle answers a query for a geo or weighted name
+func handle(w dns.ResponseWriter, r *dns.Msg) {
+	var records []db.Record
+	if len(r.Question) > 0 {
+		setsMu.RLock()
+		records = sets[strings.ToLower(r.Question[0].Name)]
+		setsMu.RUnlock()
+	}
+
+	var remote net.IP
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		remote = addr.IP
+	case *net.TCPAddr:
+		remote = addr.IP
+	}
+
+	if err := w.WriteMsg(answer(r, remote, records)); err != nil {
+		log.Warnf("dns write message: %s", err)
+	}
+}
+
+// LoadRecordHandlers loads geo and weighted records from the database and registers a handler on the SCRIPT DNS server for each name.
+// Weighted records that are unhealthy in their zone's dynamic state aren't answered.
+func LoadRecordHandlers(database *gorm.DB) {
+	records, err := db.RecordListPerQuery(database)
+	if err != nil {
+		log.Warnf("loading geo and weighted records: %s", err)
+		return
+	}
+
+	unhealthy := map[string]bool{}
+	names := map[string][]db.Record{}
+	for _, record := range records {
+		if record.Policy == db.PolicyWeighted {
+			state, err := record.Zone.DynamicState()
+			if err != nil {
+				log.Warnf("parsing dynamic state (%s): %s", record.Zone.Zone, err)
+			}
+			for _, recordID := range state.Unhealthy {
+				unhealthy[recordID] = true
+			}
+		}
+		name := validation.RecordName(record.Zone.Zone, record.Label)
+		names[name] = append(names[name], record)
+	}
+	for name, records := range names {
+		names[name] = healthy(records, unhealthy)
+	}
+
+	setsMu.Lock()
+	previous := sets
+	sets = names
+	setsMu.Unlock()
+
+	for name := range names {
+		if _, ok := previous[name]; !ok {
+			log.Debugf("Registering %s to geo and weighted records", name)
+			dns.HandleFunc(name, handle)
+		}
+	}
+	for name := range previous {
+		if _, ok := names[name]; !ok {
+			dns.HandleRemove(name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package geodns
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// testLocate locates 192.0.2.0/24 in AS64500 in the US and 198.51.100.0/24 in DE
+func testLocate(ip net.IP) (Location, int) {
+	var location Location
+	switch {
+	case ip.Equal(net.ParseIP("192.0.2.0")), ip.Equal(net.ParseIP("192.0.2.1")):
+		location.Continent.Code = "NA"
+		location.Country.ISOCode = "US"
+		location.ASN = 64500
+		return location, 24
+	case ip.Equal(net.ParseIP("198.51.100.0")), ip.Equal(net.ParseIP("198.51.100.1")):
+		location.Continent.Code = "EU"
+		location.Country.ISOCode = "DE"
+		return location, 20
+	}
+	return location, 0
+}
+
+var testRecords = []db.Record{
+	{Type: "A", Value: "203.0.113.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"},
+	{Type: "A", Value: "203.0.113.2", TTL: 300, Policy: db.PolicyGeo, Geo: "continent:EU"},
+	{Type: "A", Value: "203.0.113.3", TTL: 300, Policy: db.PolicyGeo, Geo: "country:us"},
+	{Type: "A", Value: "203.0.113.4", TTL: 300, Policy: db.PolicyGeo, Geo: "asn:64500"},
+	{Type: "A", Value: "203.0.113.5", TTL: 300, Policy: db.PolicyGeo, Geo: "country:US"},
+	{Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"},
+}
+
+// answerValues returns the addresses in a response
+func answerValues(m *dns.Msg) []string {
+	var values []string
+	for _, rr := range m.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			values = append(values, rr.A.String())
+		case *dns.AAAA:
+			values = append(values, rr.AAAA.String())
+		}
+	}
+	return values
+}
+
+func TestAnswers(t *testing.T) {
+	var us, de, other Location
+	us.Country.ISOCode = "US"
+	us.Continent.Code = "NA"
+	de.Country.ISOCode = "DE"
+	de.Continent.Code = "EU"
+
+	values := func(records []db.Record) []string {
+		var v []string
+		for _, record := range records {
+			v = append(v, record.Value)
+		}
+		return v
+	}
+	assert.Equal(t, []string{"203.0.113.3", "203.0.113.5"}, values(answers(testRecords[:5], us)))
+	assert.Equal(t, []string{"203.0.113.2"}, values(answers(testRecords[:5], de)))
+	assert.Equal(t, []string{"203.0.113.1"}, values(answers(testRecords[:5], other)))
+	us.ASN = 64500
+	assert.Equal(t, []string{"203.0.113.4"}, values(answers(testRecords[:5], us)))
+	assert.Empty(t, answers(testRecords[1:5], other))
+}
+
+func TestAnswer(t *testing.T) {
+	defer func() { Locate = locate }()
+	Locate = testLocate
+
+	// Clients are located by their address without EDNS Client Subnet
+	r := new(dns.Msg)
+	r.SetQuestion("www.example.com.", dns.TypeA)
+	m := answer(r, net.ParseIP("198.51.100.1"), testRecords)
+	assert.True(t, m.Authoritative)
+	assert.Equal(t, []string{"203.0.113.2"}, answerValues(m))
+	assert.Nil(t, m.IsEdns0())
+
+	// The client subnet is preferred and its scope is the prefix of the network it was found in
+	r.SetEdns0(4096, false)
+	r.IsEdns0().Option = append(r.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.0.2.0").To4()})
+	m = answer(r, net.ParseIP("198.51.100.1"), testRecords)
+	assert.Equal(t, []string{"203.0.113.4"}, answerValues(m))
+	subnet := clientSubnet(m)
+	assert.NotNil(t, subnet)
+	assert.Equal(t, uint8(24), subnet.SourceNetmask)
+	assert.Equal(t, uint8(24), subnet.SourceScope)
+
+	// Answers that don't depend on location are valid for all clients
+	r.Question[0].Qtype = dns.TypeAAAA
+	m = answer(r, net.ParseIP("198.51.100.1"), testRecords)
+	assert.Equal(t, []string{"2001:db8::1"}, answerValues(m))
+	assert.Equal(t, uint8(0), clientSubnet(m).SourceScope)
+
+	// Answers for clients that weren't found are only valid for their subnet
+	r.Question[0].Qtype = dns.TypeA
+	r.IsEdns0().Option = []dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("203.0.113.0").To4()}}
+	m = answer(r, net.ParseIP("198.51.100.1"), testRecords)
+	assert.Equal(t, []string{"203.0.113.1"}, answerValues(m))
+	assert.Equal(t, uint8(24), clientSubnet(m).SourceScope)
+
+	// Names below a geo name don't exist
+	r.SetQuestion("sub.www.example.com.", dns.TypeA)
+	m = answer(r, net.ParseIP("198.51.100.1"), nil)
+	assert.Equal(t, dns.RcodeNameError, m.Rcode)
+}
+
+func TestAnswerWeighted(t *testing.T) {
+	defer func() { Choose = rand.Intn }()
+	records := []db.Record{
+		{ID: "1", Type: "A", Value: "203.0.113.1", TTL: 300, Policy: db.PolicyWeighted, Weight: 1},
+		{ID: "2", Type: "A", Value: "203.0.113.2", TTL: 300, Policy: db.PolicyWeighted, Weight: 3},
+		{ID: "3", Type: "A", Value: "203.0.113.3", TTL: 300, Policy: db.PolicyWeighted, Weight: 0},
+	}
+
+	// A record is chosen by weight for each query
+	r := new(dns.Msg)
+	r.SetQuestion("lb.example.com.", dns.TypeA)
+	r.SetEdns0(4096, false)
+	r.IsEdns0().Option = append(r.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.0.2.0").To4()})
+	Choose = func(n int) int { return 0 }
+	m := answer(r, net.ParseIP("198.51.100.1"), records)
+	assert.Equal(t, []string{"203.0.113.1"}, answerValues(m))
+	Choose = func(n int) int { return n - 1 }
+	m = answer(r, net.ParseIP("198.51.100.1"), records)
+	assert.Equal(t, []string{"203.0.113.2"}, answerValues(m))
+
+	// The choice doesn't depend on the client's location
+	assert.Equal(t, uint8(0), clientSubnet(m).SourceScope)
+
+	// Records are chosen evenly if no record has a weight
+	assert.Equal(t, records[2:], weighted([]db.Record{{Weight: 0}, records[2]}))
+}
+
+func TestHealthy(t *testing.T) {
+	records := []db.Record{
+		{ID: "1", Type: "A", Value: "203.0.113.1", Policy: db.PolicyWeighted, Weight: 1},
+		{ID: "2", Type: "A", Value: "203.0.113.2", Policy: db.PolicyWeighted, Weight: 3},
+		{ID: "3", Type: "AAAA", Value: "2001:db8::1", Policy: db.PolicyWeighted, Weight: 1},
+	}
+
+	// Unhealthy records aren't answered unless every record of their type is unhealthy
+	assert.Equal(t, records, healthy(records, nil))
+	assert.Equal(t, records[1:], healthy(records, map[string]bool{"1": true}))
+	assert.Equal(t, records, healthy(records, map[string]bool{"1": true, "2": true, "3": true}))
+}
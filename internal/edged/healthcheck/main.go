@@ -0,0 +1,128 @@
+package healthcheck
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+var (
+	// Timeout is the timeout of a single health check
+	Timeout = 5 * time.Second
+	// Concurrency is the maximum number of health checks run at once
+	Concurrency = 32
+	// Allowed checks if an address may be health checked, so records can't be used to probe the networks of edge nodes
+	Allowed = util.PublicIP
+)
+
+// Check runs a record's health check against its address and returns nil if the address is healthy
+func Check(record db.Record) error {
+	check := record.HealthCheck
+	ip := net.ParseIP(record.Value)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", record.Value)
+	}
+	if !Allowed(ip) {
+		return fmt.Errorf("refusing to check non-public address %s", ip)
+	}
+
+	port := check.Port
+	switch {
+	case port != 0:
+	case check.Type == db.HealthCheckHTTP:
+		port = 80
+	case check.Type == db.HealthCheckHTTPS:
+		port = 443
+	}
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+
+	switch check.Type {
+	case db.HealthCheckTCP:
+		conn, err := net.DialTimeout("tcp", addr, Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case db.HealthCheckHTTP, db.HealthCheckHTTPS:
+		path := check.Path
+		if path == "" {
+			path = "/"
+		}
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s%s", check.Type, addr, path), nil)
+		if err != nil {
+			return err
+		}
+		if check.Host != "" {
+			req.Host = check.Host
+		}
+		client := &http.Client{
+			Timeout: Timeout,
+			Transport: &http.Transport{
+				// Checks are for availability of the address, certificates are the origin's responsibility
+				TLSClientConfig: &tls.Config{ServerName: check.Host, InsecureSkipVerify: true},
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("HTTP status %d", resp.StatusCode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported health check type %s", check.Type)
+	}
+}
+
+// Update runs the health checks of all records and stores the results for this node
+func Update(database *gorm.DB, node string) error {
+	records, err := db.RecordListHealthChecked(database)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, Concurrency)
+	for _, record := range records {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(record db.Record) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			health := &db.RecordHealth{
+				RecordID:  record.ID,
+				Node:      node,
+				ZoneID:    record.ZoneID,
+				Healthy:   true,
+				CheckedAt: time.Now(),
+			}
+			if err := Check(record); err != nil {
+				health.Healthy = false
+				health.Error = err.Error()
+			}
+			if err := db.RecordHealthSet(database, health); err != nil {
+				log.Warnf("storing record health (%s): %s", record.ID, err)
+			}
+		}(record)
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,87 @@
+package healthcheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/util"
+)
+
+// localPort returns the port of a local listener address
+func localPort(t *testing.T, addr string) uint16 {
+	_, port, err := net.SplitHostPort(addr)
+	assert.Nil(t, err)
+	p, err := strconv.Atoi(port)
+	assert.Nil(t, err)
+	return uint16(p)
+}
+
+// allowLocal permits health checks of the local test servers
+func allowLocal() func() {
+	Allowed = func(net.IP) bool { return true }
+	return func() { Allowed = util.PublicIP }
+}
+
+func TestCheckNonPublic(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.0.0.1", "169.254.169.254", "fe80::1"} {
+		record := db.Record{Type: "A", Value: ip, HealthCheck: db.HealthCheck{Type: db.HealthCheckTCP, Port: 22}}
+		assert.NotNil(t, Check(record), ip)
+	}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	defer allowLocal()()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	port := localPort(t, server.Listener.Addr().String())
+
+	record := db.Record{Type: "A", Value: "127.0.0.1", HealthCheck: db.HealthCheck{Type: db.HealthCheckHTTP, Port: port, Path: "/up", Host: "www.example.com"}}
+	assert.Nil(t, Check(record))
+
+	record.HealthCheck.Path = "/down"
+	assert.NotNil(t, Check(record))
+
+	record.HealthCheck.Path = "/up"
+	record.HealthCheck.Host = ""
+	assert.NotNil(t, Check(record))
+}
+
+func TestCheckHTTPS(t *testing.T) {
+	defer allowLocal()()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	record := db.Record{Type: "A", Value: "127.0.0.1", HealthCheck: db.HealthCheck{Type: db.HealthCheckHTTPS, Port: localPort(t, server.Listener.Addr().String())}}
+	assert.Nil(t, Check(record))
+}
+
+func TestCheckTCP(t *testing.T) {
+	defer allowLocal()()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	port := localPort(t, listener.Addr().String())
+
+	record := db.Record{Type: "A", Value: "127.0.0.1", HealthCheck: db.HealthCheck{Type: db.HealthCheckTCP, Port: port}}
+	assert.Nil(t, Check(record))
+
+	assert.Nil(t, listener.Close())
+	assert.NotNil(t, Check(record))
+}
@@ -3,7 +3,6 @@ package scriptdns
 import (
 	"encoding/json"
 	"fmt"
-	"reflect"
 	"strings"
 	"time"
 
@@ -242,11 +241,11 @@ func LoadRecordHandlers(database *gorm.DB) {
 		}
 	}
 
-	// Remove handlers that don't have a record in the database
-	muxLabels := reflect.ValueOf(dns.DefaultServeMux).Elem().FieldByName("z").MapKeys()
-	for _, label := range muxLabels {
-		if !labelExists(label.String(), scriptRecords) {
-			dns.HandleRemove(label.String())
+	// Remove handlers that don't have a record in the database. Other handlers on the server, such as geo records, are left alone.
+	for label := range scriptCache {
+		if !labelExists(label, scriptRecords) {
+			dns.HandleRemove(label)
+			delete(scriptCache, label)
 		}
 	}
 }
@@ -0,0 +1,98 @@
+package transfer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+var (
+	// Local is the address of the local knot server
+	Local = "127.0.0.1:53"
+	// Timeout is the timeout of a single SOA query
+	Timeout = 5 * time.Second
+)
+
+// serial queries a server for a zone's SOA serial, signing the query if a TSIG key is set
+func serial(server, zone string, key db.TSIGKey) (uint32, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(zone), dns.TypeSOA)
+	m.RecursionDesired = false
+
+	c := &dns.Client{Timeout: Timeout}
+	if key.Configured() {
+		c.TsigSecret = map[string]string{key.Name: key.Secret}
+		m.SetTsig(key.Name, dns.Fqdn(key.Algorithm), 300, time.Now().Unix())
+	}
+
+	r, _, err := c.Exchange(m, server)
+	if err != nil {
+		return 0, err
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("%s SOA from %s: %s", zone, server, dns.RcodeToString[r.Rcode])
+	}
+	for _, rr := range r.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, fmt.Errorf("%s SOA from %s: no SOA in answer", zone, server)
+}
+
+// Check compares the serial served by the local knot server with the serial of the zone's primaries
+func Check(zone *db.Zone, node string) *db.ZoneTransfer {
+	transfer := &db.ZoneTransfer{
+		ZoneID:    zone.ID,
+		Node:      node,
+		CheckedAt: time.Now(),
+	}
+
+	local, err := serial(Local, zone.Zone, db.TSIGKey{})
+	if err == nil {
+		transfer.Serial = local
+	}
+
+	// Use the first primary that answers
+	var primaryErr error
+	for _, primary := range zone.Primaries {
+		transfer.PrimarySerial, primaryErr = serial(primary, zone.Zone, zone.TSIG)
+		if primaryErr == nil {
+			break
+		}
+	}
+
+	switch {
+	case err != nil:
+		transfer.Status = db.TransferPending
+		transfer.Error = err.Error()
+	case primaryErr != nil:
+		transfer.Status = db.TransferError
+		transfer.Error = primaryErr.Error()
+	case transfer.Serial == transfer.PrimarySerial:
+		transfer.Status = db.TransferSynced
+	default:
+		transfer.Status = db.TransferBehind
+	}
+	return transfer
+}
+
+// Update checks the transfer status of all secondary zones and stores it for this node
+func Update(database *gorm.DB, node string) error {
+	zones, err := db.ZoneListSecondary(database)
+	if err != nil {
+		return err
+	}
+
+	for i := range zones {
+		if err := db.ZoneTransferSet(database, Check(&zones[i], node)); err != nil {
+			log.Warnf("storing transfer status (%s): %s", zones[i].Zone, err)
+		}
+	}
+	return nil
+}
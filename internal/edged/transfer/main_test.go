@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// soaServer starts a local DNS server answering SOA queries for example.com with a serial, or REFUSED if serial is 0
+func soaServer(t *testing.T, serial uint32) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if serial == 0 {
+			m.Rcode = dns.RcodeRefused
+		} else {
+			m.Answer = append(m.Answer, &dns.SOA{
+				Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+				Ns:     "ns1.example.com.",
+				Mbox:   "hostmaster.example.com.",
+				Serial: serial,
+			})
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestCheck(t *testing.T) {
+	primary := soaServer(t, 10)
+	zone := &db.Zone{ID: "zone", Zone: "example.com.", Kind: db.ZoneKindSecondary, Primaries: []string{primary}}
+
+	Local = soaServer(t, 10)
+	transfer := Check(zone, "node1")
+	assert.Equal(t, db.TransferSynced, transfer.Status)
+	assert.Equal(t, uint32(10), transfer.Serial)
+
+	Local = soaServer(t, 9)
+	transfer = Check(zone, "node1")
+	assert.Equal(t, db.TransferBehind, transfer.Status)
+	assert.Equal(t, uint32(9), transfer.Serial)
+	assert.Equal(t, uint32(10), transfer.PrimarySerial)
+
+	// Zones that aren't loaded are refused
+	Local = soaServer(t, 0)
+	transfer = Check(zone, "node1")
+	assert.Equal(t, db.TransferPending, transfer.Status)
+
+	// Unreachable primaries are skipped
+	Local = soaServer(t, 10)
+	zone.Primaries = []string{soaServer(t, 0), primary}
+	transfer = Check(zone, "node1")
+	assert.Equal(t, db.TransferSynced, transfer.Status)
+}
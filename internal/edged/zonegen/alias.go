@@ -0,0 +1,118 @@
+package zonegen
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+var (
+	// Resolve looks up the records of an ALIAS target, it can be replaced in tests
+	Resolve = resolver.Resolve
+
+	aliasMinTTL = 30 * time.Second // Shortest time a resolved ALIAS target is cached for
+	aliasMaxTTL = time.Hour        // Longest time a resolved ALIAS target is cached for
+)
+
+// aliasAnswer stores the resolved records of an ALIAS target
+type aliasAnswer struct {
+	rrs     []dns.RR
+	expires time.Time
+}
+
+// aliasCache stores resolved ALIAS targets by qtype and target FQDN
+var aliasCache = map[uint16]map[string]aliasAnswer{
+	dns.TypeA:    {},
+	dns.TypeAAAA: {},
+}
+
+// aliasExpiry stores the time a zone's ALIAS records must be resolved again, by zone FQDN
+var aliasExpiry = map[string]time.Time{}
+
+// resolveAlias returns the records of an ALIAS target, resolving it again once the cached answer's TTL has expired
+func resolveAlias(target string, qtype uint16, now time.Time) aliasAnswer {
+	target = strings.ToLower(dns.Fqdn(target))
+	cached, ok := aliasCache[qtype][target]
+	if ok && now.Before(cached.expires) {
+		return cached
+	}
+
+	rrs, err := Resolve(target, qtype)
+	if err != nil {
+		// Keep serving the stale answer until the target resolves again
+		log.Warnf("resolving ALIAS target %s %s: %s", target, dns.TypeToString[qtype], err)
+		cached.expires = now.Add(aliasMinTTL)
+		aliasCache[qtype][target] = cached
+		return cached
+	}
+
+	ttl := aliasMaxTTL
+	for _, rr := range rrs {
+		if rrTTL := time.Duration(rr.Header().Ttl) * time.Second; rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+	if ttl < aliasMinTTL {
+		ttl = aliasMinTTL
+	}
+
+	answer := aliasAnswer{rrs: rrs, expires: now.Add(ttl)}
+	aliasCache[qtype][target] = answer
+	return answer
+}
+
+// expandAliases replaces ALIAS records with A and AAAA records of their resolved targets and returns the time they expire, or a zero time if there are no ALIAS records.
+// Relative targets are qualified with the zone's name.
+func expandAliases(zone *db.Zone, records []db.Record, now time.Time) ([]db.Record, time.Time) {
+	var expanded []db.Record
+	var expires time.Time
+	for _, record := range records {
+		if record.Type != "ALIAS" {
+			expanded = append(expanded, record)
+			continue
+		}
+
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			answer := resolveAlias(db.AbsoluteName(record.Value, zone.Zone), qtype, now)
+			if expires.IsZero() || answer.expires.Before(expires) {
+				expires = answer.expires
+			}
+
+			var addresses []db.Record
+			for _, rr := range answer.rrs {
+				// Don't publish the addresses for longer than the target's TTL
+				ttl := record.TTL
+				if rr.Header().Ttl < ttl {
+					ttl = rr.Header().Ttl
+				}
+
+				var value string
+				switch rr := rr.(type) {
+				case *dns.A:
+					value = rr.A.String()
+				case *dns.AAAA:
+					value = rr.AAAA.String()
+				default:
+					continue
+				}
+				addresses = append(addresses, db.Record{
+					Label:  record.Label,
+					Type:   dns.TypeToString[qtype],
+					Value:  value,
+					TTL:    ttl,
+					ZoneID: record.ZoneID,
+				})
+			}
+			// Sort addresses so a resolver reordering them doesn't change the zone file
+			sort.Slice(addresses, func(i, j int) bool { return addresses[i].Value < addresses[j].Value })
+			expanded = append(expanded, addresses...)
+		}
+	}
+	return expanded, expires
+}
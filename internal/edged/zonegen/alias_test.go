@@ -0,0 +1,103 @@
+package zonegen
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+	"github.com/packetframe/api/internal/common/resolver"
+)
+
+func TestExpandAliases(t *testing.T) {
+	lookups := 0
+	fail := false
+	address := "192.0.2.1"
+	var resolvedNames []string
+	Resolve = func(name string, qtype uint16) ([]dns.RR, error) {
+		lookups++
+		resolvedNames = append(resolvedNames, name)
+		if fail {
+			return nil, errors.New("resolver unavailable")
+		}
+		hdr := dns.RR_Header{Name: name, Rrtype: qtype, Class: dns.ClassINET, Ttl: 120}
+		if qtype == dns.TypeA {
+			return []dns.RR{&dns.A{Hdr: hdr, A: net.ParseIP(address)}, &dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.0")}}, nil
+		}
+		hdr.Ttl = 600
+		return []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")}}, nil
+	}
+	defer func() {
+		Resolve = resolver.Resolve
+		aliasCache = map[uint16]map[string]aliasAnswer{dns.TypeA: {}, dns.TypeAAAA: {}}
+	}()
+
+	zone := &db.Zone{Zone: "example.com.", Serial: 1}
+	records := []db.Record{
+		{Label: "@", Type: "ALIAS", Value: "LB.example.net.", TTL: 300},
+		{Label: "www", Type: "CNAME", Value: "example.com.", TTL: 300},
+	}
+	now := time.Now()
+	expanded, expires := expandAliases(zone, records, now)
+	assert.Equal(t, 4, len(expanded))
+	assert.Equal(t, now.Add(120*time.Second), expires)
+	assert.Equal(t, 2, lookups)
+
+	rendered := Render(zone, expanded)
+	assert.Contains(t, rendered, "@ 120 IN A 192.0.2.0\n@ 120 IN A 192.0.2.1\n")
+	assert.Contains(t, rendered, "@ 300 IN AAAA 2001:db8::1")
+	assert.NotContains(t, rendered, "ALIAS")
+
+	// Answers are cached until they expire
+	_, _ = expandAliases(zone, records, now.Add(time.Minute))
+	assert.Equal(t, 2, lookups)
+
+	// Targets are resolved again once their TTL expires, the AAAA answer is still cached
+	address = "192.0.2.2"
+	expanded, _ = expandAliases(zone, records, now.Add(3*time.Minute))
+	assert.Equal(t, 3, lookups)
+	assert.Contains(t, Render(zone, expanded), "@ 120 IN A 192.0.2.2")
+
+	// Stale answers are kept if the target can't be resolved
+	fail = true
+	expanded, expires = expandAliases(zone, records, now.Add(6*time.Minute))
+	assert.Equal(t, 4, len(expanded))
+	assert.Equal(t, now.Add(6*time.Minute+aliasMinTTL), expires)
+
+	// Relative targets are qualified with the zone's name
+	fail = false
+	resolvedNames = nil
+	_, _ = expandAliases(zone, []db.Record{{Label: "cdn", Type: "ALIAS", Value: "edge", TTL: 300}}, now)
+	assert.Equal(t, []string{"edge.example.com.", "edge.example.com."}, resolvedNames)
+
+	// Zones without ALIAS records don't expire
+	_, expires = expandAliases(zone, records[1:], now)
+	assert.True(t, expires.IsZero())
+}
+
+func TestFileSerial(t *testing.T) {
+	zone := &db.Zone{Zone: "example.com.", Serial: 5}
+	records := []db.Record{{Label: "@", Type: "A", Value: "192.0.2.1", TTL: 300}}
+	written := func(serial uint64, records []db.Record) string {
+		z := *zone
+		z.Serial = serial
+		return zoneFileHeader + Render(&z, records)
+	}
+
+	// New zone files and zones with a newer serial use the zone's serial
+	assert.Equal(t, uint64(5), fileSerial("", zone, records))
+	assert.Equal(t, uint64(5), fileSerial(written(4, nil), zone, records))
+
+	// Unchanged zone files keep their serial
+	assert.Equal(t, uint64(5), fileSerial(written(5, records), zone, records))
+	assert.Equal(t, uint64(7), fileSerial(written(7, records), zone, records))
+
+	// Changed ALIAS addresses increment the serial above the previous file's
+	changed := []db.Record{{Label: "@", Type: "A", Value: "192.0.2.2", TTL: 300}}
+	assert.Equal(t, uint64(6), fileSerial(written(5, records), zone, changed))
+	assert.Equal(t, uint64(8), fileSerial(written(7, records), zone, changed))
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/miekg/dns"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
@@ -17,6 +18,31 @@ import (
 // cache of zone FQDN to serial
 var cache = make(map[string]uint64)
 
+// kinds of zone FQDN to the zone kind last written
+var kinds = make(map[string]string)
+
+// zoneFileHeader starts zone files written by zonegen, telling them apart from the files knot writes for secondary zones
+const zoneFileHeader = "; generated by packetframe zonegen\n"
+
+// zoneFilePath returns the path of a zone's file
+func zoneFilePath(zonesDirectory, zone string) string {
+	return path.Join(zonesDirectory, "db."+strings.TrimSuffix(zone, "."))
+}
+
+// removeRenderedZoneFile removes a zone file written by zonegen for a zone that became secondary, so knot transfers the zone instead of loading the stale file. It returns true if a file was removed.
+func removeRenderedZoneFile(zonesDirectory, zone string) bool {
+	filePath := zoneFilePath(zonesDirectory, zone)
+	content, err := os.ReadFile(filePath)
+	if err != nil || !strings.HasPrefix(string(content), zoneFileHeader) {
+		return false
+	}
+	if err := os.Remove(filePath); err != nil {
+		log.Warnf("removing zone file of secondary zone %s: %s", zone, err)
+		return false
+	}
+	return true
+}
+
 // writeZoneToFile writes a zone file to disk
 func writeZoneToFile(database *gorm.DB, zoneID, zonesDirectory string) error {
 	zone, err := db.ZoneFindByID(database, zoneID)
@@ -29,18 +55,88 @@ func writeZoneToFile(database *gorm.DB, zoneID, zonesDirectory string) error {
 		return err
 	}
 
-	// Serial
-	// Refresh, number of seconds after which secondary NSes should query the main to detect zone changes
-	// Retry, number of seconds after which secondary NSes should retry serial query from the main if it doesn't respond
-	// Expire, number of seconds after which secondary NSes should stop answering if main doesn't respond
-	// Negative Cache TTL
-	zoneFile := fmt.Sprintf(`@ IN SOA ns1.packetframe.com. info.packetframe.com. %d 7200 3600 1209600 300
-@ 86400 IN NS ns1.packetframe.com.
-@ 86400 IN NS ns2.packetframe.com.
-`, zone.Serial)
+	// Publish ACME challenges with the zone's records
+	challenges, err := db.ACMEChallengeList(database, zoneID)
+	if err != nil {
+		return err
+	}
+	for _, challenge := range challenges {
+		records = append(records, challenge.Record(zone.Zone))
+	}
+
+	// Answer with the record health aggregated by the API, which increments the serial when it changes
+	state, err := zone.DynamicState()
+	if err != nil {
+		return err
+	}
+	records = applyPolicies(records, state)
+
+	// Replace ALIAS records with their targets' current addresses
+	records, aliasExpires := expandAliases(zone, records, time.Now())
+	if aliasExpires.IsZero() {
+		delete(aliasExpiry, zone.Zone)
+	} else {
+		aliasExpiry[zone.Zone] = aliasExpires
+	}
+
+	// Write the zone file to disk
+	filePath := zoneFilePath(zonesDirectory, zone.Zone)
+	previous, _ := os.ReadFile(filePath)
+	zone.Serial = fileSerial(string(previous), zone, records)
+	return os.WriteFile(filePath, []byte(zoneFileHeader+Render(zone, records)), 0644)
+}
+
+// fileSerial returns the serial to write a zone file with. ALIAS addresses are resolved by each edge node and can change without the zone's serial changing,
+// so a zone file whose content changed is written with a serial above the previous file's, and the serial never goes back, such as after a restart.
+func fileSerial(previous string, zone *db.Zone, records []db.Record) uint64 {
+	if !strings.HasPrefix(previous, zoneFileHeader) {
+		return zone.Serial
+	}
+	lines := strings.SplitN(strings.TrimPrefix(previous, zoneFileHeader), "\n", 2)
+	rr, err := dns.NewRR(lines[0])
+	if err != nil {
+		return zone.Serial
+	}
+	soa, ok := rr.(*dns.SOA)
+	if !ok || uint64(soa.Serial) < zone.Serial {
+		return zone.Serial
+	}
+
+	// Compare the records after the SOA record
+	rendered := strings.SplitN(Render(zone, records), "\n", 2)
+	if len(lines) == 2 && len(rendered) == 2 && lines[1] == rendered[1] {
+		return uint64(soa.Serial)
+	}
+	return uint64(soa.Serial) + 1
+}
+
+// Render renders a zone and its records as a zone file
+func Render(zone *db.Zone, records []db.Record) string {
+	zoneFile := zone.SOA().String() + "\n"
+	for _, ns := range zone.NS() {
+		zoneFile += ns.String() + "\n"
+	}
+
+	// Publish the full DNSKEY set, which includes the next keys during rollovers
+	for _, key := range zone.DNSSECKeys() {
+		zoneFile += key.Key + "\n"
+	}
+
+	// Labels delegated to edge nodes for geo and weighted records
+	delegatedLabels := map[string]bool{}
 
 	for _, record := range records {
-		if record.Type == "SCRIPT" {
+		if record.Type == "ALIAS" {
+			// ALIAS records are expanded by writeZoneToFile
+			continue
+		} else if db.PerQueryPolicy(record.Policy) {
+			// Geo and weighted records are answered for each query by the edge DNS server, the same as SCRIPT records
+			label := strings.ToLower(record.Label)
+			if !delegatedLabels[label] {
+				delegatedLabels[label] = true
+				zoneFile += fmt.Sprintf("%s 3600 IN NS script-ns.packetframe.com.\n", record.Label)
+			}
+		} else if record.Type == "SCRIPT" {
 			zoneFile += fmt.Sprintf("%s 3600 IN NS script-ns.packetframe.com.\n", record.Label)
 		} else if record.Proxy {
 			zoneFile += fmt.Sprintf("%s 3600 IN A 66.248.235.2\n", record.Label)
@@ -50,8 +146,17 @@ func writeZoneToFile(database *gorm.DB, zoneID, zonesDirectory string) error {
 		}
 	}
 
-	// Write the zone file to disk
-	return os.WriteFile(path.Join(zonesDirectory, "db."+strings.TrimSuffix(zone.Zone, ".")), []byte(zoneFile), 0644)
+	return zoneFile
+}
+
+// zoneKeyFiles returns a map of filename to content of the BIND format DNSSEC key files for a zone
+func zoneKeyFiles(zone *db.Zone) map[string]string {
+	files := map[string]string{}
+	for _, key := range zone.DNSSECKeys() {
+		files[key.Base+".key"] = key.Key + "\n"
+		files[key.Base+".private"] = key.Private
+	}
+	return files
 }
 
 // writeZoneManifest writes the zone configuration file for knot
@@ -61,36 +166,106 @@ func writeZoneManifest(database *gorm.DB, knotZonesFile string) error {
 		return err
 	}
 
+	peers, err := db.TransferPeerListAll(database)
+	if err != nil {
+		return err
+	}
+	transfers, zoneLines := transferConfig(zones, peers)
+
 	manifestContent := fmt.Sprintf("# knot.zones.conf generated at %v\n", time.Now().UTC())
+	manifestContent += transfers
 	for _, zone := range zones {
+		if zone.PendingVerification {
+			continue
+		}
 		manifestContent += fmt.Sprintf(`zone:
   - domain: %s
     template: default
 `, strings.TrimSuffix(zone.Zone, "."))
+		manifestContent += zoneLines[zone.Zone]
 	}
 
 	// Write the zone manifest to disk
 	return os.WriteFile(knotZonesFile, []byte(manifestContent), 0644)
 }
 
-// Update writes all zone files to disk and removes unreferenced ones
-func Update(zonesDirectory, knotZonesFile string, database *gorm.DB) error {
+// Update writes all zone files and DNSSEC keys to disk and removes unreferenced ones
+func Update(zonesDirectory, keysDirectory, knotZonesFile string, database *gorm.DB) error {
 	zones, err := db.ZoneList(database)
 	if err != nil {
 		return err
 	}
 
+	// Create the directories if they don't exist yet, such as on a new node
+	for _, dir := range []string{zonesDirectory, keysDirectory} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
 	// Is a knot reload required?
 	reloadRequired := false
 
+	// Key files referenced by current zones
+	keyFiles := map[string]bool{}
+
 	for _, zone := range zones {
-		// If zone not in cache or cached serial older than current serial...
-		if _, inCache := cache[zone.Zone]; !inCache || cache[zone.Zone] < zone.Serial {
+		// Zones aren't served until ownership is verified
+		if zone.PendingVerification {
+			continue
+		}
+
+		// Write the zone again when its kind changes, and remove the file of a primary zone that became secondary
+		if kinds[zone.Zone] != zone.Kind {
+			delete(cache, zone.Zone)
+			if zone.IsSecondary() && removeRenderedZoneFile(zonesDirectory, zone.Zone) {
+				reloadRequired = true
+			}
+			kinds[zone.Zone] = zone.Kind
+		}
+
+		// Knot transfers secondary zones and writes their zone files itself
+		if zone.IsSecondary() {
+			if cached, inCache := cache[zone.Zone]; !inCache || cached != zone.Serial {
+				reloadRequired = true
+				cache[zone.Zone] = zone.Serial
+			}
+			continue
+		}
+
+		files := zoneKeyFiles(&zone)
+		for f := range files {
+			keyFiles[f] = true
+		}
+
+		// If zone not in cache, cached serial older than current serial, or ALIAS targets need to be resolved again...
+		aliasExpires, hasAliases := aliasExpiry[zone.Zone]
+		if _, inCache := cache[zone.Zone]; !inCache || cache[zone.Zone] < zone.Serial || (hasAliases && time.Now().After(aliasExpires)) {
 			reloadRequired = true
 			cache[zone.Zone] = zone.Serial
 			if err := writeZoneToFile(database, zone.ID, zonesDirectory); err != nil {
 				log.Warnf("writing zone file (%s): %s", zone.Zone, err)
 			}
+			for f, content := range files {
+				if err := os.WriteFile(path.Join(keysDirectory, f), []byte(content), 0600); err != nil {
+					log.Warnf("writing DNSSEC key file (%s): %s", f, err)
+				}
+			}
+		}
+	}
+
+	// Remove key files that are no longer published, such as old keys after a rollover. A failure here doesn't stop the manifest from being written and knot reloaded.
+	existingKeyFiles, err := os.ReadDir(keysDirectory)
+	if err != nil {
+		log.Warnf("listing DNSSEC key files: %s", err)
+	}
+	for _, f := range existingKeyFiles {
+		if !keyFiles[f.Name()] {
+			log.Debugf("%s not referenced, removing", f.Name())
+			if err := os.Remove(path.Join(keysDirectory, f.Name())); err != nil {
+				log.Warnf("removing unreferenced key file %s: %s", f.Name(), err)
+			}
+			reloadRequired = true
 		}
 	}
 
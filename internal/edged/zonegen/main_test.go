@@ -0,0 +1,90 @@
+package zonegen
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// TestRenderStructuredRecords tests that structured record data round trips through a rendered zone file
+func TestRenderStructuredRecords(t *testing.T) {
+	zone := &db.Zone{Zone: "example.com.", Serial: 1}
+
+	data := map[string]string{
+		"CAA":   `{"flag": 0, "tag": "issue", "value": "letsencrypt.org"}`,
+		"TLSA":  `{"usage": 3, "selector": 1, "matching_type": 1, "certificate": "0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6"}`,
+		"SSHFP": `{"algorithm": 4, "type": 2, "fingerprint": "123456789ABCDEF67890123456789ABCDEF67890123456789ABCDEF123456789"}`,
+		"DS":    `{"key_tag": 12345, "algorithm": 13, "digest_type": 2, "digest": "0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"}`,
+		"NAPTR": `{"order": 100, "preference": 10, "flags": "S", "service": "SIP+D2U", "regexp": "", "replacement": "_sip._udp.example.com."}`,
+		"LOC":   `{"latitude": 52.5, "longitude": -4.25, "altitude": 12.5, "size": 1, "horizontal_precision": 10000, "vertical_precision": 10}`,
+		"HTTPS": `{"priority": 1, "target": ".", "params": {"alpn": "h2,h3", "ipv4hint": "192.0.2.1"}}`,
+		"SVCB":  `{"priority": 0, "target": "svc.example.net.", "params": {}}`,
+	}
+
+	var records []db.Record
+	expected := map[string]map[string]interface{}{}
+	for rrtype, fields := range data {
+		var d map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(fields), &d))
+		expected[rrtype] = d
+
+		value, err := db.RecordValueFromData(rrtype, d)
+		assert.Nilf(t, err, rrtype)
+		records = append(records, db.Record{Label: strings.ToLower(rrtype), Type: rrtype, TTL: 300, Value: value})
+	}
+
+	// Parse the rendered zone file and convert each record back to structured data
+	found := map[string]bool{}
+	zp := dns.NewZoneParser(strings.NewReader(Render(zone, records)), zone.Zone, "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrtype := dns.TypeToString[rr.Header().Rrtype]
+		if _, structured := data[rrtype]; !structured {
+			continue
+		}
+		assert.Equal(t, strings.ToLower(rrtype)+".example.com.", rr.Header().Name)
+		value := strings.TrimPrefix(rr.String(), rr.Header().String())
+		assert.Equalf(t, expected[rrtype], db.RecordDataFromValue(rrtype, value), rrtype)
+		found[rrtype] = true
+	}
+	assert.Nil(t, zp.Err())
+	assert.Equal(t, len(data), len(found))
+}
+
+func TestRenderInvalidStructuredRecords(t *testing.T) {
+	for rrtype, fields := range map[string]string{
+		"CAA":   `{"flag": 0, "tag": "issue", "value": "letsencrypt.org", "unknown": 1}`,
+		"TLSA":  `{"usage": 3, "selector": 1, "matching_type": 1, "certificate": "not hex"}`,
+		"LOC":   `{"latitude": 95, "longitude": 0}`,
+		"HTTPS": `{"priority": 1, "target": ".", "params": {"bogus": "1"}}`,
+		"A":     `{"address": "192.0.2.1"}`,
+	} {
+		var d map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(fields), &d))
+		_, err := db.RecordValueFromData(rrtype, d)
+		assert.NotNilf(t, err, rrtype)
+	}
+}
+
+func TestRemoveRenderedZoneFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// Files written by zonegen are removed
+	assert.Nil(t, os.WriteFile(zoneFilePath(dir, "example1.com."), []byte(zoneFileHeader+"@ 300 IN A 192.0.2.1\n"), 0644))
+	assert.True(t, removeRenderedZoneFile(dir, "example1.com."))
+	_, err := os.Stat(zoneFilePath(dir, "example1.com."))
+	assert.True(t, os.IsNotExist(err))
+
+	// Files written by knot for transferred zones are kept
+	assert.Nil(t, os.WriteFile(zoneFilePath(dir, "example2.com."), []byte(";; Zone dump (Knot DNS 3.1.8)\n"), 0644))
+	assert.False(t, removeRenderedZoneFile(dir, "example2.com."))
+	_, err = os.Stat(zoneFilePath(dir, "example2.com."))
+	assert.Nil(t, err)
+
+	assert.False(t, removeRenderedZoneFile(dir, "example3.com."))
+}
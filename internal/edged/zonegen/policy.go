@@ -0,0 +1,73 @@
+package zonegen
+
+import (
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// healthPolicy checks if a record's answers are chosen by zonegen from the health of its set, rather than by edge nodes for each query
+func healthPolicy(record db.Record) bool {
+	return record.Policy != "" && !db.PerQueryPolicy(record.Policy)
+}
+
+// policyAnswers returns the records of a set that answer given their health
+func policyAnswers(set []db.Record, healthy map[string]bool) []db.Record {
+	var candidates []db.Record
+	for _, record := range set {
+		if h, ok := healthy[record.ID]; !ok || h {
+			candidates = append(candidates, record)
+		}
+	}
+	// Answer with the whole set if all records are unhealthy, it's more likely the checks are failing than every address
+	if len(candidates) == 0 {
+		candidates = set
+	}
+
+	switch set[0].Policy {
+	case db.PolicyFailover:
+		priority := candidates[0].Priority
+		for _, record := range candidates {
+			if record.Priority < priority {
+				priority = record.Priority
+			}
+		}
+		var answers []db.Record
+		for _, record := range candidates {
+			if record.Priority == priority {
+				answers = append(answers, record)
+			}
+		}
+		return answers
+	default:
+		return candidates
+	}
+}
+
+// applyPolicies replaces record sets with a policy by the records that answer given their health in the zone's dynamic state
+func applyPolicies(records []db.Record, state db.DynamicState) []db.Record {
+	healthy := map[string]bool{}
+	for _, recordID := range state.Unhealthy {
+		healthy[recordID] = false
+	}
+
+	sets := map[string][]db.Record{}
+	for _, record := range records {
+		if healthPolicy(record) {
+			sets[record.SetKey()] = append(sets[record.SetKey()], record)
+		}
+	}
+
+	var applied []db.Record
+	for _, record := range records {
+		if !healthPolicy(record) {
+			applied = append(applied, record)
+			continue
+		}
+		set, ok := sets[record.SetKey()]
+		if !ok {
+			continue // Already applied
+		}
+		delete(sets, record.SetKey())
+		applied = append(applied, policyAnswers(set, healthy)...)
+	}
+	return applied
+}
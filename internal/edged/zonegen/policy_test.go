@@ -0,0 +1,73 @@
+package zonegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// values returns the values of records
+func values(records []db.Record) []string {
+	var v []string
+	for _, record := range records {
+		v = append(v, record.Value)
+	}
+	return v
+}
+
+func TestApplyPoliciesFailover(t *testing.T) {
+	records := []db.Record{
+		{ID: "1", Label: "www", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyFailover, Priority: 1},
+		{ID: "2", Label: "www", Type: "A", Value: "192.0.2.2", TTL: 300, Policy: db.PolicyFailover, Priority: 2},
+		{ID: "3", Label: "WWW", Type: "A", Value: "192.0.2.3", TTL: 300, Policy: db.PolicyFailover, Priority: 2},
+		{ID: "4", Label: "www", Type: "TXT", Value: `"example"`, TTL: 300},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		unhealthy []string
+		values    []string
+	}{
+		{"primary healthy", nil, []string{"192.0.2.1", `"example"`}},
+		{"primary unhealthy", []string{"1"}, []string{"192.0.2.2", "192.0.2.3", `"example"`}},
+		{"all unhealthy", []string{"1", "2", "3"}, []string{"192.0.2.1", `"example"`}},
+	} {
+		applied := applyPolicies(records, db.DynamicState{Unhealthy: tc.unhealthy})
+		assert.Equal(t, tc.values, values(applied), tc.name)
+	}
+}
+
+func TestApplyPoliciesMultivalue(t *testing.T) {
+	records := []db.Record{
+		{ID: "1", Label: "www", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyMultivalue},
+		{ID: "2", Label: "www", Type: "AAAA", Value: "2001:db8::2", TTL: 300, Policy: db.PolicyMultivalue},
+		{ID: "3", Label: "www", Type: "AAAA", Value: "2001:db8::3", TTL: 300, Policy: db.PolicyMultivalue},
+	}
+
+	applied := applyPolicies(records, db.DynamicState{Unhealthy: []string{"2"}})
+	assert.Equal(t, []string{"2001:db8::1", "2001:db8::3"}, values(applied))
+}
+
+func TestRenderPerQuery(t *testing.T) {
+	zone := &db.Zone{Zone: "example.com.", Serial: 1}
+	records := []db.Record{
+		{ID: "1", Label: "www", Type: "A", Value: "192.0.2.1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"},
+		{ID: "2", Label: "www", Type: "A", Value: "192.0.2.2", TTL: 300, Policy: db.PolicyGeo, Geo: "continent:EU"},
+		{ID: "3", Label: "www", Type: "AAAA", Value: "2001:db8::1", TTL: 300, Policy: db.PolicyGeo, Geo: "default"},
+		{ID: "4", Label: "lb", Type: "A", Value: "192.0.2.4", TTL: 300, Policy: db.PolicyWeighted, Weight: 1},
+		{ID: "5", Label: "lb", Type: "A", Value: "192.0.2.5", TTL: 300, Policy: db.PolicyWeighted, Weight: 3},
+	}
+
+	// Geo and weighted records aren't chosen by zonegen, their name is delegated once to the edge DNS server
+	applied := applyPolicies(records, db.DynamicState{Unhealthy: []string{"1", "4"}})
+	assert.Equal(t, records, applied)
+
+	rendered := Render(zone, applied)
+	assert.Equal(t, 1, strings.Count(rendered, "www 3600 IN NS script-ns.packetframe.com."))
+	assert.Equal(t, 1, strings.Count(rendered, "lb 3600 IN NS script-ns.packetframe.com."))
+	assert.NotContains(t, rendered, "192.0.2.1")
+	assert.NotContains(t, rendered, "192.0.2.4")
+}
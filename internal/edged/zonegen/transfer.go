@@ -0,0 +1,116 @@
+package zonegen
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+// knotAddress converts a host:port address to knot's address@port format
+func knotAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host + "@" + port
+}
+
+// knotHost returns the host of a host:port address
+func knotHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// knotConfig accumulates the knot key, remote and acl sections shared by all zones
+type knotConfig struct {
+	keys, remotes, acls string
+	seenKeys            map[string]bool
+}
+
+// key adds a TSIG key once and returns the key line for remotes and acls that use it
+func (k *knotConfig) key(key db.TSIGKey) string {
+	if !key.Configured() {
+		return ""
+	}
+	if !k.seenKeys[key.Name] {
+		k.seenKeys[key.Name] = true
+		k.keys += fmt.Sprintf("  - id: %s\n    algorithm: %s\n    secret: %s\n", key.Name, key.Algorithm, key.Secret)
+	}
+	return fmt.Sprintf("    key: %s\n", key.Name)
+}
+
+// String returns the config sections
+func (k *knotConfig) String() string {
+	var config string
+	if k.keys != "" {
+		config += "key:\n" + k.keys
+	}
+	if k.remotes != "" {
+		config += "remote:\n" + k.remotes
+	}
+	if k.acls != "" {
+		config += "acl:\n" + k.acls
+	}
+	return config
+}
+
+// transferConfig returns the knot key, remote and acl sections for transfers from primaries of secondary zones and to transfer peers,
+// and the extra zone section lines that reference them by zone FQDN
+func transferConfig(zones []db.Zone, peers map[string][]db.TransferPeer) (string, map[string]string) {
+	config := &knotConfig{seenKeys: map[string]bool{}}
+	zoneLines := map[string]string{}
+	for _, zone := range zones {
+		if zone.PendingVerification {
+			continue
+		}
+		name := strings.TrimSuffix(zone.Zone, ".")
+		var masters, acls, notify []string
+
+		if zone.IsSecondary() {
+			var addresses, hosts []string
+			for _, primary := range zone.Primaries {
+				addresses = append(addresses, knotAddress(primary))
+				hosts = append(hosts, knotHost(primary))
+			}
+			keyLine := config.key(zone.TSIG)
+			config.remotes += fmt.Sprintf("  - id: primary-%s\n    address: [%s]\n%s", name, strings.Join(addresses, ", "), keyLine)
+			config.acls += fmt.Sprintf("  - id: notify-%s\n    address: [%s]\n    action: notify\n%s", name, strings.Join(hosts, ", "), keyLine)
+			masters = append(masters, "primary-"+name)
+			acls = append(acls, "notify-"+name)
+		}
+
+		for _, peer := range peers[zone.ID] {
+			keyLine := config.key(peer.TSIG)
+			config.acls += fmt.Sprintf("  - id: transfer-%s\n    address: [%s]\n    action: transfer\n%s", peer.ID, knotHost(peer.Address), keyLine)
+			acls = append(acls, "transfer-"+peer.ID)
+			if peer.Notify {
+				config.remotes += fmt.Sprintf("  - id: peer-%s\n    address: [%s]\n%s", peer.ID, knotAddress(peer.Address), keyLine)
+				notify = append(notify, "peer-"+peer.ID)
+			}
+		}
+
+		var lines string
+		if len(masters) > 0 {
+			lines += fmt.Sprintf("    master: [%s]\n", strings.Join(masters, ", "))
+		}
+		if len(acls) > 0 {
+			lines += fmt.Sprintf("    acl: [%s]\n", strings.Join(acls, ", "))
+		}
+		if len(notify) > 0 {
+			lines += fmt.Sprintf("    notify: [%s]\n", strings.Join(notify, ", "))
+		}
+		if zone.IsSecondary() {
+			// Secondary zones are transferred as-is and never written by zonegen
+			lines += "    dnssec-signing: off\n"
+		}
+		if lines != "" {
+			zoneLines[zone.Zone] = lines
+		}
+	}
+	return config.String(), zoneLines
+}
@@ -0,0 +1,79 @@
+package zonegen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/packetframe/api/internal/common/db"
+)
+
+func TestTransferConfig(t *testing.T) {
+	key := db.TSIGKey{Name: "transfer.example.com.", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"}
+	peerKey := db.TSIGKey{Name: "peer.example.com.", Algorithm: "hmac-sha512", Secret: "cGVlcg=="}
+	zones := []db.Zone{
+		{ID: "1", Zone: "example1.com.", Kind: db.ZoneKindSecondary, Primaries: []string{"192.0.2.1:53", "[2001:db8::1]:5353"}, TSIG: key},
+		{ID: "2", Zone: "example2.com.", Kind: db.ZoneKindSecondary, Primaries: []string{"192.0.2.1:53"}, TSIG: key},
+		{ID: "3", Zone: "example3.com.", Kind: db.ZoneKindSecondary, Primaries: []string{"192.0.2.3:53"}},
+		{ID: "4", Zone: "example4.com.", Kind: db.ZoneKindSecondary, Primaries: []string{"192.0.2.4:53"}, PendingVerification: true},
+		{ID: "5", Zone: "example5.com.", Kind: db.ZoneKindPrimary},
+		{ID: "6", Zone: "example6.com.", Kind: db.ZoneKindPrimary},
+	}
+	peers := map[string][]db.TransferPeer{
+		"5": {
+			{ID: "peer1", ZoneID: "5", Address: "198.51.100.1:53", Notify: true, TSIG: peerKey},
+			{ID: "peer2", ZoneID: "5", Address: "[2001:db8::2]:53", TSIG: key},
+		},
+		"4": {{ID: "peer3", ZoneID: "4", Address: "198.51.100.3:53"}},
+	}
+
+	config, zoneLines := transferConfig(zones, peers)
+	assert.Equal(t, `key:
+  - id: transfer.example.com.
+    algorithm: hmac-sha256
+    secret: c2VjcmV0
+  - id: peer.example.com.
+    algorithm: hmac-sha512
+    secret: cGVlcg==
+remote:
+  - id: primary-example1.com
+    address: [192.0.2.1@53, 2001:db8::1@5353]
+    key: transfer.example.com.
+  - id: primary-example2.com
+    address: [192.0.2.1@53]
+    key: transfer.example.com.
+  - id: primary-example3.com
+    address: [192.0.2.3@53]
+  - id: peer-peer1
+    address: [198.51.100.1@53]
+    key: peer.example.com.
+acl:
+  - id: notify-example1.com
+    address: [192.0.2.1, 2001:db8::1]
+    action: notify
+    key: transfer.example.com.
+  - id: notify-example2.com
+    address: [192.0.2.1]
+    action: notify
+    key: transfer.example.com.
+  - id: notify-example3.com
+    address: [192.0.2.3]
+    action: notify
+  - id: transfer-peer1
+    address: [198.51.100.1]
+    action: transfer
+    key: peer.example.com.
+  - id: transfer-peer2
+    address: [2001:db8::2]
+    action: transfer
+    key: transfer.example.com.
+`, config)
+
+	assert.Equal(t, "    master: [primary-example1.com]\n    acl: [notify-example1.com]\n    dnssec-signing: off\n", zoneLines["example1.com."])
+	assert.Equal(t, "    acl: [transfer-peer1, transfer-peer2]\n    notify: [peer-peer1]\n", zoneLines["example5.com."])
+	assert.Equal(t, "", zoneLines["example4.com."])
+	assert.Equal(t, "", zoneLines["example6.com."])
+
+	config, _ = transferConfig(zones[5:], nil)
+	assert.Equal(t, "", config)
+}